@@ -189,6 +189,7 @@ const (
 	TokenStatusDisabled  = 2 // also don't use 0
 	TokenStatusExpired   = 3
 	TokenStatusExhausted = 4
+	TokenStatusSuspended = 5 // 用量异常被自动暂停，只有管理员可以恢复
 )
 
 const (
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PriorityLimiter 进程内的并发限制器。并发已满时请求进入等待队列，释放名额时优先分配给
+// 有效优先级最高的等待者；有效优先级 = 基础优先级 + 等待时长 / aging，用于避免低优先级请求饿死。
+type PriorityLimiter struct {
+	mu       sync.Mutex
+	capacity int
+	inUse    int
+	aging    time.Duration
+	seq      uint64
+	waiters  []*priorityWaiter
+	now      func() time.Time
+}
+
+type priorityWaiter struct {
+	priority   int
+	enqueuedAt time.Time
+	seq        uint64
+	ready      chan struct{}
+}
+
+func NewPriorityLimiter(capacity int, aging time.Duration) *PriorityLimiter {
+	return &PriorityLimiter{capacity: capacity, aging: aging, now: time.Now}
+}
+
+// SetLimits 更新并发上限与老化时长，上限调大时会立即唤醒等待者
+func (l *PriorityLimiter) SetLimits(capacity int, aging time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.capacity = capacity
+	l.aging = aging
+	l.dispatchLocked()
+}
+
+// Acquire 获取一个并发名额，ctx 结束前未获取到时返回 ctx.Err()
+func (l *PriorityLimiter) Acquire(ctx context.Context, priority int) error {
+	l.mu.Lock()
+	if l.inUse < l.capacity && len(l.waiters) == 0 {
+		l.inUse++
+		l.mu.Unlock()
+		return nil
+	}
+	l.seq++
+	w := &priorityWaiter{priority: priority, enqueuedAt: l.now(), seq: l.seq, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.ready:
+			// 超时的同时已被分配名额，归还给下一个等待者
+			l.inUse--
+			l.dispatchLocked()
+		default:
+			l.removeLocked(w)
+		}
+		return ctx.Err()
+	}
+}
+
+// Release 归还名额
+func (l *PriorityLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse > 0 {
+		l.inUse--
+	}
+	l.dispatchLocked()
+}
+
+// InUse 当前占用的名额数与排队数
+func (l *PriorityLimiter) InUse() (inUse int, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse, len(l.waiters)
+}
+
+func (l *PriorityLimiter) dispatchLocked() {
+	for l.inUse < l.capacity && len(l.waiters) > 0 {
+		now := l.now()
+		best := 0
+		for i := 1; i < len(l.waiters); i++ {
+			if l.higherLocked(l.waiters[i], l.waiters[best], now) {
+				best = i
+			}
+		}
+		w := l.waiters[best]
+		l.waiters = append(l.waiters[:best], l.waiters[best+1:]...)
+		l.inUse++
+		close(w.ready)
+	}
+}
+
+func (l *PriorityLimiter) higherLocked(a, b *priorityWaiter, now time.Time) bool {
+	pa, pb := l.effectivePriority(a, now), l.effectivePriority(b, now)
+	if pa != pb {
+		return pa > pb
+	}
+	return a.seq < b.seq
+}
+
+func (l *PriorityLimiter) effectivePriority(w *priorityWaiter, now time.Time) int64 {
+	priority := int64(w.priority)
+	if l.aging > 0 {
+		priority += int64(now.Sub(w.enqueuedAt) / l.aging)
+	}
+	return priority
+}
+
+func (l *PriorityLimiter) removeLocked(target *priorityWaiter) {
+	for i, w := range l.waiters {
+		if w == target {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// enqueue 在后台排队并返回获得名额时关闭的通道，等到请求确实进入队列后才返回
+func enqueue(t *testing.T, l *PriorityLimiter, priority int) <-chan struct{} {
+	t.Helper()
+	_, queuedBefore := l.InUse()
+	acquired := make(chan struct{})
+	go func() {
+		require.NoError(t, l.Acquire(context.Background(), priority))
+		close(acquired)
+	}()
+	require.Eventually(t, func() bool {
+		_, queued := l.InUse()
+		return queued == queuedBefore+1
+	}, time.Second, time.Millisecond)
+	return acquired
+}
+
+func requireAcquired(t *testing.T, acquired <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not served")
+	}
+}
+
+func requireWaiting(t *testing.T, acquired <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-acquired:
+		t.Fatal("waiter was served too early")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestPriorityLimiter_HighPriorityServedFirst(t *testing.T) {
+	l := NewPriorityLimiter(1, 0)
+	require.NoError(t, l.Acquire(context.Background(), 0))
+
+	low := enqueue(t, l, 0)
+	high := enqueue(t, l, 10)
+
+	l.Release()
+	requireAcquired(t, high)
+	requireWaiting(t, low)
+
+	l.Release()
+	requireAcquired(t, low)
+}
+
+func TestPriorityLimiter_AgingServesLowPriority(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewPriorityLimiter(1, time.Second)
+	l.now = func() time.Time { return now }
+	require.NoError(t, l.Acquire(context.Background(), 0))
+
+	low := enqueue(t, l, 0)
+	// 低优先级请求已等待 20 秒，有效优先级 20 高于新到的高优先级请求
+	now = now.Add(20 * time.Second)
+	high := enqueue(t, l, 10)
+
+	l.Release()
+	requireAcquired(t, low)
+	requireWaiting(t, high)
+
+	l.Release()
+	requireAcquired(t, high)
+}
+
+func TestPriorityLimiter_TimeoutLeavesQueue(t *testing.T) {
+	l := NewPriorityLimiter(1, 0)
+	require.NoError(t, l.Acquire(context.Background(), 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Acquire(ctx, 5), context.DeadlineExceeded)
+	inUse, queued := l.InUse()
+	require.Equal(t, 1, inUse)
+	require.Zero(t, queued)
+}
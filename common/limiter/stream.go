@@ -0,0 +1,49 @@
+package limiter
+
+import "sync"
+
+// ConnectionLimiter 进程内的连接数限制器，同时限制总连接数与单个 key 的连接数，超出时直接拒绝不排队
+type ConnectionLimiter struct {
+	mu    sync.Mutex
+	total int
+	byKey map[int]int
+}
+
+func NewConnectionLimiter() *ConnectionLimiter {
+	return &ConnectionLimiter{byKey: make(map[int]int)}
+}
+
+// TryAcquire 尝试占用一个连接名额，maxTotal 与 maxPerKey 小于等于 0 表示不限制
+func (l *ConnectionLimiter) TryAcquire(key int, maxTotal int, maxPerKey int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if maxTotal > 0 && l.total >= maxTotal {
+		return false
+	}
+	if maxPerKey > 0 && l.byKey[key] >= maxPerKey {
+		return false
+	}
+	l.total++
+	l.byKey[key]++
+	return true
+}
+
+// Release 归还 key 占用的连接名额
+func (l *ConnectionLimiter) Release(key int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.byKey[key] <= 0 {
+		return
+	}
+	l.total--
+	if l.byKey[key]--; l.byKey[key] == 0 {
+		delete(l.byKey, key)
+	}
+}
+
+// InUse 当前的总连接数与 key 的连接数
+func (l *ConnectionLimiter) InUse(key int) (total int, perKey int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total, l.byKey[key]
+}
@@ -238,6 +238,38 @@ func RedisHGetObj(key string, obj interface{}) error {
 	return nil
 }
 
+// RedisDelByPrefix 删除所有以 prefix 开头的键，返回删除的数量
+func RedisDelByPrefix(prefix string) (int64, error) {
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis DEL by prefix: prefix=%s", prefix))
+	}
+	ctx := context.Background()
+	var deleted int64
+	iter := RDB.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+	keys := make([]string, 0, 1000)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		n, err := RDB.Del(ctx, keys...).Result()
+		deleted += n
+		keys = keys[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) >= 1000 {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
 // RedisIncr Add this function to handle atomic increments
 func RedisIncr(key string, delta int64) error {
 	if DebugEnabled {
@@ -7,8 +7,10 @@ const (
 	ContextKeyPromptTokens    ContextKey = "prompt_tokens"
 	ContextKeyEstimatedTokens ContextKey = "estimated_tokens"
 
-	ContextKeyOriginalModel    ContextKey = "original_model"
-	ContextKeyRequestStartTime ContextKey = "request_start_time"
+	ContextKeyOriginalModel        ContextKey = "original_model"
+	ContextKeyVirtualModel         ContextKey = "virtual_model"          // 请求使用的虚拟模型名，original_model 为实际选中的模型
+	ContextKeyBudgetDowngradedFrom ContextKey = "budget_downgraded_from" // 预算紧张时被降级替换前的模型名
+	ContextKeyRequestStartTime     ContextKey = "request_start_time"
 
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
@@ -19,6 +21,7 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenAllowedEndpoints  ContextKey = "token_allowed_endpoints"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -37,6 +40,7 @@ const (
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
 	ContextKeyChannelKey               ContextKey = "channel_key"
+	ContextKeyChannelRegion            ContextKey = "channel_region"
 
 	ContextKeyAutoGroup           ContextKey = "auto_group"
 	ContextKeyAutoGroupIndex      ContextKey = "auto_group_index"
@@ -52,10 +56,20 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	// ContextKeyTenantId 当前用户所属的租户，渠道选择与管理接口按它隔离
+	ContextKeyTenantId ContextKey = "tenant_id"
+	// ContextKeyRequestTenantId 从子域名或请求头解析出的租户，仅在开启多租户时设置
+	ContextKeyRequestTenantId ContextKey = "request_tenant_id"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
+	ContextKeyGroupSystemPromptInjected ContextKey = "group_system_prompt_injected"
+
+	// ContextKeyDataResidencyRegions 当前用户的数据驻留要求，只允许选择这些区域的渠道
+	ContextKeyDataResidencyRegions ContextKey = "data_residency_regions"
+
 	// ContextKeyAdminRejectReason stores an admin-only reject/block reason extracted from upstream responses.
 	// It is not returned to end users, but can be persisted into consume/error logs for debugging.
 	ContextKeyAdminRejectReason ContextKey = "admin_reject_reason"
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardQuotaLimits 用户与当前令牌的额度
+type DashboardQuotaLimits struct {
+	UserRemaining  int   `json:"user_remaining"`
+	UserUsed       int   `json:"user_used"`
+	TokenRemaining int   `json:"token_remaining"`
+	TokenUsed      int   `json:"token_used"`
+	TokenUnlimited bool  `json:"token_unlimited"`
+	TokenExpiredAt int64 `json:"token_expired_at"` // -1 表示永不过期
+}
+
+// DashboardEndpointQuota 用户分组在某类接口上的额度
+type DashboardEndpointQuota struct {
+	Endpoint  string `json:"endpoint"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining int64  `json:"remaining"`
+}
+
+// DashboardModelConcurrency 模型的全局并发上限与当前占用
+type DashboardModelConcurrency struct {
+	Model string `json:"model"`
+	Limit int    `json:"limit"`
+	InUse int    `json:"in_use"`
+}
+
+type DashboardLimitsResponse struct {
+	Object           string                                 `json:"object"`
+	Group            string                                 `json:"group"`
+	Quota            DashboardQuotaLimits                   `json:"quota"`
+	RequestLimit     *middleware.ModelRequestRateLimitUsage `json:"request_limit"`
+	EndpointQuotas   []DashboardEndpointQuota               `json:"endpoint_quotas"`
+	ModelConcurrency []DashboardModelConcurrency            `json:"model_concurrency"`
+}
+
+// GetDashboardLimits 返回当前令牌可用的额度、请求限流用量与各项生效中的限制
+func GetDashboardLimits(c *gin.Context) {
+	response, err := buildDashboardLimits(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"error": types.OpenAIError{
+				Message: err.Error(),
+				Type:    "new_api_error",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func buildDashboardLimits(c *gin.Context) (*DashboardLimitsResponse, error) {
+	userId := c.GetInt("id")
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		return nil, err
+	}
+	userRemaining, err := model.GetUserQuota(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	userUsed, err := model.GetUserUsedQuota(userId)
+	if err != nil {
+		return nil, err
+	}
+	requestLimit, err := middleware.GetModelRequestRateLimitUsage(c)
+	if err != nil {
+		return nil, err
+	}
+	group := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	response := &DashboardLimitsResponse{
+		Object: "limits",
+		Group:  group,
+		Quota: DashboardQuotaLimits{
+			UserRemaining:  userRemaining,
+			UserUsed:       userUsed,
+			TokenRemaining: token.RemainQuota,
+			TokenUsed:      token.UsedQuota,
+			TokenUnlimited: token.UnlimitedQuota,
+			TokenExpiredAt: token.ExpiredTime,
+		},
+		RequestLimit:     requestLimit,
+		EndpointQuotas:   []DashboardEndpointQuota{},
+		ModelConcurrency: []DashboardModelConcurrency{},
+	}
+
+	for endpoint, limit := range operation_setting.GetGroupEndpointQuotaSetting().Limits[group] {
+		used, err := model.GetGroupEndpointQuotaUsed(group, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		response.EndpointQuotas = append(response.EndpointQuotas, DashboardEndpointQuota{
+			Endpoint:  endpoint,
+			Limit:     limit,
+			Used:      used,
+			Remaining: max(limit-used, 0),
+		})
+	}
+	sort.Slice(response.EndpointQuotas, func(i, j int) bool {
+		return response.EndpointQuotas[i].Endpoint < response.EndpointQuotas[j].Endpoint
+	})
+
+	for modelName := range operation_setting.GetModelConcurrencySetting().Limits {
+		limit, ok := operation_setting.GetModelConcurrencyLimit(modelName)
+		if !ok {
+			continue
+		}
+		response.ModelConcurrency = append(response.ModelConcurrency, DashboardModelConcurrency{
+			Model: modelName,
+			Limit: limit,
+			InUse: middleware.GetModelConcurrencyInUse(modelName),
+		})
+	}
+	sort.Slice(response.ModelConcurrency, func(i, j int) bool {
+		return response.ModelConcurrency[i].Model < response.ModelConcurrency[j].Model
+	})
+	return response, nil
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDashboardLimits_ReportsRemainingAfterRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.User{}, &model.Token{})
+	origEnabled, origCount, origSuccess := setting.ModelRequestRateLimitEnabled, setting.ModelRequestRateLimitCount, setting.ModelRequestRateLimitSuccessCount
+	t.Cleanup(func() {
+		setting.ModelRequestRateLimitEnabled, setting.ModelRequestRateLimitCount, setting.ModelRequestRateLimitSuccessCount = origEnabled, origCount, origSuccess
+	})
+	setting.ModelRequestRateLimitEnabled = true
+	setting.ModelRequestRateLimitCount = 0
+	setting.ModelRequestRateLimitSuccessCount = 10
+
+	user := model.User{Username: "limits", Password: "12345678", AffCode: "l1", Quota: 1000}
+	require.NoError(t, model.DB.Create(&user).Error)
+	token := model.Token{UserId: user.Id, Key: "limits-key", Name: "limits", RemainQuota: 500, ExpiredTime: -1}
+	require.NoError(t, model.DB.Create(&token).Error)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("id", user.Id)
+		c.Set("token_id", token.Id)
+		c.Next()
+	})
+	router.POST("/v1/chat/completions", middleware.ModelRequestRateLimit(), func(c *gin.Context) {
+		require.NoError(t, model.DecreaseUserQuota(user.Id, 30))
+		require.NoError(t, model.DecreaseTokenQuota(token.Id, token.Key, 30))
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/v1/dashboard/limits", GetDashboardLimits)
+
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/dashboard/limits", nil))
+	var resp DashboardLimitsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	require.Equal(t, "limits", resp.Object)
+	require.Equal(t, 910, resp.Quota.UserRemaining)
+	require.Equal(t, 410, resp.Quota.TokenRemaining)
+	require.Equal(t, 90, resp.Quota.TokenUsed)
+	require.True(t, resp.RequestLimit.Enabled)
+	require.Equal(t, 10, resp.RequestLimit.MaxSuccessRequests)
+	require.Equal(t, 3, resp.RequestLimit.SuccessRequests)
+	require.Equal(t, 7, resp.RequestLimit.RemainingRequests)
+}
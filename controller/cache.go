@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type purgeCacheRequest struct {
+	Caches []string `json:"caches"` // 为空或包含 all 时清理全部
+}
+
+// PurgeCache 清理指定的缓存，返回各类别清理的条目数
+func PurgeCache(c *gin.Context) {
+	var req purgeCacheRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	cleared, err := model.PurgeCaches(req.Caches)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	names := slices.Sorted(maps.Keys(cleared))
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员清理了缓存: %s", strings.Join(names, ", ")))
+	common.ApiSuccess(c, cleared)
+}
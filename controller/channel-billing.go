@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -137,7 +138,12 @@ func GetClaudeAuthHeader(token string) http.Header {
 }
 
 func GetResponseBody(method, url string, channel *model.Channel, headers http.Header) ([]byte, error) {
-	req, err := http.NewRequest(method, url, nil)
+	return GetResponseBodyWithContext(context.Background(), method, url, channel, headers)
+}
+
+// GetResponseBodyWithContext 与 GetResponseBody 相同，ctx 取消或超时时中止请求
+func GetResponseBodyWithContext(ctx context.Context, method, url string, channel *model.Channel, headers http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -428,6 +434,9 @@ func UpdateChannelBalance(c *gin.Context) {
 		return
 	}
 	channel, err := model.CacheGetChannel(id)
+	if err == nil && !tenantAccessible(c, channel.TenantId) {
+		err = errors.New("渠道不存在")
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -451,12 +460,16 @@ func UpdateChannelBalance(c *gin.Context) {
 	})
 }
 
-func updateAllChannelsBalance() error {
+// updateAllChannelsBalance 更新租户内所有启用渠道的余额，tenantId 为 AllTenants 时不限租户
+func updateAllChannelsBalance(tenantId int) error {
 	channels, err := model.GetAllChannels(0, 0, true, false)
 	if err != nil {
 		return err
 	}
 	for _, channel := range channels {
+		if tenantId != model.AllTenants && channel.TenantId != tenantId {
+			continue
+		}
 		if channel.Status != common.ChannelStatusEnabled {
 			continue
 		}
@@ -483,7 +496,7 @@ func updateAllChannelsBalance() error {
 
 func UpdateAllChannelsBalance(c *gin.Context) {
 	// TODO: make it async
-	err := updateAllChannelsBalance()
+	err := updateAllChannelsBalance(tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -499,7 +512,7 @@ func AutomaticallyUpdateChannels(frequency int) {
 	for {
 		time.Sleep(time.Duration(frequency) * time.Minute)
 		common.SysLog("updating all channels")
-		_ = updateAllChannelsBalance()
+		_ = updateAllChannelsBalance(model.AllTenants)
 		common.SysLog("channels update done")
 	}
 }
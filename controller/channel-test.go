@@ -611,6 +611,10 @@ func TestChannel(c *gin.Context) {
 			return
 		}
 	}
+	if !tenantAccessible(c, channel.TenantId) {
+		common.ApiErrorMsg(c, "渠道不存在")
+		return
+	}
 	//defer func() {
 	//	if channel.ChannelInfo.IsMultiKey {
 	//		go func() { _ = channel.SaveChannelInfo() }()
@@ -650,7 +654,8 @@ func TestChannel(c *gin.Context) {
 var testAllChannelsLock sync.Mutex
 var testAllChannelsRunning bool = false
 
-func testAllChannels(notify bool) error {
+// testAllChannels 测试租户内的所有渠道，tenantId 为 AllTenants 时不限租户
+func testAllChannels(notify bool, tenantId int) error {
 
 	testAllChannelsLock.Lock()
 	if testAllChannelsRunning {
@@ -676,6 +681,9 @@ func testAllChannels(notify bool) error {
 		}()
 
 		for _, channel := range channels {
+			if tenantId != model.AllTenants && channel.TenantId != tenantId {
+				continue
+			}
 			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
 			tik := time.Now()
 			result := testChannel(channel, "", "")
@@ -720,7 +728,7 @@ func testAllChannels(notify bool) error {
 }
 
 func TestAllChannels(c *gin.Context) {
-	err := testAllChannels(true)
+	err := testAllChannels(true, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -749,7 +757,7 @@ func AutomaticallyTestChannels() {
 				time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
 				common.SysLog(fmt.Sprintf("automatically test channels with interval %f minutes", frequency))
 				common.SysLog("automatically testing all channels")
-				_ = testAllChannels(false)
+				_ = testAllChannels(false, model.AllTenants)
 				common.SysLog("automatically channel test finished")
 				if !operation_setting.GetMonitorSetting().AutoTestChannelEnabled {
 					break
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay/channel/gemini"
 	"github.com/QuantumNous/new-api/relay/channel/ollama"
+	"github.com/QuantumNous/new-api/relay/transformer"
 	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
@@ -87,7 +89,7 @@ func GetAllChannels(c *gin.Context) {
 	var total int64
 
 	if enableTagMode {
-		tags, err := model.GetPaginatedTags(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+		tags, err := model.GetPaginatedTags(tenantScopeOf(c), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 			return
@@ -102,6 +104,9 @@ func GetAllChannels(c *gin.Context) {
 			}
 			filtered := make([]*model.Channel, 0)
 			for _, ch := range tagChannels {
+				if !tenantAccessible(c, ch.TenantId) {
+					continue
+				}
 				if statusFilter == common.ChannelStatusEnabled && ch.Status != common.ChannelStatusEnabled {
 					continue
 				}
@@ -115,9 +120,9 @@ func GetAllChannels(c *gin.Context) {
 			}
 			channelData = append(channelData, filtered...)
 		}
-		total, _ = model.CountAllTags()
+		total, _ = model.CountAllTags(tenantScopeOf(c))
 	} else {
-		baseQuery := model.DB.Model(&model.Channel{})
+		baseQuery := model.DB.Model(&model.Channel{}).Scopes(model.TenantScope(tenantScopeOf(c)))
 		if typeFilter >= 0 {
 			baseQuery = baseQuery.Where("type = ?", typeFilter)
 		}
@@ -145,7 +150,7 @@ func GetAllChannels(c *gin.Context) {
 		clearChannelInfo(datum)
 	}
 
-	countQuery := model.DB.Model(&model.Channel{})
+	countQuery := model.DB.Model(&model.Channel{}).Scopes(model.TenantScope(tenantScopeOf(c)))
 	if statusFilter == common.ChannelStatusEnabled {
 		countQuery = countQuery.Where("status = ?", common.ChannelStatusEnabled)
 	} else if statusFilter == 0 {
@@ -201,7 +206,7 @@ func FetchUpstreamModels(c *gin.Context) {
 		return
 	}
 
-	channel, err := model.GetChannelById(id, true)
+	channel, err := getTenantChannel(c, id, true)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -292,6 +297,21 @@ func FetchUpstreamModels(c *gin.Context) {
 		return
 	}
 
+	ids, err := fetchOpenAICompatibleModelIds(c.Request.Context(), channel, baseURL)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    ids,
+	})
+}
+
+// fetchOpenAICompatibleModelIds 通过渠道的 OpenAI 兼容 /models 接口获取上游模型 id 列表
+func fetchOpenAICompatibleModelIds(ctx context.Context, channel *model.Channel, baseURL string) ([]string, error) {
 	var url string
 	switch channel.Type {
 	case constant.ChannelTypeAli:
@@ -321,33 +341,23 @@ func FetchUpstreamModels(c *gin.Context) {
 	// 获取用于请求的可用密钥（多密钥渠道优先使用启用状态的密钥）
 	key, _, apiErr := channel.GetNextEnabledKey()
 	if apiErr != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("获取渠道密钥失败: %s", apiErr.Error()),
-		})
-		return
+		return nil, fmt.Errorf("获取渠道密钥失败: %s", apiErr.Error())
 	}
 	key = strings.TrimSpace(key)
 
 	headers, err := buildFetchModelsHeaders(channel, key)
 	if err != nil {
-		common.ApiError(c, err)
-		return
+		return nil, err
 	}
 
-	body, err := GetResponseBody("GET", url, channel, headers)
+	body, err := GetResponseBodyWithContext(ctx, "GET", url, channel, headers)
 	if err != nil {
-		common.ApiError(c, err)
-		return
+		return nil, err
 	}
 
 	var result OpenAIModelsResponse
 	if err = json.Unmarshal(body, &result); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("解析响应失败: %s", err.Error()),
-		})
-		return
+		return nil, fmt.Errorf("解析响应失败: %s", err.Error())
 	}
 
 	var ids []string
@@ -358,12 +368,7 @@ func FetchUpstreamModels(c *gin.Context) {
 		}
 		ids = append(ids, id)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    ids,
-	})
+	return ids, nil
 }
 
 func FixChannelsAbilities(c *gin.Context) {
@@ -420,6 +425,8 @@ func SearchChannels(c *gin.Context) {
 		channelData = channels
 	}
 
+	channelData = filterTenantChannels(c, channelData)
+
 	if statusFilter == common.ChannelStatusEnabled || statusFilter == 0 {
 		filtered := make([]*model.Channel, 0, len(channelData))
 		for _, ch := range channelData {
@@ -501,7 +508,7 @@ func GetChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	channel, err := model.GetChannelById(id, false)
+	channel, err := getTenantChannel(c, id, false)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -517,6 +524,31 @@ func GetChannel(c *gin.Context) {
 	return
 }
 
+// GetChannelUsage 获取渠道在时间范围内的请求数、token、额度、错误率与平均耗时
+func GetChannelUsage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end"), 10, 64)
+	if endTimestamp != 0 && startTimestamp > endTimestamp {
+		common.ApiErrorMsg(c, "开始时间不能晚于结束时间")
+		return
+	}
+	if _, err := getTenantChannel(c, id, false); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	usage, err := model.GetChannelUsage(id, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, usage)
+}
+
 // GetChannelKey 获取渠道密钥（需要通过安全验证中间件）
 // 此函数依赖 SecureVerificationRequired 中间件，确保用户已通过安全验证
 func GetChannelKey(c *gin.Context) {
@@ -569,6 +601,8 @@ func validateTwoFactorAuth(twoFA *model.TwoFA, code string) bool {
 	return false
 }
 
+var headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+
 // validateChannel 通用的渠道校验函数
 func validateChannel(channel *model.Channel, isAdd bool) error {
 	// 校验 channel settings
@@ -576,6 +610,20 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
 
+	if err := channel.ValidateBaseURLSecurity(); err != nil {
+		return err
+	}
+
+	if err := transformer.Validate(channel.GetSetting().Transformers); err != nil {
+		return fmt.Errorf("渠道额外设置[channel setting] 错误：%s", err.Error())
+	}
+
+	for key := range channel.GetSetting().AttributionHeaders {
+		if !headerNamePattern.MatchString(key) {
+			return fmt.Errorf("渠道额外设置[channel setting] 错误：来源标识头名称无效：%q", key)
+		}
+	}
+
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
 		if channel == nil || channel.Key == "" {
@@ -636,6 +684,11 @@ func RefreshCodexChannelCredential(c *gin.Context) {
 		return
 	}
 
+	if _, err := getTenantChannel(c, channelId, false); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
@@ -717,6 +770,7 @@ func AddChannel(c *gin.Context) {
 	}
 
 	addChannelRequest.Channel.CreatedTime = common.GetTimestamp()
+	addChannelRequest.Channel.TenantId = assignTenant(c, addChannelRequest.Channel.TenantId)
 	keys := make([]string, 0)
 	switch addChannelRequest.Mode {
 	case "multi_to_single":
@@ -801,6 +855,10 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getTenantChannel(c, id, false); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -816,7 +874,7 @@ func DeleteChannel(c *gin.Context) {
 }
 
 func DeleteDisabledChannel(c *gin.Context) {
-	rows, err := model.DeleteDisabledChannel()
+	rows, err := model.DeleteDisabledChannel(tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -852,7 +910,7 @@ func DisableTagChannels(c *gin.Context) {
 		})
 		return
 	}
-	err = model.DisableChannelByTag(channelTag.Tag)
+	err = model.DisableChannelByTag(channelTag.Tag, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -875,7 +933,7 @@ func EnableTagChannels(c *gin.Context) {
 		})
 		return
 	}
-	err = model.EnableChannelByTag(channelTag.Tag)
+	err = model.EnableChannelByTag(channelTag.Tag, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -927,7 +985,7 @@ func EditTagChannels(c *gin.Context) {
 		}
 		channelTag.HeaderOverride = common.GetPointer[string](trimmed)
 	}
-	err = model.EditChannelByTag(channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight, channelTag.ParamOverride, channelTag.HeaderOverride)
+	err = model.EditChannelByTag(tenantScopeOf(c), channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight, channelTag.ParamOverride, channelTag.HeaderOverride)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -955,6 +1013,10 @@ func DeleteChannelBatch(c *gin.Context) {
 		})
 		return
 	}
+	if err := checkTenantChannelIds(c, channelBatch.Ids); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	err = model.BatchDeleteChannels(channelBatch.Ids)
 	if err != nil {
 		common.ApiError(c, err)
@@ -992,7 +1054,7 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
-	originChannel, err := model.GetChannelById(channel.Id, true)
+	originChannel, err := getTenantChannel(c, channel.Id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -1000,6 +1062,10 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	// 只有超级管理员可以修改渠道所属租户
+	if tenantScopeOf(c) != model.AllTenants {
+		channel.TenantId = originChannel.TenantId
+	}
 
 	// Always copy the original ChannelInfo so that fields like IsMultiKey and MultiKeySize are retained.
 	channel.ChannelInfo = originChannel.ChannelInfo
@@ -1236,6 +1302,10 @@ func BatchSetChannelTag(c *gin.Context) {
 		})
 		return
 	}
+	if err := checkTenantChannelIds(c, channelBatch.Ids); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	err = model.BatchSetChannelTag(channelBatch.Ids, channelBatch.Tag)
 	if err != nil {
 		common.ApiError(c, err)
@@ -1268,6 +1338,7 @@ func GetTagModels(c *gin.Context) {
 		})
 		return
 	}
+	channels = filterTenantChannels(c, channels)
 
 	var longestModels string
 	maxLength := 0
@@ -1313,7 +1384,7 @@ func CopyChannel(c *gin.Context) {
 	}
 
 	// fetch original channel with key
-	origin, err := model.GetChannelById(id, true)
+	origin, err := getTenantChannel(c, id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
@@ -1381,7 +1452,7 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 	}
 
-	channel, err := model.GetChannelById(request.ChannelId, true)
+	channel, err := getTenantChannel(c, request.ChannelId, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -1855,7 +1926,7 @@ func OllamaPullModel(c *gin.Context) {
 	}
 
 	// 获取渠道信息
-	channel, err := model.GetChannelById(req.ChannelID, true)
+	channel, err := getTenantChannel(c, req.ChannelID, true)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -1918,7 +1989,7 @@ func OllamaPullModelStream(c *gin.Context) {
 	}
 
 	// 获取渠道信息
-	channel, err := model.GetChannelById(req.ChannelID, true)
+	channel, err := getTenantChannel(c, req.ChannelID, true)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -2000,7 +2071,7 @@ func OllamaDeleteModel(c *gin.Context) {
 	}
 
 	// 获取渠道信息
-	channel, err := model.GetChannelById(req.ChannelID, true)
+	channel, err := getTenantChannel(c, req.ChannelID, true)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -2050,7 +2121,7 @@ func OllamaVersion(c *gin.Context) {
 		return
 	}
 
-	channel, err := model.GetChannelById(id, true)
+	channel, err := getTenantChannel(c, id, true)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
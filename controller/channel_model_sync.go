@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/gemini"
+	"github.com/QuantumNous/new-api/relay/channel/ollama"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultChannelModelSyncConcurrency = 5
+	maxChannelModelSyncConcurrency     = 20
+	defaultChannelModelSyncTimeout     = 15 * time.Second
+)
+
+type ChannelModelSyncRequest struct {
+	Ids            []int  `json:"ids"` // 为空时按 tag 过滤，tag 也为空时处理全部渠道
+	Tag            string `json:"tag"`
+	ApplyRemovals  bool   `json:"apply_removals"` // 是否从渠道中移除上游已不提供的模型
+	Concurrency    int    `json:"concurrency"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+type ChannelModelSyncResult struct {
+	ChannelId   int      `json:"channel_id"`
+	ChannelName string   `json:"channel_name"`
+	Added       []string `json:"added"`   // 上游提供但渠道未配置的模型
+	Removed     []string `json:"removed"` // 渠道已配置但上游不再提供的模型
+	Applied     bool     `json:"applied"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type ChannelModelSyncReport struct {
+	Total     int                      `json:"total"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Changed   int                      `json:"changed"`
+	Results   []ChannelModelSyncResult `json:"results"`
+}
+
+// SyncChannelsModels 并发从上游拉取模型列表，与渠道配置对比后返回差异报告，可选地移除失效模型
+func SyncChannelsModels(c *gin.Context) {
+	var req ChannelModelSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	var channels []*model.Channel
+	var err error
+	switch {
+	case len(req.Ids) > 0:
+		if err = checkTenantChannelIds(c, req.Ids); err == nil {
+			channels, err = model.GetChannelsByIds(req.Ids)
+		}
+	case req.Tag != "":
+		channels, err = model.GetChannelsByTag(req.Tag, true, true)
+	default:
+		channels, err = model.GetAllChannels(0, 0, true, true)
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channels = filterTenantChannels(c, channels)
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultChannelModelSyncConcurrency
+	}
+	if concurrency > maxChannelModelSyncConcurrency {
+		concurrency = maxChannelModelSyncConcurrency
+	}
+	timeout := defaultChannelModelSyncTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+
+	report := syncChannelModels(channels, concurrency, timeout, fetchChannelUpstreamModelIds)
+	if req.ApplyRemovals {
+		applyChannelModelRemovals(channels, report)
+	}
+	common.ApiSuccess(c, report)
+}
+
+// fetchChannelUpstreamModelIds 按渠道类型从上游获取模型 id 列表，ctx 超时后中止请求
+func fetchChannelUpstreamModelIds(ctx context.Context, channel *model.Channel) ([]string, error) {
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() != "" {
+		baseURL = channel.GetBaseURL()
+	}
+	switch channel.Type {
+	case constant.ChannelTypeOllama:
+		key := strings.Split(channel.Key, "\n")[0]
+		models, err := ollama.FetchOllamaModelsWithContext(ctx, baseURL, key)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(models))
+		for _, m := range models {
+			ids = append(ids, m.Name)
+		}
+		return ids, nil
+	case constant.ChannelTypeGemini:
+		key, _, apiErr := channel.GetNextEnabledKey()
+		if apiErr != nil {
+			return nil, fmt.Errorf("获取渠道密钥失败: %s", apiErr.Error())
+		}
+		return gemini.FetchGeminiModelsWithContext(ctx, baseURL, strings.TrimSpace(key), channel.GetSetting().Proxy)
+	}
+	return fetchOpenAICompatibleModelIds(ctx, channel, baseURL)
+}
+
+func syncChannelModels(channels []*model.Channel, concurrency int, timeout time.Duration, fetch func(context.Context, *model.Channel) ([]string, error)) *ChannelModelSyncReport {
+	results := make([]ChannelModelSyncResult, len(channels))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, channel := range channels {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, channel *model.Channel) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = syncChannelModel(channel, timeout, fetch)
+		}(i, channel)
+	}
+	wg.Wait()
+
+	report := &ChannelModelSyncReport{Results: results}
+	report.tally()
+	return report
+}
+
+func (report *ChannelModelSyncReport) tally() {
+	report.Total, report.Succeeded, report.Failed, report.Changed = len(report.Results), 0, 0, 0
+	for _, result := range report.Results {
+		if result.Error != "" {
+			report.Failed++
+			continue
+		}
+		report.Succeeded++
+		if len(result.Added) > 0 || len(result.Removed) > 0 {
+			report.Changed++
+		}
+	}
+}
+
+// syncChannelModel 获取单个渠道的上游模型并计算差异，超时后取消对该渠道的请求
+func syncChannelModel(channel *model.Channel, timeout time.Duration, fetch func(context.Context, *model.Channel) ([]string, error)) ChannelModelSyncResult {
+	result := ChannelModelSyncResult{ChannelId: channel.Id, ChannelName: channel.Name, Added: []string{}, Removed: []string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ids, err := fetch(ctx, channel)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Error = fmt.Sprintf("获取上游模型超时（%s）", timeout)
+		return result
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(ids) == 0 {
+		// 上游返回空列表时不做移除，避免误删全部模型
+		result.Error = "上游返回的模型列表为空"
+		return result
+	}
+
+	upstream := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		upstream[id] = struct{}{}
+	}
+	mapping := map[string]string{}
+	if modelMapping := channel.GetModelMapping(); modelMapping != "" && modelMapping != "{}" {
+		_ = common.UnmarshalJsonStr(modelMapping, &mapping)
+	}
+	configured := make(map[string]struct{})
+	for _, name := range channel.GetModels() {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		upstreamName := name
+		if mapped, ok := mapping[name]; ok && mapped != "" {
+			upstreamName = mapped
+		}
+		configured[upstreamName] = struct{}{}
+		if _, ok := upstream[upstreamName]; !ok {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	for _, id := range ids {
+		if _, ok := configured[id]; !ok {
+			result.Added = append(result.Added, id)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	return result
+}
+
+// applyChannelModelRemovals 从渠道中移除上游不再提供的模型，并同步 abilities
+func applyChannelModelRemovals(channels []*model.Channel, report *ChannelModelSyncReport) {
+	channelMap := make(map[int]*model.Channel, len(channels))
+	for _, channel := range channels {
+		channelMap[channel.Id] = channel
+	}
+	applied := false
+	for i := range report.Results {
+		result := &report.Results[i]
+		channel := channelMap[result.ChannelId]
+		if result.Error != "" || len(result.Removed) == 0 || channel == nil {
+			continue
+		}
+		removed := make(map[string]struct{}, len(result.Removed))
+		for _, name := range result.Removed {
+			removed[name] = struct{}{}
+		}
+		kept := make([]string, 0)
+		for _, name := range channel.GetModels() {
+			if _, ok := removed[strings.TrimSpace(name)]; !ok {
+				kept = append(kept, name)
+			}
+		}
+		if err := model.UpdateChannelModels(channel, kept); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Applied = true
+		applied = true
+	}
+	if applied {
+		model.InitChannelCache()
+	}
+	report.tally()
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncChannelModels_MixedUpstreams(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/models", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4.1"},{"id":"gpt-4o-2024-08-06"}]}`))
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	slowDone := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-slowDone:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	defer close(slowDone)
+
+	newChannel := func(id int, baseURL string, models string, mapping string) *model.Channel {
+		return &model.Channel{Id: id, Name: "ch", Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: common.GetPointer(baseURL), Models: models, ModelMapping: common.GetPointer(mapping)}
+	}
+	channels := []*model.Channel{
+		newChannel(1, healthy.URL, "gpt-4o,gpt-3.5-turbo,gpt-4o-latest", `{"gpt-4o-latest":"gpt-4o-2024-08-06"}`),
+		newChannel(2, broken.URL, "gpt-4o", ""),
+		newChannel(3, slow.URL, "gpt-4o", ""),
+	}
+
+	// 超时后取消上游请求，慢渠道的请求结束后才返回报告
+	start := time.Now()
+	report := syncChannelModels(channels, 2, 300*time.Millisecond, fetchChannelUpstreamModelIds)
+	require.Less(t, time.Since(start), 3*time.Second)
+	require.Equal(t, 3, report.Total)
+	require.Equal(t, 1, report.Succeeded)
+	require.Equal(t, 2, report.Failed)
+	require.Equal(t, 1, report.Changed)
+
+	ok := report.Results[0]
+	require.Empty(t, ok.Error)
+	require.Equal(t, []string{"gpt-3.5-turbo"}, ok.Removed)
+	require.Equal(t, []string{"gpt-4.1"}, ok.Added)
+	require.False(t, ok.Applied)
+
+	require.Contains(t, report.Results[1].Error, "500")
+	require.Contains(t, report.Results[2].Error, "超时")
+}
@@ -74,7 +74,7 @@ func StartCodexOAuthForChannel(c *gin.Context) {
 
 func startCodexOAuthWithChannelID(c *gin.Context, channelID int) {
 	if channelID > 0 {
-		ch, err := model.GetChannelById(channelID, false)
+		ch, err := getTenantChannel(c, channelID, false)
 		if err != nil {
 			common.ApiError(c, err)
 			return
@@ -145,7 +145,7 @@ func completeCodexOAuthWithChannelID(c *gin.Context, channelID int) {
 	}
 
 	if channelID > 0 {
-		ch, err := model.GetChannelById(channelID, false)
+		ch, err := getTenantChannel(c, channelID, false)
 		if err != nil {
 			common.ApiError(c, err)
 			return
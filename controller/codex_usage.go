@@ -25,7 +25,7 @@ func GetCodexChannelUsage(c *gin.Context) {
 		return
 	}
 
-	ch, err := model.GetChannelById(channelId, true)
+	ch, err := getTenantChannel(c, channelId, true)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"sort"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
@@ -50,3 +54,65 @@ func GetUserGroups(c *gin.Context) {
 		"data":    usableGroups,
 	})
 }
+
+// GroupEndpointQuotaItem 分组接口额度的上限与已用额度
+type GroupEndpointQuotaItem struct {
+	Group     string `json:"group"`
+	Endpoint  string `json:"endpoint"`
+	Limit     int64  `json:"limit"`
+	UsedQuota int64  `json:"used_quota"`
+}
+
+// GetGroupEndpointQuotas 列出所有已配置的分组接口额度及其用量
+func GetGroupEndpointQuotas(c *gin.Context) {
+	usages, err := model.GetAllGroupEndpointQuotaUsages()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	used := make(map[string]int64, len(usages))
+	for _, usage := range usages {
+		used[usage.Group+"|"+usage.Endpoint] = usage.UsedQuota
+	}
+	items := make([]GroupEndpointQuotaItem, 0)
+	for group, limits := range operation_setting.GetGroupEndpointQuotaSetting().Limits {
+		for endpoint, limit := range limits {
+			items = append(items, GroupEndpointQuotaItem{
+				Group:     group,
+				Endpoint:  endpoint,
+				Limit:     limit,
+				UsedQuota: used[group+"|"+endpoint],
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Group != items[j].Group {
+			return items[i].Group < items[j].Group
+		}
+		return items[i].Endpoint < items[j].Endpoint
+	})
+	common.ApiSuccess(c, items)
+}
+
+type resetGroupEndpointQuotaRequest struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+}
+
+// ResetGroupEndpointQuota 清零分组接口的已用额度
+func ResetGroupEndpointQuota(c *gin.Context) {
+	var req resetGroupEndpointQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Group == "" || req.Endpoint == "" {
+		common.ApiError(c, errors.New("分组和接口不能为空"))
+		return
+	}
+	if err := model.ResetGroupEndpointQuota(req.Group, req.Endpoint); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
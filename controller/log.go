@@ -6,6 +6,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,11 +21,12 @@ func GetAllLogs(c *gin.Context) {
 	modelName := c.Query("model_name")
 	channel, _ := strconv.Atoi(c.Query("channel"))
 	group := c.Query("group")
-	logs, total, err := model.GetAllLogs(logType, startTimestamp, endTimestamp, modelName, username, tokenName, pageInfo.GetStartIdx(), pageInfo.GetPageSize(), channel, group)
+	logs, total, err := model.GetAllLogs(logType, startTimestamp, endTimestamp, modelName, username, tokenName, pageInfo.GetStartIdx(), pageInfo.GetPageSize(), channel, group, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillLogCost(logs)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(logs)
 	common.ApiSuccess(c, pageInfo)
@@ -45,6 +47,7 @@ func GetUserLogs(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillLogCost(logs)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(logs)
 	common.ApiSuccess(c, pageInfo)
@@ -53,11 +56,12 @@ func GetUserLogs(c *gin.Context) {
 
 func SearchAllLogs(c *gin.Context) {
 	keyword := c.Query("keyword")
-	logs, err := model.SearchAllLogs(keyword)
+	logs, err := model.SearchAllLogs(keyword, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillLogCost(logs)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -74,6 +78,7 @@ func SearchUserLogs(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.FillLogCost(logs)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -92,6 +97,7 @@ func GetLogByKey(c *gin.Context) {
 		})
 		return
 	}
+	model.FillLogCost(logs)
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "",
@@ -108,7 +114,7 @@ func GetLogsStat(c *gin.Context) {
 	modelName := c.Query("model_name")
 	channel, _ := strconv.Atoi(c.Query("channel"))
 	group := c.Query("group")
-	stat := model.SumUsedQuota(logType, startTimestamp, endTimestamp, modelName, username, tokenName, channel, group)
+	stat := model.SumUsedQuota(logType, startTimestamp, endTimestamp, modelName, username, tokenName, channel, group, tenantScopeOf(c))
 	//tokenNum := model.SumUsedToken(logType, startTimestamp, endTimestamp, modelName, username, "")
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -131,7 +137,7 @@ func GetLogsSelfStat(c *gin.Context) {
 	modelName := c.Query("model_name")
 	channel, _ := strconv.Atoi(c.Query("channel"))
 	group := c.Query("group")
-	quotaNum := model.SumUsedQuota(logType, startTimestamp, endTimestamp, modelName, username, tokenName, channel, group)
+	quotaNum := model.SumUsedQuota(logType, startTimestamp, endTimestamp, modelName, username, tokenName, channel, group, model.AllTenants)
 	//tokenNum := model.SumUsedToken(logType, startTimestamp, endTimestamp, modelName, username, tokenName)
 	c.JSON(200, gin.H{
 		"success": true,
@@ -155,7 +161,7 @@ func DeleteHistoryLogs(c *gin.Context) {
 		})
 		return
 	}
-	count, err := model.DeleteOldLog(c.Request.Context(), targetTimestamp, 100)
+	count, err := model.DeleteOldLog(c.Request.Context(), targetTimestamp, 100, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -167,3 +173,20 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// GetRequestDiagnostics 根据请求 id 生成诊断报告，包括渠道可选情况、上游错误与计费结果
+func GetRequestDiagnostics(c *gin.Context) {
+	report, err := service.BuildRequestDiagnostics(c.Param("request_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if tenantScopeOf(c) != model.AllTenants {
+		user, err := model.GetUserById(report.UserId, false)
+		if err != nil || !tenantAccessible(c, user.TenantId) {
+			common.ApiErrorMsg(c, "未找到该请求的日志，可能未开启日志记录或日志已被清理")
+			return
+		}
+	}
+	common.ApiSuccess(c, report)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/moonshot"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -133,6 +134,9 @@ func ListModels(c *gin.Context, modelType int) {
 			tokenModelLimit = map[string]bool{}
 		}
 		for allowModel, _ := range tokenModelLimit {
+			if model.IsModelEndpointsDisabled(allowModel) {
+				continue
+			}
 			if !acceptUnsetRatioModel {
 				_, _, exist := ratio_setting.GetModelRatioOrPrice(allowModel)
 				if !exist {
@@ -181,6 +185,9 @@ func ListModels(c *gin.Context, modelType int) {
 			models = model.GetGroupEnabledModels(group)
 		}
 		for _, modelName := range models {
+			if model.IsModelEndpointsDisabled(modelName) {
+				continue
+			}
 			if !acceptUnsetRatioModel {
 				_, _, exist := ratio_setting.GetModelRatioOrPrice(modelName)
 				if !exist {
@@ -202,6 +209,8 @@ func ListModels(c *gin.Context, modelType int) {
 		}
 	}
 
+	applyModelDeprecation(userOpenAiModels)
+
 	switch modelType {
 	case constant.ChannelTypeAnthropic:
 		useranthropicModels := make([]dto.AnthropicModel, len(userOpenAiModels))
@@ -240,6 +249,19 @@ func ListModels(c *gin.Context, modelType int) {
 	}
 }
 
+// applyModelDeprecation 为已废弃的模型填充下线信息
+func applyModelDeprecation(models []dto.OpenAIModels) {
+	for i := range models {
+		deprecation, ok := model_setting.GetModelDeprecation(models[i].Id)
+		if !ok {
+			continue
+		}
+		models[i].Deprecated = true
+		models[i].SunsetDate = deprecation.SunsetDate
+		models[i].Replacement = deprecation.Replacement
+	}
+}
+
 func ChannelListModels(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"success": true,
@@ -273,7 +295,9 @@ func RetrieveModel(c *gin.Context, modelType int) {
 				Type:        "model",
 			})
 		default:
-			c.JSON(200, aiModel)
+			models := []dto.OpenAIModels{aiModel}
+			applyModelDeprecation(models)
+			c.JSON(200, models[0])
 		}
 	} else {
 		openAIError := types.OpenAIError{
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListModels_CarriesDeprecationMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetModelDeprecationSettings()
+	origModels := settings.Models
+	origSelfUse := operation_setting.SelfUseModeEnabled
+	t.Cleanup(func() {
+		settings.Models = origModels
+		operation_setting.SelfUseModeEnabled = origSelfUse
+	})
+	settings.Models = map[string]model_setting.ModelDeprecation{
+		"old-model": {Deprecated: true, SunsetDate: "2026-12-01", Replacement: "new-model"},
+	}
+	operation_setting.SelfUseModeEnabled = true
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, map[string]bool{"old-model": true, "new-model": true})
+	ListModels(c, constant.ChannelTypeOpenAI)
+
+	var resp struct {
+		Data []dto.OpenAIModels `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	for _, m := range resp.Data {
+		switch m.Id {
+		case "old-model":
+			require.True(t, m.Deprecated)
+			require.Equal(t, "2026-12-01", m.SunsetDate)
+			require.Equal(t, "new-model", m.Replacement)
+		case "new-model":
+			require.False(t, m.Deprecated)
+			require.Empty(t, m.SunsetDate)
+		}
+	}
+}
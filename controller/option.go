@@ -196,6 +196,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "token_setting.name_pattern":
+		err = operation_setting.ValidateTokenNamePattern(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.api_info":
 		err = console_setting.ValidateConsoleSettings(option.Value.(string), "ApiInfo")
 		if err != nil {
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -9,7 +10,7 @@ import (
 )
 
 func GetPricing(c *gin.Context) {
-	pricing := model.GetPricing()
+	pricing := model.FilterPricingByEnabledEndpoints(model.GetPricing())
 	userId, exists := c.Get("id")
 	usableGroup := map[string]string{}
 	groupRatio := map[string]float64{}
@@ -49,6 +50,11 @@ func GetPricing(c *gin.Context) {
 	})
 }
 
+// GetScheduledRatios 获取尚未生效的定时倍率调整
+func GetScheduledRatios(c *gin.Context) {
+	common.ApiSuccess(c, ratio_setting.GetUpcomingScheduledRatios(common.GetTimestamp()))
+}
+
 func ResetModelRatio(c *gin.Context) {
 	defaultStr := ratio_setting.DefaultModelRatio2JSONString()
 	err := model.UpdateOption("ModelRatio", defaultStr)
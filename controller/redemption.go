@@ -2,6 +2,7 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"strconv"
@@ -10,7 +11,9 @@ import (
 	"unicode/utf8"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,7 +30,7 @@ func init() {
 
 func GetAllRedemptions(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
-	redemptions, total, err := model.GetAllRedemptions(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	redemptions, total, err := model.GetAllRedemptions(tenantScopeOf(c), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -41,7 +44,7 @@ func GetAllRedemptions(c *gin.Context) {
 func SearchRedemptions(c *gin.Context) {
 	keyword := c.Query("keyword")
 	pageInfo := common.GetPageQuery(c)
-	redemptions, total, err := model.SearchRedemptions(keyword, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	redemptions, total, err := model.SearchRedemptions(tenantScopeOf(c), keyword, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -58,7 +61,7 @@ func GetRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	redemption, err := model.GetRedemptionById(id)
+	redemption, err := getTenantRedemption(c, id)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -71,110 +74,146 @@ func GetRedemption(c *gin.Context) {
 	return
 }
 
-func AddRedemption(c *gin.Context) {
-	type RedemptionRequest struct {
-		Name        string `json:"name"`
-		Count       int    `json:"count"`
-		Quota       int    `json:"quota"`
-		ExpiredTime int64  `json:"expired_time"`
-		RandomMode  bool   `json:"random_mode"`
-		MinQuota    int    `json:"min_quota"`
-		MaxQuota    int    `json:"max_quota"`
-	}
+// redemptionBatchParams 批量生成兑换码的参数
+type redemptionBatchParams struct {
+	Name        string `json:"name"`
+	Count       int    `json:"count"`
+	Quota       int    `json:"quota"`
+	ExpiredTime int64  `json:"expired_time"`
+	RandomMode  bool   `json:"random_mode"`
+	MinQuota    int    `json:"min_quota"`
+	MaxQuota    int    `json:"max_quota"`
+	KeyPrefix   string `json:"key_prefix"`
+	MaxUses     int    `json:"max_uses"`  // 可被多少个不同用户兑换
+	TenantId    int    `json:"tenant_id"` // 所属租户，仅超级管理员可指定
+}
 
-	var reqData RedemptionRequest
-	if err := c.ShouldBindJSON(&reqData); err != nil {
-		common.ApiError(c, err)
-		return
+func validateRedemptionBatch(params redemptionBatchParams) error {
+	if utf8.RuneCountInString(params.Name) == 0 || utf8.RuneCountInString(params.Name) > 20 {
+		return errors.New("兑换码名称长度必须在1-20之间")
 	}
-
-	if utf8.RuneCountInString(reqData.Name) == 0 || utf8.RuneCountInString(reqData.Name) > 20 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "兑换码名称长度必须在1-20之间",
-		})
-		return
+	if params.Count <= 0 {
+		return errors.New("兑换码个数必须大于0")
 	}
-	if reqData.Count <= 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "兑换码个数必须大于0",
-		})
-		return
+	if params.Count > 100 {
+		return errors.New("一次兑换码批量生成的个数不能大于 100")
 	}
-	if reqData.Count > 100 {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "一次兑换码批量生成的个数不能大于 100",
-		})
-		return
+	if params.MaxUses < 1 {
+		return errors.New("兑换码可使用次数必须大于等于1")
+	}
+	if err := validateRedemptionQuotaParams(params); err != nil {
+		return err
 	}
+	if err := model.ValidateRedemptionKeyPrefix(params.KeyPrefix); err != nil {
+		return err
+	}
+	return validateExpiredTime(params.ExpiredTime)
+}
 
+// validateRedemptionQuotaParams 校验固定额度或随机额度参数
+func validateRedemptionQuotaParams(params redemptionBatchParams) error {
 	// 验证随机模式参数
-	if reqData.RandomMode {
-		if reqData.MinQuota <= 0 || reqData.MaxQuota <= 0 {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "随机模式下最小额度和最大额度必须大于0",
-			})
-			return
+	if params.RandomMode {
+		if params.MinQuota <= 0 || params.MaxQuota <= 0 {
+			return errors.New("随机模式下最小额度和最大额度必须大于0")
 		}
-		if reqData.MinQuota >= reqData.MaxQuota {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "最小额度必须小于最大额度",
-			})
-			return
+		if params.MinQuota >= params.MaxQuota {
+			return errors.New("最小额度必须小于最大额度")
 		}
 	} else {
-		if reqData.Quota <= 0 {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "固定模式下额度必须大于0",
-			})
-			return
+		if params.Quota <= 0 {
+			return errors.New("固定模式下额度必须大于0")
 		}
 	}
 
-	if err := validateExpiredTime(reqData.ExpiredTime); err != nil {
-		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
-		return
+	// 随机模式下按最大额度校验，保证生成的每个兑换码都不超过上限
+	maxQuota := params.Quota
+	if params.RandomMode {
+		maxQuota = params.MaxQuota
+	}
+	return validateRedemptionQuota(maxQuota)
+}
+
+// nextRedemptionQuota 返回下一个兑换码的额度，随机模式下在 [MinQuota, MaxQuota] 内均匀取值（线程安全）
+func nextRedemptionQuota(params redemptionBatchParams) int {
+	if !params.RandomMode {
+		return params.Quota
+	}
+	rngMux.Lock()
+	defer rngMux.Unlock()
+	return rng.Intn(params.MaxQuota-params.MinQuota+1) + params.MinQuota
+}
+
+// getTenantRedemption 获取兑换码，其他租户的兑换码按不存在处理
+func getTenantRedemption(c *gin.Context, id int) (*model.Redemption, error) {
+	redemption, err := model.GetRedemptionById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !tenantAccessible(c, redemption.TenantId) {
+		return nil, errors.New("兑换码不存在")
+	}
+	return redemption, nil
+}
+
+// getTenantRedemptionTemplate 获取兑换码模板，其他租户的模板按不存在处理
+func getTenantRedemptionTemplate(c *gin.Context, id int) (*model.RedemptionTemplate, error) {
+	template, err := model.GetRedemptionTemplateById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !tenantAccessible(c, template.TenantId) {
+		return nil, errors.New("兑换码模板不存在")
+	}
+	return template, nil
+}
+
+// generateRedemptionBatch 校验参数并批量生成兑换码，返回生成的兑换码
+func generateRedemptionBatch(userId int, tenantId int, params redemptionBatchParams) ([]string, error) {
+	if err := validateRedemptionBatch(params); err != nil {
+		return nil, err
 	}
 
 	// 批量生成兑换码数据
 	var redemptions []model.Redemption
 	var keys []string
-	userId := c.GetInt("id")
 	createdTime := common.GetTimestamp()
 
-	for i := 0; i < reqData.Count; i++ {
-		key := common.GetUUID()
-		quota := reqData.Quota
-
-		// 随机模式生成随机额度（线程安全）
-		if reqData.RandomMode {
-			rngMux.Lock()
-			quota = rng.Intn(reqData.MaxQuota-reqData.MinQuota+1) + reqData.MinQuota
-			rngMux.Unlock()
-		}
+	for i := 0; i < params.Count; i++ {
+		key := model.NewRedemptionKey(params.KeyPrefix)
+		quota := nextRedemptionQuota(params)
 
 		redemptions = append(redemptions, model.Redemption{
 			UserId:      userId,
-			Name:        reqData.Name,
+			Name:        params.Name,
 			Key:         key,
 			CreatedTime: createdTime,
 			Quota:       quota,
-			ExpiredTime: reqData.ExpiredTime,
+			ExpiredTime: params.ExpiredTime,
+			MaxUses:     params.MaxUses,
+			TenantId:    tenantId,
 		})
 		keys = append(keys, key)
 	}
 
 	// 批量插入数据库
-	if err := model.DB.CreateInBatches(redemptions, 50).Error; err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
+	if err := model.BatchInsertRedemptions(redemptions); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func AddRedemption(c *gin.Context) {
+	// 未指定 max_uses 时为单次使用
+	reqData := redemptionBatchParams{MaxUses: 1}
+	if err := c.ShouldBindJSON(&reqData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	keys, err := generateRedemptionBatch(c.GetInt("id"), assignTenant(c, reqData.TenantId), reqData)
+	if err != nil {
+		common.ApiError(c, err)
 		return
 	}
 
@@ -188,6 +227,10 @@ func AddRedemption(c *gin.Context) {
 
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getTenantRedemption(c, id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	err := model.DeleteRedemptionById(id)
 	if err != nil {
 		common.ApiError(c, err)
@@ -208,7 +251,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
-	cleanRedemption, err := model.GetRedemptionById(redemption.Id)
+	cleanRedemption, err := getTenantRedemption(c, redemption.Id)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -218,6 +261,10 @@ func UpdateRedemption(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 			return
 		}
+		if err := validateRedemptionQuota(redemption.Quota); err != nil {
+			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+			return
+		}
 		// If you add more fields, please also update redemption.Update()
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
@@ -240,7 +287,7 @@ func UpdateRedemption(c *gin.Context) {
 }
 
 func DeleteInvalidRedemption(c *gin.Context) {
-	rows, err := model.DeleteInvalidRedemptions()
+	rows, err := model.DeleteInvalidRedemptions(tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -253,6 +300,292 @@ func DeleteInvalidRedemption(c *gin.Context) {
 	return
 }
 
+// GetRedemptionStats 获取兑换码累计统计
+func GetRedemptionStats(c *gin.Context) {
+	if !globalStatsAccessible(c) {
+		common.ApiErrorMsg(c, "仅超级管理员可查看全局兑换码统计")
+		return
+	}
+	stats, err := model.GetRedemptionStats()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
+
+// ReconcileRedemptionStats 按兑换码表重新计算累计统计
+func ReconcileRedemptionStats(c *gin.Context) {
+	if !globalStatsAccessible(c) {
+		common.ApiErrorMsg(c, "仅超级管理员可查看全局兑换码统计")
+		return
+	}
+	stats, err := model.ReconcileRedemptionStats()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
+
+// parseLiabilityAsOf 解析 as_of 参数，支持 unix 秒或 2006-01-02（取当天结束时刻），为空时取当前时间
+func parseLiabilityAsOf(value string) (int64, error) {
+	if value == "" {
+		return common.GetTimestamp(), nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return 0, errors.New("as_of 格式错误，应为 unix 时间戳或 2006-01-02")
+	}
+	return day.AddDate(0, 0, 1).Unix() - 1, nil
+}
+
+// GetRedemptionLiability 截至 as_of 的兑换码额度负债，group_by=campaign 时按兑换码名称分组
+func GetRedemptionLiability(c *gin.Context) {
+	if !globalStatsAccessible(c) {
+		common.ApiErrorMsg(c, "仅超级管理员可查看全局兑换码统计")
+		return
+	}
+	asOf, err := parseLiabilityAsOf(c.Query("as_of"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "campaign" {
+		common.ApiErrorMsg(c, "group_by 仅支持 campaign")
+		return
+	}
+	total, err := model.GetRedemptionLiability(asOf, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	data := gin.H{
+		"as_of": asOf,
+		"total": total[0],
+	}
+	if groupBy == "campaign" {
+		campaigns, err := model.GetRedemptionLiability(asOf, true)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		data["campaigns"] = campaigns
+	}
+	common.ApiSuccess(c, data)
+}
+
+const (
+	redemptionSimulateDefaultSamples = 10000
+	redemptionSimulateMaxSamples     = 1000000
+	redemptionSimulateDefaultBuckets = 10
+	redemptionSimulateMaxBuckets     = 100
+)
+
+// redemptionQuotaBucket 模拟额度分布的一个区间，包含 Min 与 Max
+type redemptionQuotaBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// redemptionQuotaHistogram 模拟生成的额度分布
+type redemptionQuotaHistogram struct {
+	Samples    int                     `json:"samples"`
+	Min        int                     `json:"min"`
+	Max        int                     `json:"max"`
+	Mean       float64                 `json:"mean"`
+	TotalQuota int64                   `json:"total_quota"`
+	Buckets    []redemptionQuotaBucket `json:"buckets"`
+}
+
+// simulateRedemptionQuotas 按生成兑换码的额度逻辑抽样 samples 次，统计到等宽区间中
+func simulateRedemptionQuotas(params redemptionBatchParams, samples int, buckets int) redemptionQuotaHistogram {
+	low, high := params.Quota, params.Quota
+	if params.RandomMode {
+		low, high = params.MinQuota, params.MaxQuota
+	}
+	width := (high - low + buckets) / buckets
+	histogram := redemptionQuotaHistogram{Samples: samples, Min: high, Max: low}
+	for i := 0; i*width <= high-low; i++ {
+		histogram.Buckets = append(histogram.Buckets, redemptionQuotaBucket{
+			Min: low + i*width,
+			Max: min(low+(i+1)*width-1, high),
+		})
+	}
+	for i := 0; i < samples; i++ {
+		quota := nextRedemptionQuota(params)
+		histogram.Buckets[(quota-low)/width].Count++
+		histogram.TotalQuota += int64(quota)
+		histogram.Min = min(histogram.Min, quota)
+		histogram.Max = max(histogram.Max, quota)
+	}
+	histogram.Mean = float64(histogram.TotalQuota) / float64(samples)
+	return histogram
+}
+
+// SimulateRedemptionDistribution 模拟生成兑换码的额度分布，不创建兑换码
+func SimulateRedemptionDistribution(c *gin.Context) {
+	var req struct {
+		redemptionBatchParams
+		Samples int `json:"samples"`
+		Buckets int `json:"buckets"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Samples == 0 {
+		req.Samples = redemptionSimulateDefaultSamples
+	}
+	if req.Buckets == 0 {
+		req.Buckets = redemptionSimulateDefaultBuckets
+	}
+	if req.Samples < 0 || req.Samples > redemptionSimulateMaxSamples {
+		common.ApiErrorMsg(c, fmt.Sprintf("模拟次数必须在1-%d之间", redemptionSimulateMaxSamples))
+		return
+	}
+	if req.Buckets < 0 || req.Buckets > redemptionSimulateMaxBuckets {
+		common.ApiErrorMsg(c, fmt.Sprintf("分组数必须在1-%d之间", redemptionSimulateMaxBuckets))
+		return
+	}
+	if err := validateRedemptionQuotaParams(req.redemptionBatchParams); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, simulateRedemptionQuotas(req.redemptionBatchParams, req.Samples, req.Buckets))
+}
+
+// redemptionTemplateBatchParams 将模板转换为在 now 生成时的批量参数
+func redemptionTemplateBatchParams(template *model.RedemptionTemplate, now int64) redemptionBatchParams {
+	return redemptionBatchParams{
+		Name:        template.Campaign,
+		Count:       template.Count,
+		Quota:       template.Quota,
+		ExpiredTime: template.ExpiredTimeAt(now),
+		RandomMode:  template.RandomMode,
+		MinQuota:    template.MinQuota,
+		MaxQuota:    template.MaxQuota,
+		KeyPrefix:   template.KeyPrefix,
+		MaxUses:     template.MaxUses,
+	}
+}
+
+func validateRedemptionTemplate(template *model.RedemptionTemplate) error {
+	if utf8.RuneCountInString(template.Name) == 0 || utf8.RuneCountInString(template.Name) > 64 {
+		return errors.New("模板名称长度必须在1-64之间")
+	}
+	if template.ExpiresInSeconds < 0 {
+		return errors.New("过期时长不能为负数")
+	}
+	return validateRedemptionBatch(redemptionTemplateBatchParams(template, common.GetTimestamp()))
+}
+
+// GetAllRedemptionTemplates 获取全部兑换码模板
+func GetAllRedemptionTemplates(c *gin.Context) {
+	templates, err := model.GetAllRedemptionTemplates(tenantScopeOf(c))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, templates)
+}
+
+// AddRedemptionTemplate 保存兑换码模板
+func AddRedemptionTemplate(c *gin.Context) {
+	template := model.RedemptionTemplate{MaxUses: 1}
+	if err := c.ShouldBindJSON(&template); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	template.Id = 0
+	template.TenantId = assignTenant(c, template.TenantId)
+	if err := validateRedemptionTemplate(&template); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := template.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, template)
+}
+
+// UpdateRedemptionTemplate 更新兑换码模板
+func UpdateRedemptionTemplate(c *gin.Context) {
+	template := model.RedemptionTemplate{MaxUses: 1}
+	if err := c.ShouldBindJSON(&template); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanTemplate, err := getTenantRedemptionTemplate(c, template.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateRedemptionTemplate(&template); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// If you add more fields, please also update template.Update()
+	cleanTemplate.Name = template.Name
+	cleanTemplate.Campaign = template.Campaign
+	cleanTemplate.Count = template.Count
+	cleanTemplate.Quota = template.Quota
+	cleanTemplate.RandomMode = template.RandomMode
+	cleanTemplate.MinQuota = template.MinQuota
+	cleanTemplate.MaxQuota = template.MaxQuota
+	cleanTemplate.ExpiresInSeconds = template.ExpiresInSeconds
+	cleanTemplate.KeyPrefix = template.KeyPrefix
+	cleanTemplate.MaxUses = template.MaxUses
+	if err := cleanTemplate.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanTemplate)
+}
+
+// DeleteRedemptionTemplate 删除兑换码模板，已生成的兑换码不受影响
+func DeleteRedemptionTemplate(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getTenantRedemptionTemplate(c, id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteRedemptionTemplateById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GenerateRedemptionsFromTemplate 按模板批量生成兑换码，过期时间从生成时刻起算
+func GenerateRedemptionsFromTemplate(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	template, err := getTenantRedemptionTemplate(c, id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	keys, err := generateRedemptionBatch(c.GetInt("id"), template.TenantId, redemptionTemplateBatchParams(template, common.GetTimestamp()))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, keys)
+}
+
+func validateRedemptionQuota(quota int) error {
+	if operation_setting.RedemptionQuotaExceedsLimit(quota) {
+		return fmt.Errorf("单个兑换码额度不能超过 %s", logger.LogQuota(operation_setting.GetRedemptionSetting().MaxQuotaPerCode))
+	}
+	return nil
+}
+
 func validateExpiredTime(expired int64) error {
 	if expired != 0 && expired < common.GetTimestamp() {
 		return errors.New("过期时间不能早于当前时间")
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedemptionTemplate_SaveAndGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.Redemption{}, &model.RedemptionStats{}, &model.RedemptionTemplate{})
+
+	body, err := json.Marshal(model.RedemptionTemplate{
+		Name:             "spring-promo",
+		Campaign:         "spring",
+		Count:            3,
+		Quota:            5000,
+		ExpiresInSeconds: 86400,
+		KeyPrefix:        "SPR-",
+		MaxUses:          2,
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/redemption/template/", bytes.NewReader(body))
+	AddRedemptionTemplate(c)
+	var saved struct {
+		Success bool                     `json:"success"`
+		Message string                   `json:"message"`
+		Data    model.RedemptionTemplate `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	require.True(t, saved.Success, saved.Message)
+	require.NotZero(t, saved.Data.Id)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/redemption/template/1/generate", nil)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	c.Set("id", 7)
+	before := common.GetTimestamp()
+	GenerateRedemptionsFromTemplate(c)
+	var generated struct {
+		Success bool     `json:"success"`
+		Message string   `json:"message"`
+		Data    []string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &generated))
+	require.True(t, generated.Success, generated.Message)
+	require.Len(t, generated.Data, 3)
+
+	var redemptions []model.Redemption
+	require.NoError(t, model.DB.Find(&redemptions).Error)
+	require.Len(t, redemptions, 3)
+	for _, redemption := range redemptions {
+		require.Equal(t, 7, redemption.UserId)
+		require.Equal(t, "spring", redemption.Name)
+		require.Equal(t, 5000, redemption.Quota)
+		require.Equal(t, 2, redemption.MaxUses)
+		require.Len(t, redemption.Key, 32)
+		require.True(t, strings.HasPrefix(redemption.Key, "SPR-"))
+		require.GreaterOrEqual(t, redemption.ExpiredTime, before+86400)
+		require.LessOrEqual(t, redemption.ExpiredTime, common.GetTimestamp()+86400)
+	}
+}
+
+func TestSimulateRedemptionDistribution_MatchesUniformRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.Redemption{})
+
+	const samples = 200000
+	body := []byte(`{"random_mode":true,"min_quota":1,"max_quota":1000,"samples":200000,"buckets":10}`)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/redemption/simulate", bytes.NewReader(body))
+	SimulateRedemptionDistribution(c)
+	var resp struct {
+		Success bool                     `json:"success"`
+		Message string                   `json:"message"`
+		Data    redemptionQuotaHistogram `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.True(t, resp.Success, resp.Message)
+
+	histogram := resp.Data
+	require.Equal(t, samples, histogram.Samples)
+	require.GreaterOrEqual(t, histogram.Min, 1)
+	require.LessOrEqual(t, histogram.Max, 1000)
+	require.InDelta(t, 500.5, histogram.Mean, 5)
+	require.Len(t, histogram.Buckets, 10)
+	total := 0
+	for i, bucket := range histogram.Buckets {
+		require.Equal(t, 1+i*100, bucket.Min)
+		require.Equal(t, (i+1)*100, bucket.Max)
+		// 均匀分布下每个区间约占 1/10
+		require.InDelta(t, samples/10, bucket.Count, samples/100)
+		total += bucket.Count
+	}
+	require.Equal(t, samples, total)
+
+	// 模拟不会创建兑换码
+	var count int64
+	require.NoError(t, model.DB.Model(&model.Redemption{}).Count(&count).Error)
+	require.Zero(t, count)
+}
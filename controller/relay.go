@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
@@ -63,6 +64,68 @@ func geminiRelayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewA
 	return err
 }
 
+// relayEndpointOf 返回请求所属的接口类别，用于全局接口开关，返回空字符串表示不受开关控制
+func relayEndpointOf(relayFormat types.RelayFormat, path string) string {
+	switch relayFormat {
+	case types.RelayFormatOpenAIImage:
+		return operation_setting.EndpointImages
+	case types.RelayFormatEmbedding:
+		return operation_setting.EndpointEmbeddings
+	case types.RelayFormatOpenAIAudio:
+		return operation_setting.EndpointAudio
+	case types.RelayFormatClaude, types.RelayFormatOpenAIResponses, types.RelayFormatOpenAIResponsesCompaction,
+		types.RelayFormatOpenAIRealtime:
+		return operation_setting.EndpointChat
+	case types.RelayFormatGemini:
+		if strings.Contains(path, "embed") {
+			return operation_setting.EndpointEmbeddings
+		}
+		return operation_setting.EndpointChat
+	case types.RelayFormatOpenAI:
+		switch relayconstant.Path2RelayMode(path) {
+		case relayconstant.RelayModeCompletions:
+			return operation_setting.EndpointCompletions
+		case relayconstant.RelayModeChatCompletions:
+			return operation_setting.EndpointChat
+		}
+	}
+	return ""
+}
+
+// clampMaxTokensToQuota 按剩余额度下调请求的最大输出 token 数，下调后重新计算预扣费
+func clampMaxTokensToQuota(c *gin.Context, request dto.Request, relayInfo *relaycommon.RelayInfo, tokens int, meta *types.TokenCountMeta, priceData types.PriceData) (types.PriceData, *types.NewAPIError) {
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return priceData, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	original, clamped, ok := service.ClampRequestMaxTokens(request, relayInfo, userQuota, c.GetInt("token_quota"), priceData)
+	if !ok {
+		return priceData, nil
+	}
+	c.Writer.Header().Add("Warning", service.MaxTokensClampWarning(original, clamped))
+	if original == 0 {
+		logger.LogInfo(c, fmt.Sprintf("用户 %d 未指定最大输出 token 数，已按剩余额度设为 %d", relayInfo.UserId, clamped))
+	} else {
+		logger.LogInfo(c, fmt.Sprintf("用户 %d 剩余额度不足以支付 %d 个输出 token，已下调为 %d", relayInfo.UserId, original, clamped))
+	}
+	meta.MaxTokens = clamped
+	priceData, err = helper.ModelPriceHelper(c, relayInfo, tokens, meta)
+	if err != nil {
+		return priceData, types.NewError(err, types.ErrorCodeModelPriceError)
+	}
+	return priceData, nil
+}
+
+// isEndpointAllowedForToken 判断令牌是否允许访问该接口类别，令牌未限制接口时全部允许，
+// 限制后不属于任何类别的接口一律拒绝
+func isEndpointAllowedForToken(c *gin.Context, endpoint string) bool {
+	allowed := common.GetContextKeyStringSlice(c, constant.ContextKeyTokenAllowedEndpoints)
+	if len(allowed) == 0 {
+		return true
+	}
+	return endpoint != "" && slices.Contains(allowed, endpoint)
+}
+
 func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	requestId := c.GetString(common.RequestIdKey)
@@ -104,6 +167,16 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	endpoint := relayEndpointOf(relayFormat, c.Request.URL.Path)
+	if !operation_setting.IsEndpointEnabled(endpoint) {
+		newAPIError = types.NewErrorWithStatusCode(fmt.Errorf("endpoint disabled: %s 接口已被管理员关闭", endpoint), types.ErrorCodeEndpointDisabled, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+		return
+	}
+	if !isEndpointAllowedForToken(c, endpoint) {
+		newAPIError = types.NewErrorWithStatusCode(fmt.Errorf("该令牌无权访问 %s 接口", c.Request.URL.Path), types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+		return
+	}
+
 	request, err := helper.GetAndValidateRequest(c, relayFormat)
 	if err != nil {
 		// Map "request body too large" to 413 so clients can handle it correctly
@@ -115,11 +188,28 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	// 在估算 token 前注入，使分组系统提示词计入预扣费
+	service.ApplyGroupSystemPrompt(c, request)
+
 	relayInfo, err := relaycommon.GenRelayInfo(c, relayFormat, request, ws)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
 		return
 	}
+	relayInfo.Endpoint = endpoint
+
+	if relayInfo.IsStream {
+		releaseStream, streamErr := service.AcquireStreamSlot(relayInfo.UserId)
+		if streamErr != nil {
+			newAPIError = streamErr
+			return
+		}
+		defer releaseStream()
+	}
+
+	if value, ok := service.ApplyDefaultMaxTokens(request, relayInfo.OriginModelName, relayInfo.UsingGroup); ok {
+		logger.LogDebug(c, "请求未指定最大输出 token 数，使用默认值 %d", value)
+	}
 
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
@@ -154,6 +244,13 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	if operation_setting.GetQuotaSetting().ClampMaxTokensToQuota && meta != nil {
+		priceData, newAPIError = clampMaxTokensToQuota(c, request, relayInfo, tokens, meta, priceData)
+		if newAPIError != nil {
+			return
+		}
+	}
+
 	// common.SetContextKey(c, constant.ContextKeyTokenCountMeta, meta)
 
 	if priceData.FreeModel {
@@ -226,6 +323,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
 			break
 		}
+		if channelAttemptsExhausted(c, relayInfo) {
+			logger.LogInfo(c, fmt.Sprintf("已尝试 %d 个渠道，达到上限，停止故障转移", service.CountTriedChannels(c)))
+			break
+		}
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -242,6 +343,12 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// channelAttemptsExhausted 判断已尝试的渠道数是否达到上限，达到后返回最后一次的错误
+func channelAttemptsExhausted(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	limit := operation_setting.GetMaxChannelsTried(info.UsingGroup, info.UserGroup)
+	return limit > 0 && service.CountTriedChannels(c) >= limit
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -323,6 +430,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 上游不存在该模型时换一个渠道重试，出错的渠道已被临时排除
+	if operation_setting.GetFailoverSetting().ModelNotFoundRetryEnabled && service.IsUpstreamModelNotFoundError(openaiErr, c.GetString("original_model")) {
+		return true
+	}
 	code := openaiErr.StatusCode
 	if code >= 200 && code < 300 {
 		return false
@@ -342,6 +453,9 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 			service.DisableChannel(channelError, err.ErrorWithStatusCode())
 		})
 	}
+	if modelName := c.GetString("original_model"); service.MarkChannelModelNotFound(channelError.ChannelId, modelName, err) {
+		logger.LogWarn(c, fmt.Sprintf("渠道 #%d 上游不存在模型 %s，%s 内不再为该模型选择此渠道", channelError.ChannelId, modelName, operation_setting.GetModelNotFoundTTL()))
+	}
 
 	if constant.ErrorLogEnabled && types.IsRecordErrorLog(err) {
 		// 保存错误日志到mysql中
@@ -361,6 +475,10 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 		other["channel_id"] = channelId
 		other["channel_name"] = c.GetString("channel_name")
 		other["channel_type"] = c.GetInt("channel_type")
+		if region := common.GetContextKeyString(c, constant.ContextKeyChannelRegion); region != "" {
+			other["channel_region"] = region
+		}
+		other["channels_tried"] = service.CountTriedChannels(c)
 		adminInfo := make(map[string]interface{})
 		adminInfo["use_channel"] = c.GetStringSlice("use_channel")
 		isMultiKey := common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey)
@@ -376,6 +494,15 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 }
 
 func RelayMidjourney(c *gin.Context) {
+	// 任务类接口不属于任何接口类别，令牌限制接口后一律拒绝
+	if !isEndpointAllowedForToken(c, "") {
+		c.JSON(http.StatusForbidden, gin.H{
+			"description": fmt.Sprintf("该令牌无权访问 %s 接口", c.Request.URL.Path),
+			"type":        "new_api_error",
+			"code":        4,
+		})
+		return
+	}
 	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatMjProxy, nil, nil)
 
 	if err != nil {
@@ -443,6 +570,10 @@ func RelayNotFound(c *gin.Context) {
 }
 
 func RelayTask(c *gin.Context) {
+	if !isEndpointAllowedForToken(c, "") {
+		c.JSON(http.StatusForbidden, service.TaskErrorWrapperLocal(fmt.Errorf("该令牌无权访问 %s 接口", c.Request.URL.Path), string(types.ErrorCodeAccessDenied), http.StatusForbidden))
+		return
+	}
 	retryTimes := common.RetryTimes
 	channelId := c.GetInt("channel_id")
 	c.Set("use_channel", []string{fmt.Sprintf("%d", channelId)})
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay_DisabledEndpointRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetEndpointSetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.ImagesEnabled = false
+
+	relayRequest := func(path string, format types.RelayFormat) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		c.Request.Header.Set("Content-Type", "application/json")
+		Relay(c, format)
+		return recorder
+	}
+
+	images := relayRequest("/v1/images/generations", types.RelayFormatOpenAIImage)
+	require.Equal(t, http.StatusForbidden, images.Code)
+	require.Contains(t, images.Body.String(), string(types.ErrorCodeEndpointDisabled))
+
+	// 其他接口不受影响，请求会继续进入正常的校验流程
+	chat := relayRequest("/v1/chat/completions", types.RelayFormatOpenAI)
+	require.NotEqual(t, http.StatusForbidden, chat.Code)
+	require.NotContains(t, chat.Body.String(), string(types.ErrorCodeEndpointDisabled))
+}
+
+func TestRelay_TokenAllowedEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token := &model.Token{AllowedEndpoints: "embeddings"}
+	relayRequest := func(path string, format types.RelayFormat) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		c.Request.Header.Set("Content-Type", "application/json")
+		common.SetContextKey(c, constant.ContextKeyTokenAllowedEndpoints, token.GetAllowedEndpoints())
+		Relay(c, format)
+		return recorder
+	}
+
+	chat := relayRequest("/v1/chat/completions", types.RelayFormatOpenAI)
+	require.Equal(t, http.StatusForbidden, chat.Code)
+	require.Contains(t, chat.Body.String(), string(types.ErrorCodeAccessDenied))
+
+	// 未归类的接口在令牌限制接口后同样被拒绝
+	moderation := relayRequest("/v1/moderations", types.RelayFormatOpenAI)
+	require.Equal(t, http.StatusForbidden, moderation.Code)
+
+	embeddings := relayRequest("/v1/embeddings", types.RelayFormatEmbedding)
+	require.NotEqual(t, http.StatusForbidden, embeddings.Code)
+	require.NotContains(t, embeddings.Body.String(), string(types.ErrorCodeAccessDenied))
+
+	// 任务类接口同样受令牌接口限制
+	taskRequest := func(handler gin.HandlerFunc, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		c.Request.Header.Set("Content-Type", "application/json")
+		common.SetContextKey(c, constant.ContextKeyTokenAllowedEndpoints, token.GetAllowedEndpoints())
+		handler(c)
+		return recorder
+	}
+	suno := taskRequest(RelayTask, "/suno/submit/music")
+	require.Equal(t, http.StatusForbidden, suno.Code)
+	require.Contains(t, suno.Body.String(), string(types.ErrorCodeAccessDenied))
+	require.Equal(t, http.StatusForbidden, taskRequest(RelayMidjourney, "/mj/submit/imagine").Code)
+}
+
+func TestRelayEndpointOf(t *testing.T) {
+	require.Equal(t, operation_setting.EndpointCompletions, relayEndpointOf(types.RelayFormatOpenAI, "/v1/completions"))
+	require.Equal(t, operation_setting.EndpointChat, relayEndpointOf(types.RelayFormatOpenAI, "/v1/chat/completions"))
+	require.Equal(t, operation_setting.EndpointEmbeddings, relayEndpointOf(types.RelayFormatGemini, "/v1beta/models/text-embedding-004:embedContent"))
+	require.Equal(t, operation_setting.EndpointAudio, relayEndpointOf(types.RelayFormatOpenAIAudio, "/v1/audio/speech"))
+	require.Empty(t, relayEndpointOf(types.RelayFormatOpenAI, "/v1/moderations"))
+}
+
+func TestChannelAttemptsExhausted_StopsFailover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetFailoverSetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.MaxChannelsTried = 3
+	setting.GroupMaxChannelsTried = map[string]int{"vip": 5}
+
+	// 模拟每个渠道都失败时的故障转移循环，返回实际尝试的渠道数
+	failover := func(info *relaycommon.RelayInfo, channelIds []int) int {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		for _, id := range channelIds {
+			addUsedChannel(c, id)
+			if channelAttemptsExhausted(c, info) {
+				break
+			}
+		}
+		return service.CountTriedChannels(c)
+	}
+	channels := []int{1, 2, 3, 4, 5, 6, 7, 8}
+
+	require.Equal(t, 3, failover(&relaycommon.RelayInfo{UsingGroup: "default", UserGroup: "default"}, channels))
+	require.Equal(t, 5, failover(&relaycommon.RelayInfo{UsingGroup: "vip", UserGroup: "default"}, channels))
+	// 重复选中同一渠道只计一次
+	require.Equal(t, 3, failover(&relaycommon.RelayInfo{UsingGroup: "default"}, []int{1, 1, 2, 2, 3, 4}))
+
+	setting.MaxChannelsTried = 0
+	require.Equal(t, len(channels), failover(&relaycommon.RelayInfo{UsingGroup: "default"}, channels))
+}
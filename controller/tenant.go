@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantScopeOf 返回管理员可见的租户范围，超级管理员可见全部租户
+func tenantScopeOf(c *gin.Context) int {
+	if c.GetInt("role") >= common.RoleRootUser {
+		return model.AllTenants
+	}
+	return common.GetContextKeyInt(c, constant.ContextKeyTenantId)
+}
+
+// tenantAccessible 判断管理员能否操作属于 tenantId 的用户或渠道
+func tenantAccessible(c *gin.Context, tenantId int) bool {
+	scope := tenantScopeOf(c)
+	return scope == model.AllTenants || scope == tenantId
+}
+
+// assignTenant 返回新建用户或渠道所属的租户，只有超级管理员可以指定其他租户
+func assignTenant(c *gin.Context, requested int) int {
+	if tenantScopeOf(c) == model.AllTenants {
+		return requested
+	}
+	return common.GetContextKeyInt(c, constant.ContextKeyTenantId)
+}
+
+// getTenantChannel 获取渠道，其他租户的渠道按不存在处理
+func getTenantChannel(c *gin.Context, id int, selectAll bool) (*model.Channel, error) {
+	channel, err := model.GetChannelById(id, selectAll)
+	if err != nil {
+		return nil, err
+	}
+	if !tenantAccessible(c, channel.TenantId) {
+		return nil, errors.New("渠道不存在")
+	}
+	return channel, nil
+}
+
+// filterTenantChannels 过滤出管理员可操作的渠道
+func filterTenantChannels(c *gin.Context, channels []*model.Channel) []*model.Channel {
+	if tenantScopeOf(c) == model.AllTenants {
+		return channels
+	}
+	filtered := make([]*model.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if tenantAccessible(c, channel.TenantId) {
+			filtered = append(filtered, channel)
+		}
+	}
+	return filtered
+}
+
+// checkTenantChannelIds 校验批量操作的渠道都属于管理员可操作的租户
+func checkTenantChannelIds(c *gin.Context, ids []int) error {
+	if tenantScopeOf(c) == model.AllTenants || len(ids) == 0 {
+		return nil
+	}
+	channels, err := model.GetChannelsByIds(ids)
+	if err != nil {
+		return err
+	}
+	if len(filterTenantChannels(c, channels)) != len(channels) {
+		return errors.New("渠道不存在")
+	}
+	return nil
+}
+
+// globalStatsAccessible 开启多租户后，跨租户的汇总数据仅超级管理员可见
+func globalStatsAccessible(c *gin.Context) bool {
+	return tenantScopeOf(c) == model.AllTenants || !operation_setting.GetTenantSetting().Enabled
+}
+
+func GetAllTenants(c *gin.Context) {
+	tenants, err := model.GetAllTenants()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tenants)
+}
+
+func AddTenant(c *gin.Context) {
+	tenant := model.Tenant{Status: model.TenantStatusEnabled}
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	tenant.Id = 0
+	if err := tenant.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := tenant.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tenant)
+}
+
+func UpdateTenant(c *gin.Context) {
+	tenant := model.Tenant{}
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanTenant, err := model.GetTenantById(tenant.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := tenant.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// If you add more fields, please also update tenant.Update()
+	cleanTenant.Code = tenant.Code
+	cleanTenant.Name = tenant.Name
+	cleanTenant.Status = tenant.Status
+	if err := cleanTenant.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanTenant)
+}
+
+func DeleteTenant(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteTenantById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
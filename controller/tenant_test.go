@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListUsers_IsolatedByTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.User{})
+	users := []model.User{
+		{Username: "default_user", Password: "12345678", AffCode: "a0"},
+		{Username: "tenant_a_user", Password: "12345678", AffCode: "a1", TenantId: 1},
+		{Username: "tenant_a_other", Password: "12345678", AffCode: "a2", TenantId: 1},
+		{Username: "tenant_b_user", Password: "12345678", AffCode: "b1", TenantId: 2},
+	}
+	require.NoError(t, model.DB.Create(&users).Error)
+
+	list := func(handler gin.HandlerFunc, target string, role int, tenantId int) []string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Set("role", role)
+		common.SetContextKey(c, constant.ContextKeyTenantId, tenantId)
+		handler(c)
+		var resp struct {
+			Success bool `json:"success"`
+			Data    struct {
+				Items []model.User `json:"items"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		require.True(t, resp.Success)
+		names := make([]string, 0, len(resp.Data.Items))
+		for _, user := range resp.Data.Items {
+			names = append(names, user.Username)
+		}
+		return names
+	}
+
+	require.ElementsMatch(t, []string{"tenant_a_user", "tenant_a_other"}, list(GetAllUsers, "/api/user/", common.RoleAdminUser, 1))
+	require.ElementsMatch(t, []string{"tenant_b_user"}, list(SearchUsers, "/api/user/search?keyword=user", common.RoleAdminUser, 2))
+	require.ElementsMatch(t, []string{"default_user"}, list(SearchUsers, "/api/user/search?keyword=user", common.RoleAdminUser, model.DefaultTenantId))
+	// 超级管理员可见全部租户
+	require.Len(t, list(GetAllUsers, "/api/user/", common.RoleRootUser, model.DefaultTenantId), 4)
+
+	// 其他租户的用户按不存在处理
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/user/", nil)
+	c.Params = gin.Params{{Key: "id", Value: "4"}}
+	c.Set("role", common.RoleAdminUser)
+	common.SetContextKey(c, constant.ContextKeyTenantId, 1)
+	GetUser(c)
+	require.Contains(t, recorder.Body.String(), "用户不存在")
+}
+
+func TestChannelAdmin_RejectsCrossTenantMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.User{}, &model.Channel{}, &model.Ability{}, &model.Log{}, &model.Redemption{})
+	tag := "shared"
+	channels := []model.Channel{
+		{Name: "a_enabled", Key: "k1", Status: common.ChannelStatusEnabled, Tag: &tag, TenantId: 1},
+		{Name: "a_disabled", Key: "k2", Status: common.ChannelStatusManuallyDisabled, TenantId: 1},
+		{Name: "b_enabled", Key: "k3", Status: common.ChannelStatusEnabled, Tag: &tag, TenantId: 2},
+		{Name: "b_disabled", Key: "k4", Status: common.ChannelStatusManuallyDisabled, TenantId: 2},
+	}
+	require.NoError(t, model.DB.Create(&channels).Error)
+
+	call := func(handler gin.HandlerFunc, method string, body string) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(method, "/api/channel/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("role", common.RoleAdminUser)
+		common.SetContextKey(c, constant.ContextKeyTenantId, 1)
+		handler(c)
+		return recorder.Body.String()
+	}
+	channelStatus := func(id int) int {
+		channel, err := model.GetChannelById(id, false)
+		require.NoError(t, err)
+		return channel.Status
+	}
+
+	// 批量删除包含其他租户的渠道时整体拒绝
+	require.Contains(t, call(DeleteChannelBatch, http.MethodPost, fmt.Sprintf(`{"ids":[%d,%d]}`, channels[0].Id, channels[2].Id)), "渠道不存在")
+	_, err := model.GetChannelById(channels[2].Id, false)
+	require.NoError(t, err)
+
+	// 按标签禁用只影响本租户的渠道
+	call(DisableTagChannels, http.MethodPost, `{"tag":"shared"}`)
+	require.Equal(t, common.ChannelStatusManuallyDisabled, channelStatus(channels[0].Id))
+	require.Equal(t, common.ChannelStatusEnabled, channelStatus(channels[2].Id))
+
+	// 删除已禁用渠道只删除本租户的渠道
+	call(DeleteDisabledChannel, http.MethodDelete, "")
+	_, err = model.GetChannelById(channels[3].Id, false)
+	require.NoError(t, err)
+	_, err = model.GetChannelById(channels[1].Id, false)
+	require.Error(t, err)
+
+	// 其他租户的兑换码与日志不可见
+	foreign := model.Redemption{Key: "foreign-key", Name: "foreign", Status: common.RedemptionCodeStatusEnabled, TenantId: 2}
+	require.NoError(t, model.DB.Create(&foreign).Error)
+	require.Contains(t, call(UpdateRedemption, http.MethodPut, fmt.Sprintf(`{"id":%d,"status":2}`, foreign.Id)), "兑换码不存在")
+	users := []model.User{
+		{Username: "a_user", Password: "12345678", AffCode: "a1", TenantId: 1},
+		{Username: "b_user", Password: "12345678", AffCode: "b1", TenantId: 2},
+	}
+	require.NoError(t, model.DB.Create(&users).Error)
+	for _, user := range users {
+		require.NoError(t, model.LOG_DB.Create(&model.Log{UserId: user.Id, Username: user.Username, Type: model.LogTypeConsume, Quota: 10}).Error)
+	}
+	logs, _, err := model.GetAllLogs(model.LogTypeUnknown, 0, 0, "", "", "", 0, 10, 0, "", 1)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, "a_user", logs[0].Username)
+}
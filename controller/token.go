@@ -8,6 +8,8 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -136,6 +138,19 @@ func GetTokenUsage(c *gin.Context) {
 	})
 }
 
+// applyTokenNamePolicy 按当前用户的用户名与分组应用令牌命名规范，
+// 分组从用户记录读取，避免 access token 调用时分组缺失或会话中的分组已过期
+func applyTokenNamePolicy(c *gin.Context, name string) (string, error) {
+	if !operation_setting.GetTokenSetting().NamePolicyEnabled {
+		return name, nil
+	}
+	group, err := model.GetUserGroup(c.GetInt("id"), false)
+	if err != nil {
+		return name, err
+	}
+	return operation_setting.ApplyTokenNamePolicy(name, c.GetString("username"), group)
+}
+
 func AddToken(c *gin.Context) {
 	token := model.Token{}
 	err := c.ShouldBindJSON(&token)
@@ -143,6 +158,14 @@ func AddToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	token.Name, err = applyTokenNamePolicy(c, token.Name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if len(token.Name) > 50 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -150,6 +173,10 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := validateTokenAllowedEndpoints(token.AllowedEndpoints); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	// 非无限额度时，检查额度值是否超出有效范围
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
@@ -191,6 +218,7 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		AllowedEndpoints:   token.AllowedEndpoints,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -204,6 +232,76 @@ func AddToken(c *gin.Context) {
 	return
 }
 
+// tokenRequestLog 令牌请求日志中对令牌所有者可见的字段
+type tokenRequestLog struct {
+	CreatedAt        int64  `json:"created_at"`
+	RequestId        string `json:"request_id"`
+	ModelName        string `json:"model_name"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+	Status           string `json:"status"` // success 或 failed
+	StatusCode       int    `json:"status_code,omitempty"`
+	UseTime          int    `json:"use_time"` // 秒
+	IsStream         bool   `json:"is_stream"`
+}
+
+// GetTokenLogs 获取当前用户某个令牌最近的请求日志
+func GetTokenLogs(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	if _, err := model.GetTokenByIds(id, userId); err != nil {
+		common.ApiErrorMsg(c, "令牌不存在")
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	logs, total, err := model.GetTokenLogs(userId, id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]tokenRequestLog, 0, len(logs))
+	for _, log := range logs {
+		item := tokenRequestLog{
+			CreatedAt:        log.CreatedAt,
+			RequestId:        log.RequestId,
+			ModelName:        log.ModelName,
+			PromptTokens:     log.PromptTokens,
+			CompletionTokens: log.CompletionTokens,
+			Quota:            log.Quota,
+			Status:           "success",
+			UseTime:          log.UseTime,
+			IsStream:         log.IsStream,
+		}
+		if log.Type == model.LogTypeError {
+			item.Status = "failed"
+			other, _ := common.StrToMap(log.Other)
+			if statusCode, ok := other["status_code"].(float64); ok {
+				item.StatusCode = int(statusCode)
+			}
+		}
+		items = append(items, item)
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// validateTokenAllowedEndpoints 校验令牌允许的接口类别均为已知类别
+func validateTokenAllowedEndpoints(allowedEndpoints string) error {
+	for _, endpoint := range strings.Split(allowedEndpoints, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint != "" && !operation_setting.IsKnownEndpoint(endpoint) {
+			return fmt.Errorf("未知的接口类别: %s", endpoint)
+		}
+	}
+	return nil
+}
+
 func DeleteToken(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -219,6 +317,26 @@ func DeleteToken(c *gin.Context) {
 	return
 }
 
+// RestoreSuspendedToken 管理员恢复因用量异常被自动暂停的令牌
+func RestoreSuspendedToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	token, err := model.RestoreSuspendedToken(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.ResetTokenAnomaly(token.Id)
+	model.RecordLog(token.UserId, model.LogTypeManage, fmt.Sprintf("管理员恢复了因用量异常被暂停的令牌 #%d", token.Id))
+	common.ApiSuccess(c, gin.H{
+		"id":     token.Id,
+		"status": token.Status,
+	})
+}
+
 func UpdateToken(c *gin.Context) {
 	userId := c.GetInt("id")
 	statusOnly := c.Query("status_only")
@@ -235,6 +353,10 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := validateTokenAllowedEndpoints(token.AllowedEndpoints); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
 			c.JSON(http.StatusOK, gin.H{
@@ -257,6 +379,13 @@ func UpdateToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if statusOnly != "" && cleanToken.Status == common.TokenStatusSuspended {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "令牌因用量异常已被暂停，请联系管理员恢复",
+		})
+		return
+	}
 	if token.Status == common.TokenStatusEnabled {
 		if cleanToken.Status == common.TokenStatusExpired && cleanToken.ExpiredTime <= common.GetTimestamp() && cleanToken.ExpiredTime != -1 {
 			c.JSON(http.StatusOK, gin.H{
@@ -276,8 +405,23 @@ func UpdateToken(c *gin.Context) {
 	if statusOnly != "" {
 		cleanToken.Status = token.Status
 	} else {
+		name, err := applyTokenNamePolicy(c, token.Name)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		if len(name) > 50 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "令牌名称过长",
+			})
+			return
+		}
 		// If you add more fields, please also update token.Update()
-		cleanToken.Name = token.Name
+		cleanToken.Name = name
 		cleanToken.ExpiredTime = token.ExpiredTime
 		cleanToken.RemainQuota = token.RemainQuota
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
@@ -286,6 +430,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.AllowedEndpoints = token.AllowedEndpoints
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupControllerTestDB 使用内存 SQLite 初始化 DB 与 LOG_DB，并迁移给定的表
+func setupControllerTestDB(t *testing.T, models ...any) {
+	t.Helper()
+	origDB, origLogDB, origRedis := model.DB, model.LOG_DB, common.RedisEnabled
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, common.RedisEnabled = origDB, origLogDB, origRedis
+	})
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(models...))
+	model.DB = db
+	model.LOG_DB = db
+	common.RedisEnabled = false
+}
+
+func TestGetTokenLogs_OwnerOnlyAndFilteredByToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.Token{}, &model.Log{})
+	mine := model.Token{UserId: 1, Key: "token-mine", Name: "mine"}
+	other := model.Token{UserId: 1, Key: "token-other", Name: "other"}
+	foreign := model.Token{UserId: 2, Key: "token-foreign", Name: "foreign"}
+	for _, token := range []*model.Token{&mine, &other, &foreign} {
+		require.NoError(t, model.DB.Create(token).Error)
+	}
+	logs := []model.Log{
+		{UserId: 1, TokenId: mine.Id, Type: model.LogTypeConsume, ModelName: "gpt-4o", PromptTokens: 10, CompletionTokens: 20, UseTime: 2, CreatedAt: 100},
+		{UserId: 1, TokenId: mine.Id, Type: model.LogTypeError, ModelName: "gpt-4o", UseTime: 1, CreatedAt: 200, Other: `{"status_code":429}`},
+		{UserId: 1, TokenId: mine.Id, Type: model.LogTypeManage, CreatedAt: 300},
+		{UserId: 1, TokenId: other.Id, Type: model.LogTypeConsume, ModelName: "gpt-4o-mini", CreatedAt: 400},
+		{UserId: 2, TokenId: foreign.Id, Type: model.LogTypeConsume, ModelName: "claude", CreatedAt: 500},
+	}
+	require.NoError(t, model.LOG_DB.Create(&logs).Error)
+
+	request := func(userId int, tokenId int) map[string]any {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/token/%d/logs", tokenId), nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(tokenId)}}
+		c.Set("id", userId)
+		GetTokenLogs(c)
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := request(1, mine.Id)
+	require.Equal(t, true, resp["success"])
+	data := resp["data"].(map[string]any)
+	require.EqualValues(t, 2, data["total"])
+	items := data["items"].([]any)
+	require.Len(t, items, 2)
+	failed := items[0].(map[string]any)
+	require.Equal(t, "failed", failed["status"])
+	require.EqualValues(t, 429, failed["status_code"])
+	success := items[1].(map[string]any)
+	require.Equal(t, "success", success["status"])
+	require.EqualValues(t, 10, success["prompt_tokens"])
+	require.EqualValues(t, 2, success["use_time"])
+
+	// 不能查看其他用户的令牌
+	resp = request(1, foreign.Id)
+	require.Equal(t, false, resp["success"])
+	require.Nil(t, resp["data"])
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,12 +13,14 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 
 	"github.com/QuantumNous/new-api/constant"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -96,12 +99,20 @@ func Login(c *gin.Context) {
 
 // setup session & cookies and then return user info
 func setupLogin(user *model.User, c *gin.Context) {
+	if !middleware.RequestTenantMatches(c, user.TenantId) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户不属于当前租户",
+			"success": false,
+		})
+		return
+	}
 	session := sessions.Default(c)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
 	session.Set("status", user.Status)
 	session.Set("group", user.Group)
+	session.Set("tenant_id", user.TenantId)
 	err := session.Save()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -212,6 +223,7 @@ func Register(c *gin.Context) {
 		DisplayName: user.Username,
 		InviterId:   inviterId,
 		Role:        common.RoleCommonUser, // 明确设置角色为普通用户
+		TenantId:    common.GetContextKeyInt(c, constant.ContextKeyRequestTenantId),
 	}
 	if common.EmailVerificationEnabled {
 		cleanUser.Email = user.Email
@@ -274,7 +286,7 @@ func Register(c *gin.Context) {
 
 func GetAllUsers(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.GetAllUsers(pageInfo)
+	users, total, err := model.GetAllUsers(pageInfo, tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -291,7 +303,7 @@ func SearchUsers(c *gin.Context) {
 	keyword := c.Query("keyword")
 	group := c.Query("group")
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.SearchUsers(keyword, group, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	users, total, err := model.SearchUsers(keyword, group, pageInfo.GetStartIdx(), pageInfo.GetPageSize(), tenantScopeOf(c))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -310,6 +322,9 @@ func GetUser(c *gin.Context) {
 		return
 	}
 	user, err := model.GetUserById(id, false)
+	if err == nil && !tenantAccessible(c, user.TenantId) {
+		err = errors.New("用户不存在")
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -618,6 +633,9 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	originUser, err := model.GetUserById(updatedUser.Id, false)
+	if err == nil && !tenantAccessible(c, originUser.TenantId) {
+		err = errors.New("用户不存在")
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -784,6 +802,9 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	originUser, err := model.GetUserById(id, false)
+	if err == nil && !tenantAccessible(c, originUser.TenantId) {
+		err = errors.New("用户不存在")
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -830,6 +851,28 @@ func DeleteSelf(c *gin.Context) {
 	return
 }
 
+// GetUserIdentities 列出当前用户绑定的外部登录身份
+func GetUserIdentities(c *gin.Context) {
+	identities, err := model.GetUserIdentities(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, identities)
+}
+
+// UnlinkUserIdentity 解绑当前用户的外部登录身份
+func UnlinkUserIdentity(c *gin.Context) {
+	userId := c.GetInt("id")
+	provider := c.Param("provider")
+	if err := model.UnlinkUserIdentity(userId, provider); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("解绑登录方式 %s", provider))
+	common.ApiSuccess(c, nil)
+}
+
 func CreateUser(c *gin.Context) {
 	var user model.User
 	err := json.NewDecoder(c.Request.Body).Decode(&user)
@@ -865,6 +908,7 @@ func CreateUser(c *gin.Context) {
 		Password:    user.Password,
 		DisplayName: user.DisplayName,
 		Role:        user.Role, // 保持管理员设置的角色
+		TenantId:    assignTenant(c, user.TenantId),
 	}
 	if err := cleanUser.Insert(0); err != nil {
 		common.ApiError(c, err)
@@ -900,7 +944,7 @@ func ManageUser(c *gin.Context) {
 	}
 	// Fill attributes
 	model.DB.Unscoped().Where(&user).First(&user)
-	if user.Id == 0 {
+	if user.Id == 0 || !tenantAccessible(c, user.TenantId) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "用户不存在",
@@ -917,7 +961,6 @@ func ManageUser(c *gin.Context) {
 	}
 	switch req.Action {
 	case "disable":
-		user.Status = common.UserStatusDisabled
 		if user.Role == common.RoleRootUser {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -925,6 +968,22 @@ func ManageUser(c *gin.Context) {
 			})
 			return
 		}
+		// 禁用用户时同时禁用其全部令牌，避免已缓存的令牌继续通过鉴权
+		disabledTokens, err := model.SuspendUser(user.Id)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data": gin.H{
+				"role":            user.Role,
+				"status":          common.UserStatusDisabled,
+				"disabled_tokens": disabledTokens,
+			},
+		})
+		return
 	case "enable":
 		user.Status = common.UserStatusEnabled
 	case "delete":
@@ -992,6 +1051,82 @@ func ManageUser(c *gin.Context) {
 	return
 }
 
+type MergeUserRequest struct {
+	SourceId int  `json:"source_id"`
+	TargetId int  `json:"target_id"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// MergeUsers 将源用户合并到目标用户，dry_run 时仅返回将要迁移的数据统计
+func MergeUsers(c *gin.Context) {
+	var req MergeUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SourceId == 0 || req.TargetId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if req.SourceId == req.TargetId {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不能将用户合并到自身",
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	for _, id := range []int{req.SourceId, req.TargetId} {
+		user, err := model.GetUserById(id, false)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "用户不存在",
+			})
+			return
+		}
+		if myRole <= user.Role && myRole != common.RoleRootUser {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权合并同权限等级或更高权限等级的用户",
+			})
+			return
+		}
+	}
+	summary, err := model.MergeUsers(req.SourceId, req.TargetId, req.DryRun)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, summary)
+}
+
+type GroupTopUpRequest struct {
+	Amount      int    `json:"amount"`
+	OperationId string `json:"operation_id"`
+}
+
+// TopUpGroupUsers 为分组内所有未禁用的用户批量增加额度，operation_id 用于重试时防止重复入账
+func TopUpGroupUsers(c *gin.Context) {
+	var req GroupTopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	group := c.Param("group")
+	result, err := model.TopUpGroupUsers(group, req.Amount, req.OperationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if result.Credited > 0 {
+		model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员为分组 %s 的 %d 个用户批量充值，共 %s（操作 %s）", group, result.Credited, logger.LogQuota(int(result.TotalQuota)), req.OperationId))
+	}
+	common.ApiSuccess(c, result)
+}
+
 func EmailBind(c *gin.Context) {
 	email := c.Query("email")
 	code := c.Query("code")
@@ -1088,11 +1223,19 @@ func TopUp(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if allowed, retryAfter := service.CheckRedeemAllowed(id, c.ClientIP()); !allowed {
+		common.ApiErrorMsg(c, fmt.Sprintf("兑换失败次数过多，请 %d 秒后再试", retryAfter))
+		return
+	}
 	quota, err := model.Redeem(req.Key, id)
 	if err != nil {
+		service.RecordRedeemFailure(id, c.ClientIP())
 		common.ApiError(c, err)
 		return
 	}
+	gopool.Go(func() {
+		service.EvaluateUserGroupTierById(id)
+	})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -1101,17 +1244,18 @@ func TopUp(c *gin.Context) {
 }
 
 type UpdateUserSettingRequest struct {
-	QuotaWarningType           string  `json:"notify_type"`
-	QuotaWarningThreshold      float64 `json:"quota_warning_threshold"`
-	WebhookUrl                 string  `json:"webhook_url,omitempty"`
-	WebhookSecret              string  `json:"webhook_secret,omitempty"`
-	NotificationEmail          string  `json:"notification_email,omitempty"`
-	BarkUrl                    string  `json:"bark_url,omitempty"`
-	GotifyUrl                  string  `json:"gotify_url,omitempty"`
-	GotifyToken                string  `json:"gotify_token,omitempty"`
-	GotifyPriority             int     `json:"gotify_priority,omitempty"`
-	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
-	RecordIpLog                bool    `json:"record_ip_log"`
+	QuotaWarningType           string    `json:"notify_type"`
+	QuotaWarningThreshold      float64   `json:"quota_warning_threshold"`
+	QuotaWarningPercents       []float64 `json:"quota_warning_percents,omitempty"`
+	WebhookUrl                 string    `json:"webhook_url,omitempty"`
+	WebhookSecret              string    `json:"webhook_secret,omitempty"`
+	NotificationEmail          string    `json:"notification_email,omitempty"`
+	BarkUrl                    string    `json:"bark_url,omitempty"`
+	GotifyUrl                  string    `json:"gotify_url,omitempty"`
+	GotifyToken                string    `json:"gotify_token,omitempty"`
+	GotifyPriority             int       `json:"gotify_priority,omitempty"`
+	AcceptUnsetModelRatioModel bool      `json:"accept_unset_model_ratio_model"`
+	RecordIpLog                bool      `json:"record_ip_log"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1142,6 +1286,24 @@ func UpdateUserSetting(c *gin.Context) {
 		return
 	}
 
+	// 验证多级预警百分比
+	if len(req.QuotaWarningPercents) > 10 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "预警百分比最多设置 10 个",
+		})
+		return
+	}
+	for _, percent := range req.QuotaWarningPercents {
+		if percent <= 0 || percent >= 100 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "预警百分比必须在 0 到 100 之间",
+			})
+			return
+		}
+	}
+
 	// 如果是webhook类型,验证webhook地址
 	if req.QuotaWarningType == dto.NotifyTypeWebhook {
 		if req.WebhookUrl == "" {
@@ -1245,6 +1407,7 @@ func UpdateUserSetting(c *gin.Context) {
 	settings := dto.UserSetting{
 		NotifyType:            req.QuotaWarningType,
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
+		QuotaWarningPercents:  req.QuotaWarningPercents,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
 	}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserIdentities_ListAndUnlink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupControllerTestDB(t, &model.User{}, &model.UserIdentityLink{}, &model.PasskeyCredential{}, &model.Log{})
+	user := &model.User{Username: "oauth_user", GitHubId: "octocat", OidcId: "oidc-1", AffCode: "a1b2"}
+	require.NoError(t, model.DB.Create(user).Error)
+	require.NoError(t, user.Update(false))
+
+	call := func(method string, handler gin.HandlerFunc, params gin.Params) map[string]any {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(method, "/api/user/identities", nil)
+		c.Params = params
+		c.Set("id", user.Id)
+		handler(c)
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := call(http.MethodGet, GetUserIdentities, nil)
+	require.Equal(t, true, resp["success"])
+	identities := resp["data"].([]any)
+	require.Len(t, identities, 2)
+	github := identities[0].(map[string]any)
+	require.Equal(t, "github", github["provider"])
+	require.Equal(t, "octocat", github["external_id"])
+	require.NotZero(t, github["linked_at"])
+
+	resp = call(http.MethodDelete, UnlinkUserIdentity, gin.Params{{Key: "provider", Value: "github"}})
+	require.Equal(t, true, resp["success"])
+	resp = call(http.MethodGet, GetUserIdentities, nil)
+	require.Len(t, resp["data"].([]any), 1)
+
+	// 未设置密码时不能解绑最后一个登录方式
+	resp = call(http.MethodDelete, UnlinkUserIdentity, gin.Params{{Key: "provider", Value: "oidc"}})
+	require.Equal(t, false, resp["success"])
+	require.Equal(t, model.ErrLastLoginMethod.Error(), resp["message"])
+
+	resp = call(http.MethodDelete, UnlinkUserIdentity, gin.Params{{Key: "provider", Value: "github"}})
+	require.Equal(t, false, resp["success"])
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFailedWebhookDeliveries 分页列出失败（重试中或死信）的 webhook 投递
+func GetFailedWebhookDeliveries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	deliveries, total, err := model.GetFailedWebhookDeliveries(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ReplayWebhookDelivery 立即重放一条失败的 webhook 投递
+func ReplayWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	delivery, err := service.ReplayWebhookDelivery(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
@@ -0,0 +1,126 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChannelScheduleWindow 渠道可用时间段，Start/End 格式为 HH:MM，End 小于 Start 时表示跨天
+type ChannelScheduleWindow struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Weekdays []int  `json:"weekdays,omitempty"` // 0 表示周日，为空表示每天
+
+	startMinute int
+	endMinute   int
+}
+
+// ChannelSchedule 渠道定时启用配置，仅在时间窗口内参与渠道选择
+type ChannelSchedule struct {
+	Enabled  bool                    `json:"enabled"`
+	Timezone string                  `json:"timezone,omitempty"` // IANA 时区，例如 Asia/Shanghai，默认 UTC
+	Windows  []ChannelScheduleWindow `json:"windows"`
+
+	location *time.Location
+	prepared bool
+}
+
+func parseScheduleClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// Prepare 解析时区与时间窗口，解析结果会被缓存，之后的判断无需再次解析
+func (s *ChannelSchedule) Prepare() error {
+	if s == nil {
+		return nil
+	}
+	location := time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+		}
+		location = loc
+	}
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		start, err := parseScheduleClock(w.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseScheduleClock(w.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("schedule window start and end must differ: %s-%s", w.Start, w.End)
+		}
+		for _, day := range w.Weekdays {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("invalid weekday %d, expected 0-6", day)
+			}
+		}
+		w.startMinute = start
+		w.endMinute = end
+	}
+	s.location = location
+	s.prepared = true
+	return nil
+}
+
+func (w *ChannelScheduleWindow) matchWeekday(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActiveAt 判断给定时间是否处于可用时间窗口内，未启用定时或配置无效时视为始终可用
+func (s *ChannelSchedule) IsActiveAt(t time.Time) bool {
+	if s == nil || !s.Enabled || len(s.Windows) == 0 {
+		return true
+	}
+	if !s.prepared {
+		if err := s.Prepare(); err != nil {
+			return true
+		}
+	}
+	local := t.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		if w.startMinute < w.endMinute {
+			if minute >= w.startMinute && minute < w.endMinute && w.matchWeekday(local.Weekday()) {
+				return true
+			}
+			continue
+		}
+		// 跨天窗口：开始日的 [start, 24:00) 与次日的 [00:00, end)
+		if minute >= w.startMinute && w.matchWeekday(local.Weekday()) {
+			return true
+		}
+		if minute < w.endMinute && w.matchWeekday((local.Weekday()+6)%7) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSchedule_OvernightWindowAndWeekdays(t *testing.T) {
+	schedule := &ChannelSchedule{
+		Enabled:  true,
+		Timezone: "Asia/Shanghai",
+		Windows:  []ChannelScheduleWindow{{Start: "22:00", End: "06:00", Weekdays: []int{int(time.Monday)}}},
+	}
+	require.NoError(t, schedule.Prepare())
+
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// 2024-01-01 is a Monday
+	require.True(t, schedule.IsActiveAt(time.Date(2024, 1, 1, 23, 0, 0, 0, loc)))
+	require.True(t, schedule.IsActiveAt(time.Date(2024, 1, 2, 5, 59, 0, 0, loc)))
+	require.False(t, schedule.IsActiveAt(time.Date(2024, 1, 2, 6, 0, 0, 0, loc)))
+	require.False(t, schedule.IsActiveAt(time.Date(2024, 1, 2, 23, 0, 0, 0, loc)))
+	require.False(t, schedule.IsActiveAt(time.Date(2024, 1, 1, 12, 0, 0, 0, loc)))
+}
+
+func TestChannelSchedule_PrepareRejectsInvalidConfig(t *testing.T) {
+	cases := map[string]ChannelSchedule{
+		"bad timezone": {Enabled: true, Timezone: "Mars/Olympus", Windows: []ChannelScheduleWindow{{Start: "09:00", End: "18:00"}}},
+		"start == end": {Enabled: true, Windows: []ChannelScheduleWindow{{Start: "09:00", End: "09:00"}}},
+		"weekday 7":    {Enabled: true, Windows: []ChannelScheduleWindow{{Start: "09:00", End: "18:00", Weekdays: []int{7}}}},
+	}
+	for name, schedule := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, schedule.Prepare())
+		})
+	}
+}
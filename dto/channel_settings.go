@@ -1,12 +1,19 @@
 package dto
 
 type ChannelSettings struct {
-	ForceFormat            bool   `json:"force_format,omitempty"`
-	ThinkingToContent      bool   `json:"thinking_to_content,omitempty"`
-	Proxy                  string `json:"proxy"`
-	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
-	SystemPrompt           string `json:"system_prompt,omitempty"`
-	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	ForceFormat            bool              `json:"force_format,omitempty"`
+	ThinkingToContent      bool              `json:"thinking_to_content,omitempty"`
+	Proxy                  string            `json:"proxy"`
+	PassThroughBodyEnabled bool              `json:"pass_through_body_enabled,omitempty"`
+	SystemPrompt           string            `json:"system_prompt,omitempty"`
+	SystemPromptOverride   bool              `json:"system_prompt_override,omitempty"`
+	Schedule               *ChannelSchedule  `json:"schedule,omitempty"`
+	ProviderLabel          string            `json:"provider_label,omitempty"`      // 对外展示的服务商标签，用于 X-Served-By 响应头
+	UpstreamStream         bool              `json:"upstream_stream,omitempty"`     // 非流式请求也以流式请求上游，再聚合为非流式响应返回
+	Region                 string            `json:"region,omitempty"`              // 渠道所在区域标签，用于数据驻留限制，例如 eu、us
+	Transformers           []string          `json:"transformers,omitempty"`        // 按顺序应用的请求/响应转换器名称，见 relay/transformer
+	UserAgent              string            `json:"user_agent,omitempty"`          // 请求上游时使用的 User-Agent，为空时使用网关默认值
+	AttributionHeaders     map[string]string `json:"attribution_headers,omitempty"` // 请求上游时附带的来源标识头，例如 OpenRouter 的 HTTP-Referer、X-Title
 }
 
 type VertexKeyType string
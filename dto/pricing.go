@@ -9,6 +9,9 @@ type OpenAIModels struct {
 	Created                int                     `json:"created"`
 	OwnedBy                string                  `json:"owned_by"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
+	Deprecated             bool                    `json:"deprecated,omitempty"`
+	SunsetDate             string                  `json:"sunset_date,omitempty"`
+	Replacement            string                  `json:"replacement,omitempty"`
 }
 
 type AnthropicModel struct {
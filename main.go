@@ -105,6 +105,24 @@ func main() {
 	// Codex credential auto-refresh check every 10 minutes, refresh when expires within 1 day
 	service.StartCodexCredentialAutoRefreshTask()
 
+	// 渠道定时启停状态同步
+	service.StartChannelScheduleTask()
+
+	// 失败 webhook 的重试投递
+	service.StartWebhookRetryTask()
+
+	// 兑换码累计统计的定时校准
+	service.StartRedemptionStatsReconcileTask()
+
+	// 按额度等级自动调整用户分组
+	service.StartGroupTierTask()
+
+	// 预算控制的当日消耗定期落库
+	service.StartDailySpendFlushTask()
+
+	// 定期向外部计费系统上报消费
+	service.StartBillingSettlementTask()
+
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
@@ -36,6 +36,7 @@ func authHelper(c *gin.Context, minRole int) {
 	role := session.Get("role")
 	id := session.Get("id")
 	status := session.Get("status")
+	tenantId, _ := session.Get("tenant_id").(int)
 	useAccessToken := false
 	if username == nil {
 		// Check access token
@@ -63,6 +64,7 @@ func authHelper(c *gin.Context, minRole int) {
 			role = user.Role
 			id = user.Id
 			status = user.Status
+			tenantId = user.TenantId
 			useAccessToken = true
 		} else {
 			c.JSON(http.StatusOK, gin.H{
@@ -125,12 +127,21 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	if !RequestTenantMatches(c, tenantId) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "无权进行此操作，用户不属于当前租户",
+		})
+		c.Abort()
+		return
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
 	c.Set("group", session.Get("group"))
 	c.Set("user_group", session.Get("group"))
 	c.Set("use_access_token", useAccessToken)
+	common.SetContextKey(c, constant.ContextKeyTenantId, tenantId)
 
 	//userCache, err := model.GetUserCache(id.(int))
 	//if err != nil {
@@ -274,6 +285,10 @@ func TokenAuth() func(c *gin.Context) {
 			return
 		}
 
+		if !RequestTenantMatches(c, userCache.TenantId) {
+			abortWithOpenAiMessage(c, http.StatusUnauthorized, "令牌不属于当前租户")
+			return
+		}
 		userCache.WriteContext(c)
 
 		userGroup := userCache.Group
@@ -299,6 +314,7 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		model.RecordTokenUsage(token.Id, token.UserId, c.ClientIP())
 		c.Next()
 	}
 }
@@ -323,6 +339,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenAllowedEndpoints, token.GetAllowedEndpoints())
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -12,9 +12,12 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -35,6 +38,11 @@ func Distribute() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, "Invalid request, "+err.Error())
 			return
 		}
+		setModelDeprecationHeaders(c, modelRequest.Model)
+		regions := operation_setting.GetRequiredRegions(c.GetInt("id"), common.GetContextKeyString(c, constant.ContextKeyUserGroup))
+		if len(regions) > 0 {
+			common.SetContextKey(c, constant.ContextKeyDataResidencyRegions, regions)
+		}
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -42,7 +50,7 @@ func Distribute() func(c *gin.Context) {
 				return
 			}
 			channel, err = model.GetChannelById(id, true)
-			if err != nil {
+			if err != nil || channel.TenantId != common.GetContextKeyInt(c, constant.ContextKeyTenantId) {
 				abortWithOpenAiMessage(c, http.StatusBadRequest, "无效的渠道 Id")
 				return
 			}
@@ -50,6 +58,17 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, "该渠道已被禁用")
 				return
 			}
+			if !channel.IsInRegions(regions) {
+				abortWithOpenAiMessage(c, http.StatusForbidden, dataResidencyMessage(regions), types.ErrorCodeDataResidency)
+				return
+			}
+			resolveVirtualModel(c, modelRequest)
+			applyBudgetPressure(c, modelRequest)
+			release, acquired := acquireModelConcurrency(c, modelRequest.Model)
+			if !acquired {
+				return
+			}
+			defer release()
 		} else {
 			// Select a channel for the user
 			// check token model mapping
@@ -72,6 +91,13 @@ func Distribute() func(c *gin.Context) {
 					return
 				}
 			}
+			resolveVirtualModel(c, modelRequest)
+			applyBudgetPressure(c, modelRequest)
+			release, acquired := acquireModelConcurrency(c, modelRequest.Model)
+			if !acquired {
+				return
+			}
+			defer release()
 
 			if shouldSelectChannel {
 				if modelRequest.Model == "" {
@@ -98,27 +124,9 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
-				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
-					preferred, err := model.CacheGetChannel(preferredChannelID)
-					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
-						if usingGroup == "auto" {
-							userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
-							autoGroups := service.GetUserAutoGroup(userGroup)
-							for _, g := range autoGroups {
-								if model.IsChannelEnabledForGroupModel(g, modelRequest.Model, preferred.Id) {
-									selectGroup = g
-									common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
-									channel = preferred
-									service.MarkChannelAffinityUsed(c, g, preferred.Id)
-									break
-								}
-							}
-						} else if model.IsChannelEnabledForGroupModel(usingGroup, modelRequest.Model, preferred.Id) {
-							channel = preferred
-							selectGroup = usingGroup
-							service.MarkChannelAffinityUsed(c, usingGroup, preferred.Id)
-						}
-					}
+				if preferred, group := selectAffinityChannel(c, modelRequest.Model, usingGroup, regions); preferred != nil {
+					channel = preferred
+					selectGroup = group
 				}
 
 				if channel == nil {
@@ -128,6 +136,11 @@ func Distribute() func(c *gin.Context) {
 						TokenGroup: usingGroup,
 						Retry:      common.GetPointer(0),
 					})
+					if len(regions) > 0 && (err != nil || channel == nil) {
+						message := fmt.Sprintf("分组 %s 下模型 %s %s", usingGroup, modelRequest.Model, dataResidencyMessage(regions))
+						abortWithOpenAiMessage(c, http.StatusForbidden, message, types.ErrorCodeDataResidency)
+						return
+					}
 					if err != nil {
 						showGroup := usingGroup
 						if usingGroup == "auto" {
@@ -150,7 +163,10 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if newAPIError := SetupContextForSelectedChannel(c, channel, modelRequest.Model); newAPIError != nil && newAPIError.GetErrorCode() == types.ErrorCodeChannelInsecureBaseURL {
+			abortWithOpenAiMessage(c, http.StatusServiceUnavailable, newAPIError.Error(), types.ErrorCodeChannelInsecureBaseURL)
+			return
+		}
 		c.Next()
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
 			service.RecordChannelAffinity(c, channel.Id)
@@ -158,6 +174,63 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// selectAffinityChannel 返回渠道亲和缓存中记录的渠道，渠道不可用或不再属于分组时返回 nil，由常规选择兜底
+func selectAffinityChannel(c *gin.Context, modelName string, usingGroup string, regions []string) (*model.Channel, string) {
+	preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelName, usingGroup)
+	if !found {
+		return nil, ""
+	}
+	preferred, err := model.CacheGetChannel(preferredChannelID)
+	if err != nil || preferred == nil || preferred.Status != common.ChannelStatusEnabled || !preferred.IsInSchedule(time.Now()) || !preferred.IsInRegions(regions) {
+		return nil, ""
+	}
+	if preferred.TenantId != common.GetContextKeyInt(c, constant.ContextKeyTenantId) {
+		return nil, ""
+	}
+	if usingGroup == "auto" {
+		userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		autoGroups := service.GetUserAutoGroup(userGroup)
+		for _, g := range autoGroups {
+			if model.IsChannelEnabledForGroupModel(g, modelName, preferred.Id) {
+				common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+				service.MarkChannelAffinityUsed(c, g, preferred.Id)
+				return preferred, g
+			}
+		}
+		return nil, ""
+	}
+	if model.IsChannelEnabledForGroupModel(usingGroup, modelName, preferred.Id) {
+		service.MarkChannelAffinityUsed(c, usingGroup, preferred.Id)
+		return preferred, usingGroup
+	}
+	return nil, ""
+}
+
+func dataResidencyMessage(regions []string) string {
+	return fmt.Sprintf("没有满足数据驻留要求（允许区域：%s）的可用渠道", strings.Join(regions, ","))
+}
+
+// resolveVirtualModel 将虚拟模型按权重替换为实际模型，后续选渠道、计费与转发均使用实际模型
+func resolveVirtualModel(c *gin.Context, modelRequest *ModelRequest) {
+	target, ok := model_setting.ResolveVirtualModel(modelRequest.Model)
+	if !ok {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyVirtualModel, modelRequest.Model)
+	modelRequest.Model = target
+}
+
+// applyBudgetPressure 全站当日消耗达到预算阈值时，将昂贵模型替换为配置的替代模型
+func applyBudgetPressure(c *gin.Context, modelRequest *ModelRequest) {
+	target, ok := service.ResolveBudgetPressureModel(modelRequest.Model)
+	if !ok {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyBudgetDowngradedFrom, modelRequest.Model)
+	c.Writer.Header().Set("X-Model-Downgraded-From", modelRequest.Model)
+	modelRequest.Model = target
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -341,11 +414,19 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if channel == nil {
 		return types.NewError(errors.New("channel is nil"), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
+	if err := channel.ValidateBaseURLSecurity(); err != nil {
+		// 详细原因包含渠道地址，只记录日志，不返回给调用方
+		logger.LogError(c, fmt.Sprintf("channel #%d rejected by base url security check: %s", channel.Id, err.Error()))
+		return types.NewError(errors.New("当前渠道暂不可用，请稍后再试或联系管理员"), types.ErrorCodeChannelInsecureBaseURL, types.ErrOptionWithSkipRetry())
+	}
 	common.SetContextKey(c, constant.ContextKeyChannelId, channel.Id)
 	common.SetContextKey(c, constant.ContextKeyChannelName, channel.Name)
 	common.SetContextKey(c, constant.ContextKeyChannelType, channel.Type)
 	common.SetContextKey(c, constant.ContextKeyChannelCreateTime, channel.CreatedTime)
-	common.SetContextKey(c, constant.ContextKeyChannelSetting, channel.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyChannelRegion, channel.GetRegion())
+	channelSetting := channel.GetSetting()
+	common.SetContextKey(c, constant.ContextKeyChannelSetting, channelSetting)
+	setServedByHeader(c, channelSetting)
 	common.SetContextKey(c, constant.ContextKeyChannelOtherSetting, channel.GetOtherSettings())
 	common.SetContextKey(c, constant.ContextKeyChannelParamOverride, channel.GetParamOverride())
 	common.SetContextKey(c, constant.ContextKeyChannelHeaderOverride, channel.GetHeaderOverride())
@@ -422,3 +503,26 @@ func extractModelNameFromGeminiPath(path string) string {
 	// 返回模型名部分
 	return path[startIndex : startIndex+colonIndex]
 }
+
+// setServedByHeader 按渠道配置的服务商标签设置 X-Served-By 响应头，未开启或未配置标签时不返回，
+// 重试切换渠道时会覆盖或移除上一个渠道的标签
+func setServedByHeader(c *gin.Context, setting dto.ChannelSettings) {
+	label := strings.TrimSpace(setting.ProviderLabel)
+	if !operation_setting.GetGeneralSetting().ServedByHeaderEnabled || label == "" {
+		c.Writer.Header().Del("X-Served-By")
+		return
+	}
+	c.Writer.Header().Set("X-Served-By", label)
+}
+
+// setModelDeprecationHeaders 请求已废弃的模型时返回 Warning 与 Sunset 响应头，请求照常处理
+func setModelDeprecationHeaders(c *gin.Context, modelName string) {
+	deprecation, ok := model_setting.GetModelDeprecation(modelName)
+	if !ok {
+		return
+	}
+	c.Writer.Header().Set("Warning", fmt.Sprintf("299 - %q", deprecation.WarningMessage(modelName)))
+	if sunset := deprecation.SunsetHTTPDate(); sunset != "" {
+		c.Writer.Header().Set("Sunset", sunset)
+	}
+}
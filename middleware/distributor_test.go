@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSetupContextForSelectedChannel_ServedByHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetGeneralSetting()
+	orig := setting.ServedByHeaderEnabled
+	t.Cleanup(func() { setting.ServedByHeaderEnabled = orig })
+
+	channelSetting := `{"provider_label":"azure-eu"}`
+	channel := &model.Channel{Id: 7, Type: constant.ChannelTypeOpenAI, Key: "sk-test", Setting: &channelSetting}
+
+	setting.ServedByHeaderEnabled = false
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	require.Nil(t, SetupContextForSelectedChannel(c, channel, "gpt-4o"))
+	require.Empty(t, c.Writer.Header().Get("X-Served-By"))
+
+	setting.ServedByHeaderEnabled = true
+	require.Nil(t, SetupContextForSelectedChannel(c, channel, "gpt-4o"))
+	c.String(200, "ok")
+	require.Equal(t, "azure-eu", recorder.Header().Get("X-Served-By"))
+	require.Equal(t, 7, common.GetContextKeyInt(c, constant.ContextKeyChannelId))
+}
+
+func TestSetupContextForSelectedChannel_InsecureBaseURLHidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	security := system_setting.GetChannelSecuritySetting()
+	orig := *security
+	t.Cleanup(func() { *security = orig })
+	security.HttpsOnly = true
+
+	baseURL := "http://10.0.0.8:8080"
+	channel := &model.Channel{Id: 7, Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: &baseURL}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	newAPIError := SetupContextForSelectedChannel(c, channel, "gpt-4o")
+	require.NotNil(t, newAPIError)
+	require.Equal(t, types.ErrorCodeChannelInsecureBaseURL, newAPIError.GetErrorCode())
+	require.NotContains(t, newAPIError.Error(), "10.0.0.8")
+
+	// 域名黑白名单的拒绝原因同样不返回给调用方，避免暴露渠道地址与安全策略
+	security.HttpsOnly = false
+	security.DeniedDomains = []string{"*.internal.example.com"}
+	deniedURL := "https://llm.internal.example.com"
+	channel.BaseURL = &deniedURL
+	newAPIError = SetupContextForSelectedChannel(c, channel, "gpt-4o")
+	require.NotNil(t, newAPIError)
+	require.NotContains(t, newAPIError.Error(), "internal.example.com")
+	require.NotContains(t, newAPIError.Error(), "禁止列表")
+
+	security.DeniedDomains = nil
+	security.AllowedDomains = []string{"api.openai.com"}
+	newAPIError = SetupContextForSelectedChannel(c, channel, "gpt-4o")
+	require.NotNil(t, newAPIError)
+	require.NotContains(t, newAPIError.Error(), "允许列表")
+}
+
+func TestSetModelDeprecationHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	settings := model_setting.GetModelDeprecationSettings()
+	orig := settings.Models
+	t.Cleanup(func() { settings.Models = orig })
+	settings.Models = map[string]model_setting.ModelDeprecation{
+		"gpt-3.5-turbo": {Deprecated: true, SunsetDate: "2026-12-01", Replacement: "gpt-4o-mini"},
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	setModelDeprecationHeaders(c, "gpt-3.5-turbo")
+	c.String(200, "ok")
+	require.Equal(t, 200, recorder.Code)
+	require.Equal(t, `299 - "model gpt-3.5-turbo is deprecated and will be removed on 2026-12-01, please migrate to gpt-4o-mini"`, recorder.Header().Get("Warning"))
+	require.Equal(t, "Tue, 01 Dec 2026 00:00:00 GMT", recorder.Header().Get("Sunset"))
+
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	setModelDeprecationHeaders(c, "gpt-4o")
+	c.String(200, "ok")
+	require.Empty(t, recorder.Header().Get("Warning"))
+}
+
+func TestSelectAffinityChannel_StickySessionAndFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	origDB, origMemoryCache := model.DB, common.MemoryCacheEnabled
+	t.Cleanup(func() { model.DB, common.MemoryCacheEnabled = origDB, origMemoryCache })
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Channel{}, &model.Ability{}))
+	model.DB = db
+	common.MemoryCacheEnabled = true
+	for _, channel := range []*model.Channel{
+		{Id: 1, Name: "a", Key: "sk-a", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"},
+		{Id: 2, Name: "b", Key: "sk-b", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"},
+	} {
+		require.NoError(t, db.Create(channel).Error)
+		require.NoError(t, channel.AddAbilities(nil))
+	}
+	model.InitChannelCache()
+
+	setting := operation_setting.GetChannelAffinitySetting()
+	orig := *setting
+	t.Cleanup(func() {
+		service.ClearChannelAffinityCacheAll()
+		*setting = orig
+	})
+	setting.Enabled = true
+	setting.Rules = []operation_setting.ChannelAffinityRule{{
+		Name:            "session",
+		ModelRegex:      []string{".*"},
+		KeySources:      []operation_setting.ChannelAffinityKeySource{{Type: "request_header", Key: "X-Session-Id"}},
+		TTLSeconds:      60,
+		IncludeRuleName: true,
+	}}
+
+	newContext := func(sessionId string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		c.Request.Header.Set("X-Session-Id", sessionId)
+		return c
+	}
+
+	// 首次请求没有记录，由常规选择决定渠道，成功后记录到会话
+	c := newContext("session-1")
+	channel, _ := selectAffinityChannel(c, "gpt-4o", "default", nil)
+	require.Nil(t, channel)
+	service.RecordChannelAffinity(c, 2)
+
+	// TTL 内同一会话固定到同一渠道
+	for i := 0; i < 3; i++ {
+		channel, group := selectAffinityChannel(newContext("session-1"), "gpt-4o", "default", nil)
+		require.NotNil(t, channel)
+		require.Equal(t, 2, channel.Id)
+		require.Equal(t, "default", group)
+	}
+	channel, _ = selectAffinityChannel(newContext("session-2"), "gpt-4o", "default", nil)
+	require.Nil(t, channel)
+
+	// 渠道被禁用后回退到常规选择
+	require.NoError(t, db.Model(&model.Channel{}).Where("id = ?", 2).Update("status", common.ChannelStatusAutoDisabled).Error)
+	model.InitChannelCache()
+	channel, _ = selectAffinityChannel(newContext("session-1"), "gpt-4o", "default", nil)
+	require.Nil(t, channel)
+}
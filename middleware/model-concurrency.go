@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	modelConcurrencyLimiters   = make(map[string]*limiter.PriorityLimiter)
+	modelConcurrencyLimitersMu sync.Mutex
+)
+
+func getModelConcurrencyLimiter(modelName string, limit int) *limiter.PriorityLimiter {
+	modelConcurrencyLimitersMu.Lock()
+	defer modelConcurrencyLimitersMu.Unlock()
+	l, ok := modelConcurrencyLimiters[modelName]
+	if !ok {
+		l = limiter.NewPriorityLimiter(limit, 0)
+		modelConcurrencyLimiters[modelName] = l
+	}
+	l.SetLimits(limit, 0)
+	return l
+}
+
+// acquireModelConcurrency 在选择渠道前占用模型的全局并发名额，名额不足时排队或直接返回 429。
+// 获取成功时返回释放函数，请求处理完成后调用；失败时已写入响应
+func acquireModelConcurrency(c *gin.Context, modelName string) (func(), bool) {
+	limitName := modelName
+	limit, ok := operation_setting.GetModelConcurrencyLimit(limitName)
+	if !ok {
+		limitName = ratio_setting.FormatMatchingModelName(modelName)
+		limit, ok = operation_setting.GetModelConcurrencyLimit(limitName)
+	}
+	if !ok {
+		return func() {}, true
+	}
+	l := getModelConcurrencyLimiter(limitName, limit)
+
+	group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+	if group == "" {
+		group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+	timeout := time.Duration(operation_setting.GetModelConcurrencySetting().QueueTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	err := l.Acquire(ctx, operation_setting.GetGroupPriority(group))
+	cancel()
+	if err != nil {
+		abortWithOpenAiMessage(c, http.StatusTooManyRequests, fmt.Sprintf("模型 %s 当前并发请求数已达上限 %d，请稍后重试", modelName, limit))
+		return nil, false
+	}
+	return l.Release, true
+}
+
+// GetModelConcurrencyInUse 返回模型当前占用的全局并发名额数
+func GetModelConcurrencyInUse(modelName string) int {
+	modelConcurrencyLimitersMu.Lock()
+	l, ok := modelConcurrencyLimiters[modelName]
+	modelConcurrencyLimitersMu.Unlock()
+	if !ok {
+		return 0
+	}
+	inUse, _ := l.InUse()
+	return inUse
+}
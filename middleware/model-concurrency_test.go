@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireModelConcurrency_CapsOnlyConfiguredModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetModelConcurrencySetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	*setting = operation_setting.ModelConcurrencySetting{Enabled: true, Limits: map[string]int{"capped-model": 2}}
+
+	newContext := func() (*gin.Context, *httptest.ResponseRecorder) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		return c, recorder
+	}
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		c, _ := newContext()
+		release, ok := acquireModelConcurrency(c, "capped-model")
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+
+	// 第 N+1 个并发请求被拒绝
+	c, recorder := newContext()
+	_, ok := acquireModelConcurrency(c, "capped-model")
+	require.False(t, ok)
+	require.True(t, c.IsAborted())
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+
+	// 其他模型不受影响
+	c, _ = newContext()
+	release, ok := acquireModelConcurrency(c, "other-model")
+	require.True(t, ok)
+	release()
+
+	// 开启排队后，名额释放时等待中的请求获得名额
+	setting.QueueTimeoutSeconds = 5
+	acquired := make(chan bool)
+	go func() {
+		c, _ := newContext()
+		release, ok := acquireModelConcurrency(c, "capped-model")
+		if ok {
+			release()
+		}
+		acquired <- ok
+	}()
+	time.Sleep(50 * time.Millisecond)
+	releases[0]()
+	require.True(t, <-acquired)
+	releases[1]()
+}
@@ -174,21 +174,7 @@ func ModelRequestRateLimit() func(c *gin.Context) {
 
 		// 计算限流参数
 		duration := int64(setting.ModelRequestRateLimitDurationMinutes * 60)
-		totalMaxCount := setting.ModelRequestRateLimitCount
-		successMaxCount := setting.ModelRequestRateLimitSuccessCount
-
-		// 获取分组
-		group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
-		if group == "" {
-			group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
-		}
-
-		//获取分组的限流配置
-		groupTotalCount, groupSuccessCount, found := setting.GetGroupRateLimit(group)
-		if found {
-			totalMaxCount = groupTotalCount
-			successMaxCount = groupSuccessCount
-		}
+		totalMaxCount, successMaxCount := getModelRequestRateLimits(c)
 
 		// 根据存储类型选择并执行限流处理器
 		if common.RedisEnabled {
@@ -198,3 +184,60 @@ func ModelRequestRateLimit() func(c *gin.Context) {
 		}
 	}
 }
+
+// getModelRequestRateLimits 获取请求所在分组的总请求数与成功请求数上限，分组未配置时使用全局配置
+func getModelRequestRateLimits(c *gin.Context) (totalMaxCount int, successMaxCount int) {
+	group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+	if group == "" {
+		group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+	if groupTotalCount, groupSuccessCount, found := setting.GetGroupRateLimit(group); found {
+		return groupTotalCount, groupSuccessCount
+	}
+	return setting.ModelRequestRateLimitCount, setting.ModelRequestRateLimitSuccessCount
+}
+
+// ModelRequestRateLimitUsage 用户在当前限流窗口内的请求限制与用量
+type ModelRequestRateLimitUsage struct {
+	Enabled            bool `json:"enabled"`
+	DurationMinutes    int  `json:"duration_minutes"`
+	MaxRequests        int  `json:"max_requests"`         // 总请求数上限（含失败），0 表示不限制
+	MaxSuccessRequests int  `json:"max_success_requests"` // 成功请求数上限
+	SuccessRequests    int  `json:"success_requests"`     // 窗口内已成功的请求数
+	RemainingRequests  int  `json:"remaining_requests"`   // 窗口内剩余的成功请求数
+}
+
+// GetModelRequestRateLimitUsage 按限流中间件的计数统计当前用户的请求用量
+func GetModelRequestRateLimitUsage(c *gin.Context) (*ModelRequestRateLimitUsage, error) {
+	usage := &ModelRequestRateLimitUsage{
+		Enabled:         setting.ModelRequestRateLimitEnabled,
+		DurationMinutes: setting.ModelRequestRateLimitDurationMinutes,
+	}
+	if !usage.Enabled {
+		return usage, nil
+	}
+	usage.MaxRequests, usage.MaxSuccessRequests = getModelRequestRateLimits(c)
+	duration := int64(setting.ModelRequestRateLimitDurationMinutes * 60)
+	userId := strconv.Itoa(c.GetInt("id"))
+	if common.RedisEnabled {
+		successKey := fmt.Sprintf("rateLimit:%s:%s", ModelRequestRateLimitSuccessCountMark, userId)
+		times, err := common.RDB.LRange(context.Background(), successKey, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		// 与写入时一致，按 timeFormat 解析后比较
+		now, _ := time.Parse(timeFormat, time.Now().Format(timeFormat))
+		for _, timeStr := range times {
+			requestTime, err := time.Parse(timeFormat, timeStr)
+			if err == nil && int64(now.Sub(requestTime).Seconds()) < duration {
+				usage.SuccessRequests++
+			}
+		}
+	} else {
+		usage.SuccessRequests = inMemoryRateLimiter.Count(ModelRequestRateLimitSuccessCountMark+userId, duration)
+	}
+	if usage.MaxSuccessRequests > 0 {
+		usage.RemainingRequests = max(usage.MaxSuccessRequests-usage.SuccessRequests, 0)
+	}
+	return usage, nil
+}
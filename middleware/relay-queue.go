@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+var relayQueue = limiter.NewPriorityLimiter(0, 0)
+
+// RelayQueue 并发已满时按令牌分组的优先级排队，高优先级请求先获得名额
+func RelayQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetRelayQueueSetting()
+		if !setting.Enabled || setting.MaxConcurrency <= 0 {
+			c.Next()
+			return
+		}
+		relayQueue.SetLimits(setting.MaxConcurrency, time.Duration(setting.AgingSeconds)*time.Second)
+
+		group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+		if group == "" {
+			group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		}
+		timeout := time.Duration(setting.QueueTimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		err := relayQueue.Acquire(ctx, operation_setting.GetGroupPriority(group))
+		cancel()
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusTooManyRequests, "当前请求过多，排队超时，请稍后重试")
+			return
+		}
+		defer relayQueue.Release()
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveTenant 开启多租户时从请求头或子域名解析租户，未指定租户的请求属于默认租户
+func ResolveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting := operation_setting.GetTenantSetting()
+		if !setting.Enabled {
+			c.Next()
+			return
+		}
+		tenantId := model.DefaultTenantId
+		if code := resolveTenantCode(c, setting); code != "" {
+			tenant, err := model.GetTenantByCode(code)
+			if err != nil || tenant.Status != model.TenantStatusEnabled {
+				abortWithOpenAiMessage(c, http.StatusNotFound, "租户不存在或已停用")
+				return
+			}
+			tenantId = tenant.Id
+		}
+		common.SetContextKey(c, constant.ContextKeyRequestTenantId, tenantId)
+		c.Next()
+	}
+}
+
+func resolveTenantCode(c *gin.Context, setting *operation_setting.TenantSetting) string {
+	if setting.Header != "" {
+		if code := strings.TrimSpace(c.GetHeader(setting.Header)); code != "" {
+			return strings.ToLower(code)
+		}
+	}
+	if setting.BaseDomain == "" {
+		return ""
+	}
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	suffix := "." + strings.ToLower(strings.TrimPrefix(setting.BaseDomain, "."))
+	code, ok := strings.CutSuffix(host, suffix)
+	// 只识别一级子域名，www 视为主站
+	if !ok || code == "" || code == "www" || strings.Contains(code, ".") {
+		return ""
+	}
+	return code
+}
+
+// RequestTenantMatches 判断请求解析出的租户与用户所属租户是否一致，未开启多租户时总是一致
+func RequestTenantMatches(c *gin.Context, tenantId int) bool {
+	requestTenantId, ok := common.GetContextKey(c, constant.ContextKeyRequestTenantId)
+	if !ok {
+		return true
+	}
+	return requestTenantId == tenantId
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 
@@ -28,6 +29,13 @@ type AbilityWithChannel struct {
 	ChannelType int `json:"channel_type"`
 }
 
+// GetGroupModelAbilities 获取分组下某模型的所有能力记录，包含已禁用的
+func GetGroupModelAbilities(group string, model string) ([]Ability, error) {
+	var abilities []Ability
+	err := DB.Where(map[string]interface{}{"group": group, "model": model}).Find(&abilities).Error
+	return abilities, err
+}
+
 func GetAllEnableAbilityWithChannels() ([]AbilityWithChannel, error) {
 	var abilities []AbilityWithChannel
 	err := DB.Table("abilities").
@@ -103,7 +111,8 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetChannel(group string, model string, retry int) (*Channel, error) {
+// GetChannel 从数据库按权重选择租户 tenantId 下的渠道，regions 不为空时只选择区域标签符合要求的渠道
+func GetChannel(group string, model string, retry int, regions []string, tenantId int) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
@@ -119,28 +128,34 @@ func GetChannel(group string, model string, retry int) (*Channel, error) {
 	if err != nil {
 		return nil, err
 	}
-	channel := Channel{}
-	if len(abilities) > 0 {
-		// Randomly choose one
+	// 按权重随机选择，选中的渠道不在定时窗口内、区域或租户不符合时剔除后重选
+	now := time.Now()
+	for len(abilities) > 0 {
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
 			weightSum += ability_.Weight + 10
 		}
 		// Randomly choose one
 		weight := common.GetRandomInt(int(weightSum))
-		for _, ability_ := range abilities {
+		chosen := len(abilities) - 1
+		for i, ability_ := range abilities {
 			weight -= int(ability_.Weight) + 10
 			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
 			if weight <= 0 {
-				channel.Id = ability_.ChannelId
+				chosen = i
 				break
 			}
 		}
-	} else {
-		return nil, nil
+		channel := Channel{}
+		if err = DB.First(&channel, "id = ?", abilities[chosen].ChannelId).Error; err != nil {
+			return &channel, err
+		}
+		if channel.TenantId == tenantId && channel.IsInSchedule(now) && channel.IsInRegions(regions) && !IsChannelModelUnavailable(channel.Id, model, now) {
+			return &channel, nil
+		}
+		abilities = append(abilities[:chosen], abilities[chosen+1:]...)
 	}
-	err = DB.First(&channel, "id = ?", channel.Id).Error
-	return &channel, err
+	return nil, nil
 }
 
 func (channel *Channel) AddAbilities(tx *gorm.DB) error {
@@ -264,11 +279,18 @@ func UpdateAbilityStatus(channelId int, status bool) error {
 	return DB.Model(&Ability{}).Where("channel_id = ?", channelId).Select("enabled").Update("enabled", status).Error
 }
 
-func UpdateAbilityStatusByTag(tag string, status bool) error {
-	return DB.Model(&Ability{}).Where("tag = ?", tag).Select("enabled").Update("enabled", status).Error
+// HasAbilityWithStatus 判断渠道是否存在指定启用状态的 ability
+func HasAbilityWithStatus(channelId int, enabled bool) (bool, error) {
+	var count int64
+	err := DB.Model(&Ability{}).Where("channel_id = ? and enabled = ?", channelId, enabled).Count(&count).Error
+	return count > 0, err
+}
+
+func UpdateAbilityStatusByTag(tag string, status bool, tenantId int) error {
+	return DB.Model(&Ability{}).Scopes(abilityTenantScope(tenantId)).Where("tag = ?", tag).Select("enabled").Update("enabled", status).Error
 }
 
-func UpdateAbilityByTag(tag string, newTag *string, priority *int64, weight *uint) error {
+func UpdateAbilityByTag(tenantId int, tag string, newTag *string, priority *int64, weight *uint) error {
 	ability := Ability{}
 	if newTag != nil {
 		ability.Tag = newTag
@@ -279,7 +301,7 @@ func UpdateAbilityByTag(tag string, newTag *string, priority *int64, weight *uin
 	if weight != nil {
 		ability.Weight = *weight
 	}
-	return DB.Model(&Ability{}).Where("tag = ?", tag).Updates(ability).Error
+	return DB.Model(&Ability{}).Scopes(abilityTenantScope(tenantId)).Where("tag = ?", tag).Updates(ability).Error
 }
 
 var fixLock = sync.Mutex{}
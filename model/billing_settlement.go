@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 结算批次状态
+const (
+	BillingSettlementStatusPending   = "pending"   // 等待（重新）上报
+	BillingSettlementStatusSucceeded = "succeeded" // 上报成功
+)
+
+// BillingSettlement 向外部计费系统上报的结算批次。已成功批次的最大 PeriodEnd 即结算水位线，
+// 创建时间不晚于水位线的消费日志视为已结算；payload 完整保存，重试时原样发送
+type BillingSettlement struct {
+	Id          int    `json:"id"`
+	BatchId     string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	PeriodStart int64  `json:"period_start" gorm:"bigint"`
+	PeriodEnd   int64  `json:"period_end" gorm:"bigint;index"`
+	Users       int    `json:"users"`
+	Quota       int64  `json:"quota" gorm:"bigint"`
+	Payload     string `json:"payload" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	Attempts    int    `json:"attempts" gorm:"default:0"`
+	LastError   string `json:"last_error" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// BillingSettlementUsage 单个用户在结算周期内的消费汇总
+type BillingSettlementUsage struct {
+	UserId           int    `json:"user_id"`
+	Username         string `json:"username"`
+	Quota            int64  `json:"quota"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// GetBillingSettlementWatermark 返回已结算到的时间点，尚未结算过时返回 0
+func GetBillingSettlementWatermark() (int64, error) {
+	var watermark int64
+	err := DB.Model(&BillingSettlement{}).Where("status = ?", BillingSettlementStatusSucceeded).
+		Select("coalesce(max(period_end), 0)").Scan(&watermark).Error
+	return watermark, err
+}
+
+// GetPendingBillingSettlement 返回最早一个未上报成功的批次，没有时返回 nil
+func GetPendingBillingSettlement() (*BillingSettlement, error) {
+	settlement := &BillingSettlement{}
+	err := DB.Where("status = ?", BillingSettlementStatusPending).Order("id asc").First(settlement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return settlement, err
+}
+
+func CreateBillingSettlement(settlement *BillingSettlement) error {
+	now := common.GetTimestamp()
+	settlement.CreatedAt = now
+	settlement.UpdatedAt = now
+	if settlement.Status == "" {
+		settlement.Status = BillingSettlementStatusPending
+	}
+	return DB.Create(settlement).Error
+}
+
+// RecordAttempt 记录一次上报结果，成功后水位线推进到 PeriodEnd
+func (settlement *BillingSettlement) RecordAttempt(sendErr error) error {
+	settlement.Attempts++
+	settlement.UpdatedAt = common.GetTimestamp()
+	if sendErr == nil {
+		settlement.Status = BillingSettlementStatusSucceeded
+		settlement.LastError = ""
+	} else {
+		settlement.LastError = sendErr.Error()
+	}
+	return DB.Model(settlement).Select("status", "attempts", "last_error", "updated_at").Updates(settlement).Error
+}
+
+// AggregateUnsettledUsage 按用户汇总 (start, end] 内的消费日志，要求消费日志未被采样
+func AggregateUnsettledUsage(start int64, end int64) ([]BillingSettlementUsage, error) {
+	usages := make([]BillingSettlementUsage, 0)
+	err := LOG_DB.Table("logs").
+		Select("user_id, max(username) as username, coalesce(sum(quota), 0) as quota, count(*) as requests, "+
+			"coalesce(sum(prompt_tokens), 0) as prompt_tokens, coalesce(sum(completion_tokens), 0) as completion_tokens").
+		Where("type = ? AND created_at > ? AND created_at <= ?", LogTypeConsume, start, end).
+		Group("user_id").Order("user_id asc").
+		Scan(&usages).Error
+	return usages, err
+}
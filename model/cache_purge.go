@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// 可清理的缓存类别
+const (
+	CacheToken   = "token"
+	CacheUser    = "user"
+	CacheChannel = "channel"
+	CachePricing = "pricing" // 定价与模型列表相关的缓存
+)
+
+var purgeableCaches = []string{CacheToken, CacheUser, CacheChannel, CachePricing}
+
+// PurgeCaches 清理指定类别的缓存并返回各类别清理的条目数，names 为空或包含 all 时清理全部；
+// 清理后的查询会重新从数据库加载
+func PurgeCaches(names []string) (map[string]int64, error) {
+	if len(names) == 0 || slices.Contains(names, "all") {
+		names = purgeableCaches
+	}
+	for _, name := range names {
+		if !slices.Contains(purgeableCaches, name) {
+			return nil, fmt.Errorf("不支持的缓存类别: %s，可选值: %s", name, strings.Join(purgeableCaches, ", "))
+		}
+	}
+
+	cleared := make(map[string]int64, len(names))
+	for _, name := range names {
+		var count int64
+		var err error
+		switch name {
+		case CacheToken:
+			count, err = purgeRedisCache("token:")
+		case CacheUser:
+			count, err = purgeRedisCache("user:")
+		case CacheChannel:
+			count = purgeChannelCache()
+		case CachePricing:
+			count = purgePricingCache()
+		}
+		if err != nil {
+			return cleared, fmt.Errorf("清理 %s 缓存失败: %w", name, err)
+		}
+		cleared[name] = count
+	}
+	return cleared, nil
+}
+
+func purgeRedisCache(prefix string) (int64, error) {
+	if !common.RedisEnabled {
+		return 0, nil
+	}
+	return common.RedisDelByPrefix(prefix)
+}
+
+// purgeChannelCache 丢弃内存中的渠道缓存并从数据库重建
+func purgeChannelCache() int64 {
+	if !common.MemoryCacheEnabled {
+		return 0
+	}
+	channelSyncLock.RLock()
+	count := int64(len(channelsIDM))
+	channelSyncLock.RUnlock()
+	InitChannelCache()
+	return count
+}
+
+// purgePricingCache 立即重新计算定价与模型列表缓存，并清除对外暴露的倍率缓存
+func purgePricingCache() int64 {
+	updatePricingLock.Lock()
+	count := int64(len(pricingMap))
+	updatePricingLock.Unlock()
+	RefreshPricing()
+	ratio_setting.InvalidateExposedDataCache()
+	return count
+}
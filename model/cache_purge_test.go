@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeCaches_ChannelCacheReloadsFromDB(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{})
+	setupScheduleChannelCache(t)
+
+	channel := &Channel{Name: "before", Key: "sk-test", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"}
+	require.NoError(t, DB.Create(channel).Error)
+	require.NoError(t, DB.Create(&Ability{Group: "default", Model: "gpt-4o", ChannelId: channel.Id, Enabled: true}).Error)
+	InitChannelCache()
+
+	// 绕过缓存直接修改数据库，缓存中仍是旧值
+	require.NoError(t, DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("name", "after").Error)
+	cached, err := CacheGetChannel(channel.Id)
+	require.NoError(t, err)
+	require.Equal(t, "before", cached.Name)
+
+	cleared, err := PurgeCaches([]string{CacheChannel})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{CacheChannel: 1}, cleared)
+
+	cached, err = CacheGetChannel(channel.Id)
+	require.NoError(t, err)
+	require.Equal(t, "after", cached.Name)
+}
+
+func TestPurgeCaches_RejectsUnknownCache(t *testing.T) {
+	_, err := PurgeCaches([]string{"response"})
+	require.Error(t, err)
+}
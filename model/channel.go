@@ -8,10 +8,12 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/system_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/samber/lo"
@@ -51,10 +53,13 @@ type Channel struct {
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
 
-	OtherSettings string `json:"settings" gorm:"column:settings"` // 其他设置，存储azure版本等不需要检索的信息，详见dto.ChannelOtherSettings
+	OtherSettings string `json:"settings" gorm:"column:settings"`  // 其他设置，存储azure版本等不需要检索的信息，详见dto.ChannelOtherSettings
+	TenantId      int    `json:"tenant_id" gorm:"index;default:0"` // 所属租户，0 为默认租户
 
 	// cache info
-	Keys []string `json:"-" gorm:"-"`
+	Keys     []string             `json:"-" gorm:"-"`
+	Schedule *dto.ChannelSchedule `json:"-" gorm:"-"`
+	Region   string               `json:"-" gorm:"-"`
 }
 
 type ChannelInfo struct {
@@ -431,6 +436,15 @@ func (channel *Channel) GetBaseURL() string {
 	return url
 }
 
+// ValidateBaseURLSecurity 按渠道安全配置校验实际使用的渠道地址，未填写时使用该类型的默认地址
+func (channel *Channel) ValidateBaseURLSecurity() error {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(constant.ChannelBaseURLs) {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	return system_setting.ValidateChannelBaseURL(baseURL)
+}
+
 func (channel *Channel) GetModelMapping() string {
 	if channel.ModelMapping == nil {
 		return ""
@@ -679,25 +693,26 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 	return true
 }
 
-func EnableChannelByTag(tag string) error {
-	err := DB.Model(&Channel{}).Where("tag = ?", tag).Update("status", common.ChannelStatusEnabled).Error
+func EnableChannelByTag(tag string, tenantId int) error {
+	err := DB.Model(&Channel{}).Scopes(TenantScope(tenantId)).Where("tag = ?", tag).Update("status", common.ChannelStatusEnabled).Error
 	if err != nil {
 		return err
 	}
-	err = UpdateAbilityStatusByTag(tag, true)
+	err = UpdateAbilityStatusByTag(tag, true, tenantId)
 	return err
 }
 
-func DisableChannelByTag(tag string) error {
-	err := DB.Model(&Channel{}).Where("tag = ?", tag).Update("status", common.ChannelStatusManuallyDisabled).Error
+func DisableChannelByTag(tag string, tenantId int) error {
+	err := DB.Model(&Channel{}).Scopes(TenantScope(tenantId)).Where("tag = ?", tag).Update("status", common.ChannelStatusManuallyDisabled).Error
 	if err != nil {
 		return err
 	}
-	err = UpdateAbilityStatusByTag(tag, false)
+	err = UpdateAbilityStatusByTag(tag, false, tenantId)
 	return err
 }
 
-func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint, paramOverride *string, headerOverride *string) error {
+// EditChannelByTag 批量修改租户内指定标签的渠道，tenantId 为 AllTenants 时不限租户
+func EditChannelByTag(tenantId int, tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint, paramOverride *string, headerOverride *string) error {
 	updateData := Channel{}
 	shouldReCreateAbilities := false
 	updatedTag := tag
@@ -730,7 +745,7 @@ func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *
 		updateData.HeaderOverride = headerOverride
 	}
 
-	err := DB.Model(&Channel{}).Where("tag = ?", tag).Updates(updateData).Error
+	err := DB.Model(&Channel{}).Scopes(TenantScope(tenantId)).Where("tag = ?", tag).Updates(updateData).Error
 	if err != nil {
 		return err
 	}
@@ -738,6 +753,9 @@ func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *
 		channels, err := GetChannelsByTag(updatedTag, false, false)
 		if err == nil {
 			for _, channel := range channels {
+				if tenantId != AllTenants && channel.TenantId != tenantId {
+					continue
+				}
 				err = channel.UpdateAbilities(nil)
 				if err != nil {
 					common.SysLog(fmt.Sprintf("failed to update abilities: channel_id=%d, tag=%s, error=%v", channel.Id, channel.GetTag(), err))
@@ -745,7 +763,7 @@ func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *
 			}
 		}
 	} else {
-		err := UpdateAbilityByTag(tag, newTag, priority, weight)
+		err := UpdateAbilityByTag(tenantId, tag, newTag, priority, weight)
 		if err != nil {
 			return err
 		}
@@ -773,14 +791,14 @@ func DeleteChannelByStatus(status int64) (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
-func DeleteDisabledChannel() (int64, error) {
-	result := DB.Where("status = ? or status = ?", common.ChannelStatusAutoDisabled, common.ChannelStatusManuallyDisabled).Delete(&Channel{})
+func DeleteDisabledChannel(tenantId int) (int64, error) {
+	result := DB.Scopes(TenantScope(tenantId)).Where("status = ? or status = ?", common.ChannelStatusAutoDisabled, common.ChannelStatusManuallyDisabled).Delete(&Channel{})
 	return result.RowsAffected, result.Error
 }
 
-func GetPaginatedTags(offset int, limit int) ([]*string, error) {
+func GetPaginatedTags(tenantId int, offset int, limit int) ([]*string, error) {
 	var tags []*string
-	err := DB.Model(&Channel{}).Select("DISTINCT tag").Where("tag != ''").Offset(offset).Limit(limit).Find(&tags).Error
+	err := DB.Model(&Channel{}).Scopes(TenantScope(tenantId)).Select("DISTINCT tag").Where("tag != ''").Offset(offset).Limit(limit).Find(&tags).Error
 	return tags, err
 }
 
@@ -849,9 +867,46 @@ func (channel *Channel) ValidateSettings() error {
 			return err
 		}
 	}
+	if err := channelParams.Schedule.Prepare(); err != nil {
+		return fmt.Errorf("schedule: %w", err)
+	}
 	return nil
 }
 
+// IsInSchedule 判断渠道当前是否处于定时可用窗口内，未配置定时的渠道始终返回 true
+func (channel *Channel) IsInSchedule(now time.Time) bool {
+	schedule := channel.Schedule
+	if schedule == nil && !common.MemoryCacheEnabled {
+		schedule = channel.GetSetting().Schedule
+	}
+	return schedule.IsActiveAt(now)
+}
+
+// GetRegion 获取渠道的区域标签，Region 仅在渠道缓存中预先解析，未解析时从渠道设置中读取
+func (channel *Channel) GetRegion() string {
+	if channel.Region == "" {
+		return channel.GetSetting().Region
+	}
+	return channel.Region
+}
+
+// IsInRegions 判断渠道是否满足数据驻留要求，regions 为空表示不限制
+func (channel *Channel) IsInRegions(regions []string) bool {
+	if len(regions) == 0 {
+		return true
+	}
+	region := channel.GetRegion()
+	if region == "" {
+		return false
+	}
+	for _, r := range regions {
+		if strings.EqualFold(r, region) {
+			return true
+		}
+	}
+	return false
+}
+
 func (channel *Channel) GetSetting() dto.ChannelSettings {
 	setting := dto.ChannelSettings{}
 	if channel.Setting != nil && *channel.Setting != "" {
@@ -965,9 +1020,9 @@ func CountAllChannels() (int64, error) {
 }
 
 // CountAllTags returns number of non-empty distinct tags
-func CountAllTags() (int64, error) {
+func CountAllTags(tenantId int) (int64, error) {
 	var total int64
-	err := DB.Model(&Channel{}).Where("tag is not null AND tag != ''").Distinct("tag").Count(&total).Error
+	err := DB.Model(&Channel{}).Scopes(TenantScope(tenantId)).Where("tag is not null AND tag != ''").Distinct("tag").Count(&total).Error
 	return total, err
 }
 
@@ -1006,3 +1061,12 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+// UpdateChannelModels 只更新渠道的模型列表，并同步 abilities
+func UpdateChannelModels(channel *Channel, models []string) error {
+	channel.Models = strings.Join(models, ",")
+	if err := DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("models", channel.Models).Error; err != nil {
+		return err
+	}
+	return channel.UpdateAbilities(nil)
+}
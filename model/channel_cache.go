@@ -26,6 +26,15 @@ func InitChannelCache() {
 	var channels []*Channel
 	DB.Find(&channels)
 	for _, channel := range channels {
+		channelSetting := channel.GetSetting()
+		channel.Region = channelSetting.Region
+		if schedule := channelSetting.Schedule; schedule != nil && schedule.Enabled {
+			if err := schedule.Prepare(); err != nil {
+				common.SysError(fmt.Sprintf("invalid schedule for channel #%d: %s", channel.Id, err.Error()))
+			} else {
+				channel.Schedule = schedule
+			}
+		}
 		newChannelId2channel[channel.Id] = channel
 	}
 	var abilities []*Ability
@@ -94,9 +103,20 @@ func SyncChannelCache(frequency int) {
 }
 
 func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+	return GetRandomSatisfiedChannelInRegions(group, model, retry, nil)
+}
+
+// GetRandomSatisfiedChannelInRegions 与 GetRandomSatisfiedChannel 相同，但只选择区域标签在 regions 内的渠道，
+// regions 为空表示不限制
+func GetRandomSatisfiedChannelInRegions(group string, model string, retry int, regions []string) (*Channel, error) {
+	return GetRandomSatisfiedChannelForTenant(group, model, retry, regions, DefaultTenantId)
+}
+
+// GetRandomSatisfiedChannelForTenant 与 GetRandomSatisfiedChannelInRegions 相同，但只选择属于租户 tenantId 的渠道
+func GetRandomSatisfiedChannelForTenant(group string, model string, retry int, regions []string, tenantId int) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetChannel(group, model, retry)
+		return GetChannel(group, model, retry, regions, tenantId)
 	}
 
 	channelSyncLock.RLock()
@@ -111,6 +131,15 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		channels = group2model2channels[group][normalizedModel]
 	}
 
+	now := time.Now()
+	channels = filterScheduledChannels(channels, now)
+	channels = filterChannels(channels, func(channel *Channel) bool {
+		return !IsChannelModelUnavailable(channel.Id, model, now)
+	})
+	channels = filterChannels(channels, func(channel *Channel) bool {
+		return channel.TenantId == tenantId && channel.IsInRegions(regions)
+	})
+
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -190,6 +219,36 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return nil, errors.New("channel not found")
 }
 
+// filterScheduledChannels 过滤掉当前不在定时窗口内的渠道，调用方需持有 channelSyncLock
+func filterScheduledChannels(channels []int, now time.Time) []int {
+	return filterChannels(channels, func(channel *Channel) bool {
+		return channel.IsInSchedule(now)
+	})
+}
+
+// filterChannels 保留 keep 返回 true 的渠道，调用方需持有 channelSyncLock
+func filterChannels(channels []int, keep func(*Channel) bool) []int {
+	var filtered []int
+	for i, channelId := range channels {
+		channel, ok := channelsIDM[channelId]
+		if !ok || keep(channel) {
+			if filtered != nil {
+				filtered = append(filtered, channelId)
+			}
+			continue
+		}
+		// 首次遇到需要剔除的渠道时才复制，避免常规情况下的内存分配
+		if filtered == nil {
+			filtered = make([]int, 0, len(channels))
+			filtered = append(filtered, channels[:i]...)
+		}
+	}
+	if filtered == nil {
+		return channels
+	}
+	return filtered
+}
+
 func CacheGetChannel(id int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
 		return GetChannelById(id, true)
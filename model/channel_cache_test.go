@@ -0,0 +1,140 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/stretchr/testify/require"
+)
+
+func scheduleAround(now time.Time, offset time.Duration) *dto.ChannelSchedule {
+	start := now.Add(offset - time.Hour).UTC()
+	end := now.Add(offset + time.Hour).UTC()
+	schedule := &dto.ChannelSchedule{
+		Enabled:  true,
+		Timezone: "UTC",
+		Windows: []dto.ChannelScheduleWindow{{
+			Start: fmt.Sprintf("%02d:%02d", start.Hour(), start.Minute()),
+			End:   fmt.Sprintf("%02d:%02d", end.Hour(), end.Minute()),
+		}},
+	}
+	if err := schedule.Prepare(); err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+func setupScheduleChannelCache(t *testing.T, channels ...*Channel) {
+	origEnabled := common.MemoryCacheEnabled
+	origIDM := channelsIDM
+	origGroups := group2model2channels
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = origEnabled
+		channelsIDM = origIDM
+		group2model2channels = origGroups
+	})
+	common.MemoryCacheEnabled = true
+	channelsIDM = make(map[int]*Channel)
+	ids := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		channelsIDM[channel.Id] = channel
+		ids = append(ids, channel.Id)
+	}
+	group2model2channels = map[string]map[string][]int{
+		"default": {"gpt-4o": ids},
+	}
+}
+
+func TestGetRandomSatisfiedChannel_SkipsOutOfWindowChannel(t *testing.T) {
+	now := time.Now()
+	setupScheduleChannelCache(t,
+		&Channel{Id: 1, Status: common.ChannelStatusEnabled, Schedule: scheduleAround(now, 0)},
+		&Channel{Id: 2, Status: common.ChannelStatusEnabled, Schedule: scheduleAround(now, 12*time.Hour)},
+	)
+
+	for i := 0; i < 50; i++ {
+		channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0)
+		require.NoError(t, err)
+		require.NotNil(t, channel)
+		require.Equal(t, 1, channel.Id)
+	}
+}
+
+func TestGetRandomSatisfiedChannel_AllOutOfWindow(t *testing.T) {
+	now := time.Now()
+	setupScheduleChannelCache(t,
+		&Channel{Id: 1, Status: common.ChannelStatusEnabled, Schedule: scheduleAround(now, 12*time.Hour)},
+	)
+
+	channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0)
+	require.NoError(t, err)
+	require.Nil(t, channel)
+}
+
+func TestGetRandomSatisfiedChannelInRegions_OnlyCompliantChannels(t *testing.T) {
+	setupScheduleChannelCache(t,
+		&Channel{Id: 1, Status: common.ChannelStatusEnabled, Region: "us"},
+		&Channel{Id: 2, Status: common.ChannelStatusEnabled, Region: "eu"},
+		&Channel{Id: 3, Status: common.ChannelStatusEnabled},
+		&Channel{Id: 4, Status: common.ChannelStatusEnabled, Region: "EU"},
+	)
+
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		channel, err := GetRandomSatisfiedChannelInRegions("default", "gpt-4o", 0, []string{"eu"})
+		require.NoError(t, err)
+		require.NotNil(t, channel)
+		require.Contains(t, []int{2, 4}, channel.Id)
+		seen[channel.Id] = true
+	}
+	require.Len(t, seen, 2)
+
+	// 没有符合要求的渠道时不回退到其他区域
+	channel, err := GetRandomSatisfiedChannelInRegions("default", "gpt-4o", 0, []string{"cn"})
+	require.NoError(t, err)
+	require.Nil(t, channel)
+
+	// 没有数据驻留要求时所有渠道都可选
+	channel, err = GetRandomSatisfiedChannelInRegions("default", "gpt-4o", 0, nil)
+	require.NoError(t, err)
+	require.NotNil(t, channel)
+}
+
+func TestGetRandomSatisfiedChannelForTenant_IsolatesTenants(t *testing.T) {
+	setupScheduleChannelCache(t,
+		&Channel{Id: 1, Status: common.ChannelStatusEnabled},
+		&Channel{Id: 2, Status: common.ChannelStatusEnabled, TenantId: 7},
+		&Channel{Id: 3, Status: common.ChannelStatusEnabled, TenantId: 8},
+	)
+
+	for tenantId, want := range map[int]int{DefaultTenantId: 1, 7: 2, 8: 3} {
+		for i := 0; i < 30; i++ {
+			channel, err := GetRandomSatisfiedChannelForTenant("default", "gpt-4o", 0, nil, tenantId)
+			require.NoError(t, err)
+			require.NotNil(t, channel)
+			require.Equal(t, want, channel.Id)
+		}
+	}
+
+	// 租户没有可用渠道时不会借用其他租户的渠道
+	channel, err := GetRandomSatisfiedChannelForTenant("default", "gpt-4o", 0, nil, 9)
+	require.NoError(t, err)
+	require.Nil(t, channel)
+}
+
+func TestChannelGetRegion_FallsBackToSetting(t *testing.T) {
+	origMemoryCache := common.MemoryCacheEnabled
+	t.Cleanup(func() { common.MemoryCacheEnabled = origMemoryCache })
+	common.MemoryCacheEnabled = true
+
+	// 未经过渠道缓存加载的渠道（例如直接从数据库读取）也按设置中的区域判断
+	channel := &Channel{Id: 1}
+	channel.SetSetting(dto.ChannelSettings{Region: "eu"})
+	require.Equal(t, "eu", channel.GetRegion())
+	require.True(t, channel.IsInRegions([]string{"eu"}))
+	require.False(t, channel.IsInRegions([]string{"us"}))
+}
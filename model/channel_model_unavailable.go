@@ -0,0 +1,44 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 上游确认不承接某模型的渠道，过期前选路时跳过，仅保存在本节点内存
+var (
+	channelModelUnavailable     = make(map[string]time.Time)
+	channelModelUnavailableLock sync.RWMutex
+)
+
+func channelModelUnavailableKey(channelId int, model string) string {
+	return fmt.Sprintf("%d:%s", channelId, model)
+}
+
+// MarkChannelModelUnavailable 标记渠道在 ttl 内不承接该模型
+func MarkChannelModelUnavailable(channelId int, model string, ttl time.Duration) {
+	channelModelUnavailableLock.Lock()
+	defer channelModelUnavailableLock.Unlock()
+	channelModelUnavailable[channelModelUnavailableKey(channelId, model)] = time.Now().Add(ttl)
+}
+
+// IsChannelModelUnavailable 判断渠道当前是否被标记为不承接该模型
+func IsChannelModelUnavailable(channelId int, model string, now time.Time) bool {
+	key := channelModelUnavailableKey(channelId, model)
+	channelModelUnavailableLock.RLock()
+	expireAt, ok := channelModelUnavailable[key]
+	channelModelUnavailableLock.RUnlock()
+	if !ok {
+		return false
+	}
+	if now.Before(expireAt) {
+		return true
+	}
+	channelModelUnavailableLock.Lock()
+	if expireAt, ok = channelModelUnavailable[key]; ok && !now.Before(expireAt) {
+		delete(channelModelUnavailable, key)
+	}
+	channelModelUnavailableLock.Unlock()
+	return false
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DailySpend 全站每日消耗额度，用于预算控制
+type DailySpend struct {
+	Day       string `json:"day" gorm:"primaryKey;type:varchar(10)"` // 2006-01-02
+	UsedQuota int64  `json:"used_quota" gorm:"bigint;default:0"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func GetDailySpend(day string) (int64, error) {
+	spend := DailySpend{}
+	err := DB.Where("day = ?", day).First(&spend).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return spend.UsedQuota, err
+}
+
+func AddDailySpend(day string, quota int64) error {
+	if quota == 0 {
+		return nil
+	}
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&DailySpend{Day: day, UpdatedAt: common.GetTimestamp()}).Error
+	if err != nil {
+		return err
+	}
+	return DB.Model(&DailySpend{}).Where("day = ?", day).Updates(map[string]interface{}{
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+		"updated_at": common.GetTimestamp(),
+	}).Error
+}
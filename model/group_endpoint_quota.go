@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrGroupEndpointQuotaExhausted 分组接口额度不足
+var ErrGroupEndpointQuotaExhausted = errors.New("group endpoint quota exhausted")
+
+// GroupEndpointQuotaUsage 分组在某类接口上的已用额度，额度上限由 operation_setting 配置
+type GroupEndpointQuotaUsage struct {
+	Id        int    `json:"id"`
+	Group     string `json:"group" gorm:"column:group_name;type:varchar(64);uniqueIndex:idx_group_endpoint_quota"`
+	Endpoint  string `json:"endpoint" gorm:"type:varchar(32);uniqueIndex:idx_group_endpoint_quota"`
+	UsedQuota int64  `json:"used_quota" gorm:"bigint;default:0"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func GetGroupEndpointQuotaUsed(group string, endpoint string) (int64, error) {
+	usage := GroupEndpointQuotaUsage{}
+	err := DB.Where("group_name = ? AND endpoint = ?", group, endpoint).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return usage.UsedQuota, err
+}
+
+func GetAllGroupEndpointQuotaUsages() ([]*GroupEndpointQuotaUsage, error) {
+	var usages []*GroupEndpointQuotaUsage
+	err := DB.Order("group_name asc, endpoint asc").Find(&usages).Error
+	return usages, err
+}
+
+// ConsumeGroupEndpointQuota 在不超过 limit 的前提下增加已用额度，额度不足时返回 ErrGroupEndpointQuotaExhausted
+func ConsumeGroupEndpointQuota(group string, endpoint string, quota int, limit int64) error {
+	if quota <= 0 {
+		return nil
+	}
+	if err := ensureGroupEndpointQuotaUsage(group, endpoint); err != nil {
+		return err
+	}
+	result := DB.Model(&GroupEndpointQuotaUsage{}).
+		Where("group_name = ? AND endpoint = ? AND used_quota + ? <= ?", group, endpoint, quota, limit).
+		Updates(map[string]interface{}{
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+			"updated_at": common.GetTimestamp(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGroupEndpointQuotaExhausted
+	}
+	return nil
+}
+
+// AdjustGroupEndpointQuota 按实际消耗调整已用额度，delta 为负数时返还
+func AdjustGroupEndpointQuota(group string, endpoint string, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := ensureGroupEndpointQuotaUsage(group, endpoint); err != nil {
+		return err
+	}
+	return DB.Model(&GroupEndpointQuotaUsage{}).
+		Where("group_name = ? AND endpoint = ?", group, endpoint).
+		Updates(map[string]interface{}{
+			"used_quota": gorm.Expr("used_quota + ?", delta),
+			"updated_at": common.GetTimestamp(),
+		}).Error
+}
+
+// ResetGroupEndpointQuota 清零分组接口的已用额度
+func ResetGroupEndpointQuota(group string, endpoint string) error {
+	return DB.Model(&GroupEndpointQuotaUsage{}).
+		Where("group_name = ? AND endpoint = ?", group, endpoint).
+		Updates(map[string]interface{}{
+			"used_quota": 0,
+			"updated_at": common.GetTimestamp(),
+		}).Error
+}
+
+func ensureGroupEndpointQuotaUsage(group string, endpoint string) error {
+	usage := GroupEndpointQuotaUsage{Group: group, Endpoint: endpoint, UpdatedAt: common.GetTimestamp()}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+)
+
+const groupTopUpBatchSize = 100
+
+// GroupTopUpRecord 分组批量充值的入账记录，(operation_id, user_id) 唯一，用于防止重试时重复入账
+type GroupTopUpRecord struct {
+	Id          int    `json:"id"`
+	OperationId string `json:"operation_id" gorm:"type:varchar(64);uniqueIndex:idx_group_topup_op_user"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_group_topup_op_user;index"`
+	Group       string `json:"group" gorm:"type:varchar(64)"`
+	Quota       int    `json:"quota"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+}
+
+// GroupTopUpResult 分组批量充值结果，Credited/TotalQuota 为本次调用新入账的部分
+type GroupTopUpResult struct {
+	OperationId string `json:"operation_id"`
+	Group       string `json:"group"`
+	Credited    int    `json:"credited"`
+	Skipped     int    `json:"skipped"`
+	TotalQuota  int64  `json:"total_quota"`
+}
+
+// TopUpGroupUsers 为分组内所有未禁用的用户增加额度，按批次在事务中入账并写入记录。
+// 同一 operationId 重复调用时，已入账的用户会被跳过，不会重复充值。
+func TopUpGroupUsers(group string, quota int, operationId string) (*GroupTopUpResult, error) {
+	if group == "" {
+		return nil, errors.New("分组不能为空")
+	}
+	if quota <= 0 {
+		return nil, errors.New("充值额度必须大于0")
+	}
+	if operationId == "" || len(operationId) > 64 {
+		return nil, errors.New("操作 id 不能为空且长度不能超过 64")
+	}
+	var existing GroupTopUpRecord
+	err := DB.Where("operation_id = ?", operationId).First(&existing).Error
+	if err == nil && (existing.Group != group || existing.Quota != quota) {
+		return nil, errors.New("该操作 id 已用于其他分组或其他充值额度")
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	result := &GroupTopUpResult{OperationId: operationId, Group: group}
+	lastId := 0
+	for {
+		var users []User
+		err := DB.Select("id", "username").
+			Where(commonGroupCol+" = ? AND status != ? AND id > ?", group, common.UserStatusDisabled, lastId).
+			Order("id asc").Limit(groupTopUpBatchSize).Find(&users).Error
+		if err != nil {
+			return result, err
+		}
+		if len(users) == 0 {
+			break
+		}
+		lastId = users[len(users)-1].Id
+		userIds := make([]int, 0, len(users))
+		usernames := make(map[int]string, len(users))
+		for _, user := range users {
+			userIds = append(userIds, user.Id)
+			usernames[user.Id] = user.Username
+		}
+
+		credited, err := topUpGroupUserBatch(group, quota, operationId, userIds)
+		if err != nil {
+			return result, err
+		}
+		result.Credited += len(credited)
+		result.Skipped += len(userIds) - len(credited)
+		result.TotalQuota += int64(quota) * int64(len(credited))
+
+		logs := make([]*Log, 0, len(credited))
+		for _, userId := range credited {
+			_ = invalidateUserCache(userId)
+			logs = append(logs, &Log{
+				UserId:    userId,
+				Username:  usernames[userId],
+				CreatedAt: common.GetTimestamp(),
+				Type:      LogTypeTopup,
+				Content:   fmt.Sprintf("管理员为分组 %s 批量充值 %s（操作 %s）", group, logger.LogQuota(quota), operationId),
+				Quota:     quota,
+				Group:     group,
+			})
+		}
+		if len(logs) > 0 {
+			if err := LOG_DB.CreateInBatches(logs, groupTopUpBatchSize).Error; err != nil {
+				common.SysLog("failed to record group topup logs: " + err.Error())
+			}
+		}
+	}
+	return result, nil
+}
+
+// topUpGroupUserBatch 在一个事务中为一批用户入账，返回本次实际入账的用户
+func topUpGroupUserBatch(group string, quota int, operationId string, userIds []int) ([]int, error) {
+	var credited []int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var done []int
+		if err := tx.Model(&GroupTopUpRecord{}).
+			Where("operation_id = ? AND user_id IN ?", operationId, userIds).
+			Pluck("user_id", &done).Error; err != nil {
+			return err
+		}
+		doneSet := make(map[int]struct{}, len(done))
+		for _, id := range done {
+			doneSet[id] = struct{}{}
+		}
+		now := common.GetTimestamp()
+		records := make([]GroupTopUpRecord, 0, len(userIds))
+		for _, id := range userIds {
+			if _, ok := doneSet[id]; ok {
+				continue
+			}
+			credited = append(credited, id)
+			records = append(records, GroupTopUpRecord{OperationId: operationId, UserId: id, Group: group, Quota: quota, CreatedAt: now})
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id IN ?", credited).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credited, nil
+}
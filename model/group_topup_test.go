@@ -0,0 +1,65 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func seedGroupTopUpUsers(t *testing.T) (vip1 User, vip2 User, disabled User, other User) {
+	vip1 = User{Username: "vip1", Password: "12345678", Group: "vip", Quota: 100, AffCode: "a1"}
+	vip2 = User{Username: "vip2", Password: "12345678", Group: "vip", Quota: 0, AffCode: "a2"}
+	disabled = User{Username: "vip3", Password: "12345678", Group: "vip", Quota: 0, AffCode: "a3", Status: common.UserStatusDisabled}
+	other = User{Username: "free", Password: "12345678", Group: "default", Quota: 0, AffCode: "a4"}
+	for _, user := range []*User{&vip1, &vip2, &disabled, &other} {
+		require.NoError(t, DB.Create(user).Error)
+	}
+	return
+}
+
+func requireQuota(t *testing.T, userId int, want int) {
+	t.Helper()
+	var user User
+	require.NoError(t, DB.First(&user, "id = ?", userId).Error)
+	require.Equal(t, want, user.Quota)
+}
+
+func TestTopUpGroupUsers_CreditsEnabledUsersInGroup(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{}, &GroupTopUpRecord{})
+	vip1, vip2, disabled, other := seedGroupTopUpUsers(t)
+
+	result, err := TopUpGroupUsers("vip", 500, "campaign-1")
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Credited)
+	require.EqualValues(t, 1000, result.TotalQuota)
+
+	requireQuota(t, vip1.Id, 600)
+	requireQuota(t, vip2.Id, 500)
+	requireQuota(t, disabled.Id, 0)
+	requireQuota(t, other.Id, 0)
+
+	var ledger int64
+	require.NoError(t, LOG_DB.Model(&Log{}).Where("type = ? AND quota = ?", LogTypeTopup, 500).Count(&ledger).Error)
+	require.EqualValues(t, 2, ledger)
+}
+
+func TestTopUpGroupUsers_IdempotentOnOperationId(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{}, &GroupTopUpRecord{})
+	vip1, vip2, _, _ := seedGroupTopUpUsers(t)
+
+	_, err := TopUpGroupUsers("vip", 500, "campaign-1")
+	require.NoError(t, err)
+	result, err := TopUpGroupUsers("vip", 500, "campaign-1")
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Credited)
+	require.Equal(t, 2, result.Skipped)
+	require.EqualValues(t, 0, result.TotalQuota)
+	requireQuota(t, vip1.Id, 600)
+	requireQuota(t, vip2.Id, 500)
+
+	// 同一操作 id 不能用于不同的额度
+	_, err = TopUpGroupUsers("vip", 800, "campaign-1")
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+var (
+	lastUsedWrites   = make(map[string]int64)
+	lastUsedWritesMu sync.Mutex
+
+	lastUsedNow = common.GetTimestamp
+	// lastUsedGo 异步执行写入，测试中替换为同步执行
+	lastUsedGo = gopool.Go
+)
+
+// shouldWriteLastUsed 判断距上次写入是否已超过防抖间隔，是则记录本次写入时间
+func shouldWriteLastUsed(key string, now int64, debounce int64) bool {
+	lastUsedWritesMu.Lock()
+	defer lastUsedWritesMu.Unlock()
+	if last, ok := lastUsedWrites[key]; ok && now-last < debounce {
+		return false
+	}
+	lastUsedWrites[key] = now
+	return true
+}
+
+// RecordTokenUsage 异步记录令牌与用户的最近使用时间和 IP，同一令牌或用户在防抖间隔内只写一次
+func RecordTokenUsage(tokenId int, userId int, ip string) {
+	setting := operation_setting.GetUsageTrackingSetting()
+	if !setting.Enabled {
+		return
+	}
+	now := lastUsedNow()
+	if !setting.RecordIp {
+		ip = ""
+	}
+	if tokenId > 0 && shouldWriteLastUsed(fmt.Sprintf("token:%d", tokenId), now, setting.DebounceSeconds) {
+		updates := map[string]interface{}{"accessed_time": now}
+		if ip != "" {
+			updates["last_used_ip"] = ip
+		}
+		lastUsedGo(func() {
+			if err := DB.Model(&Token{}).Where("id = ?", tokenId).UpdateColumns(updates).Error; err != nil {
+				common.SysLog(fmt.Sprintf("failed to record token usage: tokenId=%d, error=%s", tokenId, err.Error()))
+			}
+		})
+	}
+	if userId > 0 && shouldWriteLastUsed(fmt.Sprintf("user:%d", userId), now, setting.DebounceSeconds) {
+		updates := map[string]interface{}{"last_used_time": now}
+		if ip != "" {
+			updates["last_used_ip"] = ip
+		}
+		lastUsedGo(func() {
+			if err := DB.Model(&User{}).Where("id = ?", userId).UpdateColumns(updates).Error; err != nil {
+				common.SysLog(fmt.Sprintf("failed to record user usage: userId=%d, error=%s", userId, err.Error()))
+			}
+		})
+	}
+}
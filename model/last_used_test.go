@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordTokenUsage_DebouncedWrites(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	setting := operation_setting.GetUsageTrackingSetting()
+	origSetting, origNow, origGo := *setting, lastUsedNow, lastUsedGo
+	t.Cleanup(func() {
+		*setting, lastUsedNow, lastUsedGo = origSetting, origNow, origGo
+		lastUsedWrites = make(map[string]int64)
+	})
+	*setting = operation_setting.UsageTrackingSetting{Enabled: true, DebounceSeconds: 60, RecordIp: true}
+	lastUsedWrites = make(map[string]int64)
+	var now int64 = 1_700_000_000
+	lastUsedNow = func() int64 { return now }
+	writes := 0
+	lastUsedGo = func(f func()) {
+		writes++
+		f()
+	}
+
+	user := User{Username: "tracked", Password: "12345678", AffCode: "t1"}
+	require.NoError(t, DB.Create(&user).Error)
+	token := Token{UserId: user.Id, Key: "tracked-key", Name: "t"}
+	require.NoError(t, DB.Create(&token).Error)
+	reload := func() (Token, User) {
+		var gotToken Token
+		var gotUser User
+		require.NoError(t, DB.First(&gotToken, token.Id).Error)
+		require.NoError(t, DB.First(&gotUser, user.Id).Error)
+		return gotToken, gotUser
+	}
+
+	RecordTokenUsage(token.Id, user.Id, "10.0.0.1")
+	gotToken, gotUser := reload()
+	require.Equal(t, now, gotToken.AccessedTime)
+	require.Equal(t, "10.0.0.1", gotToken.LastUsedIp)
+	require.Equal(t, now, gotUser.LastUsedTime)
+	require.Equal(t, "10.0.0.1", gotUser.LastUsedIp)
+	require.Equal(t, 2, writes)
+
+	// 防抖间隔内的请求不写库
+	for i := 0; i < 10; i++ {
+		now++
+		RecordTokenUsage(token.Id, user.Id, "10.0.0.2")
+	}
+	require.Equal(t, 2, writes)
+	gotToken, _ = reload()
+	require.Equal(t, "10.0.0.1", gotToken.LastUsedIp)
+
+	// 超过间隔后再次写入；关闭 IP 记录后只更新时间
+	now += 60
+	setting.RecordIp = false
+	RecordTokenUsage(token.Id, user.Id, "10.0.0.3")
+	require.Equal(t, 4, writes)
+	gotToken, gotUser = reload()
+	require.Equal(t, now, gotToken.AccessedTime)
+	require.Equal(t, "10.0.0.1", gotToken.LastUsedIp)
+	require.Equal(t, now, gotUser.LastUsedTime)
+}
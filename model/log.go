@@ -9,6 +9,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -37,6 +38,23 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	RequestId        string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	// 以下字段按日志配置在查询时换算，不落库
+	Cost     *float64 `json:"cost,omitempty" gorm:"-"`
+	Currency string   `json:"currency,omitempty" gorm:"-"`
+}
+
+// FillLogCost 按配置的货币与精度为日志附加换算后的金额
+func FillLogCost(logs []*Log) {
+	if !operation_setting.GetLogSetting().CostEnabled {
+		return
+	}
+	currency := operation_setting.GetLogCostCurrency()
+	for _, log := range logs {
+		cost := operation_setting.QuotaToLogCost(log.Quota)
+		log.Cost = &cost
+		log.Currency = currency
+	}
 }
 
 // don't use iota, avoid change log type value
@@ -132,7 +150,8 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 			}
 			return ""
 		}(),
-		Other: otherStr,
+		Other:     otherStr,
+		RequestId: c.GetString(common.RequestIdKey),
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
@@ -161,6 +180,18 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 	logger.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
 	username := c.GetString("username")
+	// 采样只影响详细日志，额度统计仍按每次请求记录
+	if operation_setting.ShouldSampleSuccessLog(params.Group) {
+		createConsumeLog(c, userId, username, params)
+	}
+	if common.DataExportEnabled {
+		gopool.Go(func() {
+			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+		})
+	}
+}
+
+func createConsumeLog(c *gin.Context, userId int, username string, params RecordConsumeLogParams) {
 	otherStr := common.MapToJsonStr(params.Other)
 	// 判断是否需要记录 IP
 	needRecordIp := false
@@ -191,25 +222,19 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 			}
 			return ""
 		}(),
-		Other: otherStr,
+		Other:     otherStr,
+		RequestId: c.GetString(common.RequestIdKey),
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
 		logger.LogError(c, "failed to record log: "+err.Error())
 	}
-	if common.DataExportEnabled {
-		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
-		})
-	}
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
-	var tx *gorm.DB
-	if logType == LogTypeUnknown {
-		tx = LOG_DB
-	} else {
-		tx = LOG_DB.Where("logs.type = ?", logType)
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string, tenantId int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Scopes(LogTenantScope(tenantId))
+	if logType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", logType)
 	}
 
 	if modelName != "" {
@@ -305,8 +330,23 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
-func SearchAllLogs(keyword string) (logs []*Log, err error) {
-	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
+// GetTokenLogs 分页获取用户某个令牌的请求日志（消费与错误），按时间倒序
+func GetTokenLogs(userId int, tokenId int, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.user_id = ? AND logs.token_id = ? AND logs.type IN ?", userId, tokenId, []int{LogTypeConsume, LogTypeError})
+	err = tx.Model(&Log{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	formatUserLogs(logs)
+	return logs, total, err
+}
+
+func SearchAllLogs(keyword string, tenantId int) (logs []*Log, err error) {
+	err = LOG_DB.Scopes(LogTenantScope(tenantId)).Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
 }
 
@@ -322,11 +362,11 @@ type Stat struct {
 	Tpm   int `json:"tpm"`
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string, tenantId int) (stat Stat) {
+	tx := LOG_DB.Table("logs").Scopes(LogTenantScope(tenantId)).Select("sum(quota) quota")
 
 	// 为rpm和tpm创建单独的查询
-	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
+	rpmTpmQuery := LOG_DB.Table("logs").Scopes(LogTenantScope(tenantId)).Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
 
 	if username != "" {
 		tx = tx.Where("username = ?", username)
@@ -368,6 +408,55 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	return stat
 }
 
+// ChannelUsage 渠道在时间范围内的用量汇总，错误数依赖错误日志，未开启错误日志时为 0
+type ChannelUsage struct {
+	ChannelId        int     `json:"channel_id"`
+	RequestCount     int64   `json:"request_count"`
+	ErrorCount       int64   `json:"error_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Quota            int64   `json:"quota"`
+	ErrorRate        float64 `json:"error_rate"`
+	AvgUseTime       float64 `json:"avg_use_time"` // 成功请求的平均耗时（秒）
+	// 按日志配置换算的金额，未开启时不返回
+	Cost     *float64 `json:"cost,omitempty" gorm:"-"`
+	Currency string   `json:"currency,omitempty" gorm:"-"`
+}
+
+// GetChannelUsage 在数据库中按渠道聚合消费日志与错误日志
+func GetChannelUsage(channelId int, startTimestamp int64, endTimestamp int64) (*ChannelUsage, error) {
+	usage := &ChannelUsage{ChannelId: channelId}
+	tx := LOG_DB.Table("logs").Select(
+		"COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS request_count, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS error_count, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN prompt_tokens ELSE 0 END), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN completion_tokens ELSE 0 END), 0) AS completion_tokens, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN quota ELSE 0 END), 0) AS quota, "+
+			"COALESCE(AVG(CASE WHEN type = ? THEN use_time END), 0) AS avg_use_time",
+		LogTypeConsume, LogTypeError, LogTypeConsume, LogTypeConsume, LogTypeConsume, LogTypeConsume,
+	).Where("channel_id = ? AND type IN ?", channelId, []int{LogTypeConsume, LogTypeError})
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if err := tx.Scan(usage).Error; err != nil {
+		return nil, err
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	if total := usage.RequestCount + usage.ErrorCount; total > 0 {
+		usage.ErrorRate = float64(usage.ErrorCount) / float64(total)
+	}
+	if operation_setting.GetLogSetting().CostEnabled {
+		cost := operation_setting.QuotaToLogCost(int(usage.Quota))
+		usage.Cost = &cost
+		usage.Currency = operation_setting.GetLogCostCurrency()
+	}
+	return usage, nil
+}
+
 func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
 	tx := LOG_DB.Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
 	if username != "" {
@@ -389,7 +478,8 @@ func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	return token
 }
 
-func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64, error) {
+// DeleteOldLog 删除 targetTimestamp 之前的日志，tenantId 不为 AllTenants 时只删除该租户用户的日志
+func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int, tenantId int) (int64, error) {
 	var total int64 = 0
 
 	for {
@@ -397,7 +487,7 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 			return total, ctx.Err()
 		}
 
-		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&Log{})
+		result := LOG_DB.Scopes(LogTenantScope(tenantId)).Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&Log{})
 		if nil != result.Error {
 			return total, result.Error
 		}
@@ -411,3 +501,9 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 
 	return total, nil
 }
+
+// GetLogsByRequestId 获取同一请求产生的所有日志，按时间顺序返回
+func GetLogsByRequestId(requestId string) (logs []*Log, err error) {
+	err = LOG_DB.Where("request_id = ?", requestId).Order("id asc").Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChannelUsage_AggregatesSeededLogs(t *testing.T) {
+	setupTestDB(t, &Log{})
+	logs := []*Log{
+		{ChannelId: 1, Type: LogTypeConsume, CreatedAt: 100, PromptTokens: 10, CompletionTokens: 20, Quota: 300, UseTime: 2},
+		{ChannelId: 1, Type: LogTypeConsume, CreatedAt: 200, PromptTokens: 5, CompletionTokens: 5, Quota: 100, UseTime: 4},
+		{ChannelId: 1, Type: LogTypeError, CreatedAt: 150, UseTime: 9},
+		{ChannelId: 1, Type: LogTypeConsume, CreatedAt: 900, PromptTokens: 1000, Quota: 1000, UseTime: 1}, // 超出时间范围
+		{ChannelId: 2, Type: LogTypeConsume, CreatedAt: 120, PromptTokens: 1000, Quota: 1000, UseTime: 1}, // 其他渠道
+		{ChannelId: 1, Type: LogTypeManage, CreatedAt: 130, Quota: 1000},                                  // 非请求日志
+	}
+	require.NoError(t, LOG_DB.Create(&logs).Error)
+
+	usage, err := GetChannelUsage(1, 100, 500)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, usage.RequestCount)
+	require.EqualValues(t, 1, usage.ErrorCount)
+	require.EqualValues(t, 15, usage.PromptTokens)
+	require.EqualValues(t, 25, usage.CompletionTokens)
+	require.EqualValues(t, 40, usage.TotalTokens)
+	require.EqualValues(t, 400, usage.Quota)
+	require.InDelta(t, 1.0/3, usage.ErrorRate, 1e-9)
+	require.InDelta(t, 3.0, usage.AvgUseTime, 1e-9)
+
+	empty, err := GetChannelUsage(3, 0, 0)
+	require.NoError(t, err)
+	require.Zero(t, empty.RequestCount)
+	require.Zero(t, empty.ErrorRate)
+}
+
+func TestRecordConsumeLog_SamplesSuccessButBillsAll(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{})
+	setting := operation_setting.GetLogSetting()
+	origSetting, origConsumeEnabled := *setting, common.LogConsumeEnabled
+	t.Cleanup(func() {
+		*setting = origSetting
+		common.LogConsumeEnabled = origConsumeEnabled
+	})
+	common.LogConsumeEnabled = true
+	setting.SuccessSamplePercent = 100
+	setting.GroupSuccessSamplePercent = map[string]int{"bulk": 10}
+
+	user := &User{Username: "sampled", Quota: 10000, AffCode: "s1"}
+	require.NoError(t, DB.Create(user).Error)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		require.NoError(t, DecreaseUserQuota(user.Id, 1))
+		RecordConsumeLog(c, user.Id, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 1, Group: "bulk"})
+	}
+	for i := 0; i < 10; i++ {
+		RecordErrorLog(c, user.Id, 1, "gpt-4o", "", "upstream error", 0, 0, false, "bulk", nil)
+	}
+
+	var consumeLogs, errorLogs int64
+	require.NoError(t, LOG_DB.Model(&Log{}).Where("type = ?", LogTypeConsume).Count(&consumeLogs).Error)
+	require.NoError(t, LOG_DB.Model(&Log{}).Where("type = ?", LogTypeError).Count(&errorLogs).Error)
+	// 10% 采样，允许约 4 个标准差的波动
+	require.InDelta(t, requests/10, consumeLogs, 40)
+	require.EqualValues(t, 10, errorLogs)
+
+	quota, err := GetUserQuota(user.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, 10000-requests, quota)
+}
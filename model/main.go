@@ -268,6 +268,18 @@ func migrateDB() error {
 		&TwoFA{},
 		&TwoFABackupCode{},
 		&Checkin{},
+		&WebhookDelivery{},
+		&GroupTopUpRecord{},
+		&QuotaAlertState{},
+		&GroupEndpointQuotaUsage{},
+		&RedemptionStats{},
+		&DailySpend{},
+		&UserIdentityLink{},
+		&RedemptionTemplate{},
+		&QuotaRemainder{},
+		&RedemptionUse{},
+		&Tenant{},
+		&BillingSettlement{},
 	)
 	if err != nil {
 		return err
@@ -302,6 +314,18 @@ func migrateDBFast() error {
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
 		{&Checkin{}, "Checkin"},
+		{&WebhookDelivery{}, "WebhookDelivery"},
+		{&GroupTopUpRecord{}, "GroupTopUpRecord"},
+		{&QuotaAlertState{}, "QuotaAlertState"},
+		{&GroupEndpointQuotaUsage{}, "GroupEndpointQuotaUsage"},
+		{&RedemptionStats{}, "RedemptionStats"},
+		{&DailySpend{}, "DailySpend"},
+		{&UserIdentityLink{}, "UserIdentityLink"},
+		{&RedemptionTemplate{}, "RedemptionTemplate"},
+		{&QuotaRemainder{}, "QuotaRemainder"},
+		{&RedemptionUse{}, "RedemptionUse"},
+		{&Tenant{}, "Tenant"},
+		{&BillingSettlement{}, "BillingSettlement"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -10,6 +10,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 )
@@ -84,11 +85,44 @@ func GetModelSupportEndpointTypes(model string) []constant.EndpointType {
 	modelSupportEndpointsLock.RLock()
 	defer modelSupportEndpointsLock.RUnlock()
 	if endpoints, ok := modelSupportEndpointTypes[model]; ok {
-		return endpoints
+		return filterEnabledEndpointTypes(endpoints)
 	}
 	return make([]constant.EndpointType, 0)
 }
 
+// IsModelEndpointsDisabled 模型支持的端点类型全部被全局关闭时返回 true，此类模型不应出现在模型列表中
+func IsModelEndpointsDisabled(model string) bool {
+	modelSupportEndpointsLock.RLock()
+	defer modelSupportEndpointsLock.RUnlock()
+	endpoints := modelSupportEndpointTypes[model]
+	return len(endpoints) > 0 && len(filterEnabledEndpointTypes(endpoints)) == 0
+}
+
+// FilterPricingByEnabledEndpoints 去除已全局关闭的端点类型，并排除全部能力都被关闭的模型
+func FilterPricingByEnabledEndpoints(pricing []Pricing) []Pricing {
+	filtered := make([]Pricing, 0, len(pricing))
+	for _, p := range pricing {
+		if len(p.SupportedEndpointTypes) > 0 {
+			p.SupportedEndpointTypes = filterEnabledEndpointTypes(p.SupportedEndpointTypes)
+			if len(p.SupportedEndpointTypes) == 0 {
+				continue
+			}
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+func filterEnabledEndpointTypes(endpoints []constant.EndpointType) []constant.EndpointType {
+	enabled := make([]constant.EndpointType, 0, len(endpoints))
+	for _, et := range endpoints {
+		if operation_setting.IsEndpointTypeEnabled(et) {
+			enabled = append(enabled, et)
+		}
+	}
+	return enabled
+}
+
 func updatePricing() {
 	//modelRatios := common.GetModelRatios()
 	enableAbilities, err := GetAllEnableAbilityWithChannels()
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 额度预警的对象类型
+const (
+	QuotaAlertOwnerUser  = "user"
+	QuotaAlertOwnerToken = "token"
+)
+
+// QuotaAlertState 多阈值额度预警的触发状态，用于保证每个阈值在每次下穿时只通知一次
+type QuotaAlertState struct {
+	Id        int     `json:"id"`
+	OwnerType string  `json:"owner_type" gorm:"type:varchar(16);uniqueIndex:idx_quota_alert_owner"`
+	OwnerId   int     `json:"owner_id" gorm:"uniqueIndex:idx_quota_alert_owner"`
+	Base      int     `json:"base"`  // 百分比的基准额度，即最近观测到的最高余额
+	Level     float64 `json:"level"` // 已触发的最低阈值百分比，0 表示尚未触发
+	UpdatedAt int64   `json:"updated_at" gorm:"bigint"`
+}
+
+// GetQuotaAlertState 获取预警状态，不存在时返回未保存的空状态
+func GetQuotaAlertState(ownerType string, ownerId int) (*QuotaAlertState, error) {
+	state := QuotaAlertState{}
+	err := DB.Where("owner_type = ? AND owner_id = ?", ownerType, ownerId).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &QuotaAlertState{OwnerType: ownerType, OwnerId: ownerId}, nil
+	}
+	return &state, err
+}
+
+// SaveQuotaAlertState 以乐观锁方式保存预警状态，prevBase/prevLevel 为读取时的值；
+// 状态已被其他请求修改时返回 false，调用方应放弃本次通知
+func SaveQuotaAlertState(state *QuotaAlertState, prevBase int, prevLevel float64) (bool, error) {
+	state.UpdatedAt = common.GetTimestamp()
+	if state.Id == 0 {
+		result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(state)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+	result := DB.Model(&QuotaAlertState{}).
+		Where("id = ? AND base = ? AND level = ?", state.Id, prevBase, prevLevel).
+		Updates(map[string]interface{}{
+			"base":       state.Base,
+			"level":      state.Level,
+			"updated_at": state.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaMicrosPerQuota 每单位额度对应的微额度数
+const QuotaMicrosPerQuota int64 = 1_000_000
+
+// QuotaRemainder 用户尚未扣除的不足 1 额度的消耗，以微额度保存
+// 余额仍为整数额度，累计满 1 额度后并入下一次扣费，没有记录的用户余数为 0
+type QuotaRemainder struct {
+	UserId    int   `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Micros    int64 `json:"micros" gorm:"bigint;default:0"`
+	UpdatedAt int64 `json:"updated_at" gorm:"bigint"`
+}
+
+func GetQuotaRemainder(userId int) (int64, error) {
+	var remainders []QuotaRemainder
+	if err := DB.Where("user_id = ?", userId).Limit(1).Find(&remainders).Error; err != nil {
+		return 0, err
+	}
+	if len(remainders) == 0 {
+		return 0, nil
+	}
+	return remainders[0].Micros, nil
+}
+
+// AccrueQuotaRemainder 将 micros 微额度计入用户余数，返回累计满的整数额度，余数中只保留不足 1 额度的部分
+func AccrueQuotaRemainder(userId int, micros int64) (int, error) {
+	if micros <= 0 {
+		return 0, nil
+	}
+	var whole int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&QuotaRemainder{UserId: userId, UpdatedAt: common.GetTimestamp()}).Error
+		if err != nil {
+			return err
+		}
+		remainder := QuotaRemainder{}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&remainder, "user_id = ?", userId).Error; err != nil {
+			return err
+		}
+		total := remainder.Micros + micros
+		whole = total / QuotaMicrosPerQuota
+		return tx.Model(&QuotaRemainder{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+			"micros":     total % QuotaMicrosPerQuota,
+			"updated_at": common.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(whole), nil
+}
@@ -7,8 +7,10 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Redemption struct {
@@ -23,24 +25,34 @@ type Redemption struct {
 	Count        int            `json:"count" gorm:"-:all"` // only for api request
 	UsedUserId   int            `json:"used_user_id"`
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
-	ExpiredTime  int64          `json:"expired_time" gorm:"bigint"` // 过期时间，0 表示不过期
+	ExpiredTime  int64          `json:"expired_time" gorm:"bigint"`       // 过期时间，0 表示不过期
+	MaxUses      int            `json:"max_uses" gorm:"default:1"`        // 可被多少个不同用户兑换
+	UsedCount    int            `json:"used_count" gorm:"default:0"`      // 已兑换次数
+	TenantId     int            `json:"tenant_id" gorm:"index;default:0"` // 所属租户，只能由该租户的用户兑换
 }
 
-func GetAllRedemptions(startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
+// RedemptionUse 兑换记录，同一用户对同一兑换码只能兑换一次
+type RedemptionUse struct {
+	RedemptionId int   `json:"redemption_id" gorm:"primaryKey;autoIncrement:false"`
+	UserId       int   `json:"user_id" gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedTime  int64 `json:"created_time" gorm:"bigint;index"`
+}
+
+func GetAllRedemptions(tenantId int, startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
 	// 获取总数
-	err = DB.Model(&Redemption{}).Count(&total).Error
+	err = DB.Model(&Redemption{}).Scopes(TenantScope(tenantId)).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 获取分页数据
-	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
+	err = DB.Scopes(TenantScope(tenantId)).Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
 	return redemptions, total, err
 }
 
-func SearchRedemptions(keyword string, startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
+func SearchRedemptions(tenantId int, keyword string, startIdx int, num int) (redemptions []*Redemption, total int64, err error) {
 	// Build query based on keyword type
-	query := DB.Model(&Redemption{})
+	query := DB.Model(&Redemption{}).Scopes(TenantScope(tenantId))
 
 	// Only try to convert to ID if the string represents a valid integer
 	if id, err := strconv.Atoi(keyword); err == nil {
@@ -77,6 +89,10 @@ func Redeem(key string, userId int) (quota int, err error) {
 	if userId == 0 {
 		return 0, errors.New("无效的 user id")
 	}
+	// 校验位不通过时不查库，提示用户检查输入
+	if operation_setting.GetRedemptionSetting().ChecksumRequired && !ValidRedemptionKeyChecksum(key) {
+		return 0, errors.New("兑换码校验失败，请检查是否输入有误")
+	}
 	redemption := &Redemption{}
 
 	keyCol := "`key`"
@@ -85,25 +101,53 @@ func Redeem(key string, userId int) (quota int, err error) {
 	}
 	common.RandomSleep()
 	err = DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
+		err := tx.Where(keyCol+" = ?", key).First(redemption).Error
 		if err != nil {
 			return errors.New("无效的兑换码")
 		}
+		now := common.GetTimestamp()
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&RedemptionUse{RedemptionId: redemption.Id, UserId: userId, CreatedTime: now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("已兑换过该兑换码")
+		}
 		if redemption.Status != common.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		if redemption.ExpiredTime != 0 && redemption.ExpiredTime < common.GetTimestamp() {
+		if redemption.ExpiredTime != 0 && redemption.ExpiredTime < now {
 			return errors.New("该兑换码已过期")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		// 条件更新保证并发兑换不会超过使用上限
+		result = tx.Model(&Redemption{}).
+			Where("id = ? AND status = ? AND used_count < max_uses", redemption.Id, common.RedemptionCodeStatusEnabled).
+			Updates(map[string]interface{}{
+				"used_count":    gorm.Expr("used_count + 1"),
+				"redeemed_time": now,
+				"used_user_id":  userId,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该兑换码已被使用")
+		}
+		err = tx.Model(&Redemption{}).
+			Where("id = ? AND used_count >= max_uses", redemption.Id).
+			Update("status", common.RedemptionCodeStatusUsed).Error
 		if err != nil {
 			return err
 		}
-		redemption.RedeemedTime = common.GetTimestamp()
-		redemption.Status = common.RedemptionCodeStatusUsed
-		redemption.UsedUserId = userId
-		err = tx.Save(redemption).Error
-		return err
+		// 其他租户的兑换码按无效处理
+		result = tx.Model(&User{}).Where("id = ? AND tenant_id = ?", userId, redemption.TenantId).Update("quota", gorm.Expr("quota + ?", redemption.Quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("无效的兑换码")
+		}
+		return incrRedemptionStats(tx, 0, 1, 0, int64(redemption.Quota))
 	})
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
@@ -148,8 +192,8 @@ func DeleteRedemptionById(id int) (err error) {
 	return redemption.Delete()
 }
 
-func DeleteInvalidRedemptions() (int64, error) {
+func DeleteInvalidRedemptions(tenantId int) (int64, error) {
 	now := common.GetTimestamp()
-	result := DB.Where("status IN ? OR (status = ? AND expired_time != 0 AND expired_time < ?)", []int{common.RedemptionCodeStatusUsed, common.RedemptionCodeStatusDisabled}, common.RedemptionCodeStatusEnabled, now).Delete(&Redemption{})
+	result := DB.Scopes(TenantScope(tenantId)).Where("status IN ? OR (status = ? AND expired_time != 0 AND expired_time < ?)", []int{common.RedemptionCodeStatusUsed, common.RedemptionCodeStatusDisabled}, common.RedemptionCodeStatusEnabled, now).Delete(&Redemption{})
 	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,42 @@
+package model
+
+import "strings"
+
+// 兑换码校验位使用的字符集，覆盖前缀与随机部分的全部可用字符
+const redemptionKeyAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_-"
+
+// redemptionKeyChecksum 按 Luhn mod N 计算 payload 的校验位，可检出单个字符错误和大部分相邻字符互换，
+// payload 含字符集外的字符时返回 false
+func redemptionKeyChecksum(payload string) (byte, bool) {
+	sum, ok := redemptionKeyLuhnSum(payload, 2)
+	if !ok {
+		return 0, false
+	}
+	n := len(redemptionKeyAlphabet)
+	return redemptionKeyAlphabet[(n-sum%n)%n], true
+}
+
+// ValidRedemptionKeyChecksum 校验兑换码末位的校验位
+func ValidRedemptionKeyChecksum(key string) bool {
+	if len(key) < 2 {
+		return false
+	}
+	sum, ok := redemptionKeyLuhnSum(key, 1)
+	return ok && sum%len(redemptionKeyAlphabet) == 0
+}
+
+// redemptionKeyLuhnSum 从右向左累加，权重在 factor 与另一值之间交替
+func redemptionKeyLuhnSum(s string, factor int) (int, bool) {
+	n := len(redemptionKeyAlphabet)
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		codePoint := strings.IndexByte(redemptionKeyAlphabet, s[i])
+		if codePoint < 0 {
+			return 0, false
+		}
+		addend := factor * codePoint
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return sum, true
+}
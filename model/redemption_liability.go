@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// RedemptionLiability 截至某一时刻的兑换码额度负债，campaign 为兑换码名称（批量生成时的名称）
+// 数量按可兑换次数统计，多次使用的兑换码每次兑换计一次
+// 已发行 = 已兑换 + 未兑换未过期 + 未兑换已过期 + 作废（禁用或已删除）
+type RedemptionLiability struct {
+	Campaign         string `json:"campaign,omitempty"`
+	IssuedCount      int64  `json:"issued_count"`
+	IssuedQuota      int64  `json:"issued_quota"`
+	RedeemedCount    int64  `json:"redeemed_count"`
+	RedeemedQuota    int64  `json:"redeemed_quota"`
+	OutstandingCount int64  `json:"outstanding_count"`
+	OutstandingQuota int64  `json:"outstanding_quota"`
+	ExpiredCount     int64  `json:"expired_count"`
+	ExpiredQuota     int64  `json:"expired_quota"`
+	VoidCount        int64  `json:"void_count"`
+	VoidQuota        int64  `json:"void_quota"`
+}
+
+// GetRedemptionLiability 按 asOf 时刻统计兑换码额度负债，groupByCampaign 为 true 时按名称分组
+func GetRedemptionLiability(asOf int64, groupByCampaign bool) ([]RedemptionLiability, error) {
+	// 截至 asOf 的兑换次数：多次使用的兑换码按兑换记录统计，单次使用的兑换码按兑换时间判断
+	uses := fmt.Sprintf("(CASE WHEN max_uses > 1 THEN (SELECT COUNT(*) FROM redemption_uses WHERE redemption_uses.redemption_id = redemptions.id AND redemption_uses.created_time <= @as_of) "+
+		"WHEN status = %d AND redeemed_time > 0 AND redeemed_time <= @as_of THEN 1 ELSE 0 END)", common.RedemptionCodeStatusUsed)
+	void := fmt.Sprintf("(status = %d OR (deleted_at IS NOT NULL AND deleted_at <= @deleted_before))", common.RedemptionCodeStatusDisabled)
+	expired := fmt.Sprintf("(NOT %s AND expired_time <> 0 AND expired_time <= @as_of)", void)
+	outstanding := fmt.Sprintf("(NOT %s AND (expired_time = 0 OR expired_time > @as_of))", void)
+
+	selectCampaign, groupBy := "", ""
+	if groupByCampaign {
+		selectCampaign, groupBy = "name AS campaign, ", " GROUP BY name ORDER BY name"
+	}
+	// 未兑换的部分按剩余次数计入过期、作废或未兑换
+	sumRemaining := func(cond string, alias string) string {
+		return fmt.Sprintf("COALESCE(SUM(CASE WHEN %s THEN max_uses - uses ELSE 0 END), 0) AS %s_count, COALESCE(SUM(CASE WHEN %s THEN (max_uses - uses) * quota ELSE 0 END), 0) AS %s_quota", cond, alias, cond, alias)
+	}
+	query := "SELECT " + selectCampaign +
+		"COALESCE(SUM(max_uses), 0) AS issued_count, COALESCE(SUM(max_uses * quota), 0) AS issued_quota, " +
+		"COALESCE(SUM(uses), 0) AS redeemed_count, COALESCE(SUM(uses * quota), 0) AS redeemed_quota, " +
+		sumRemaining(outstanding, "outstanding") + ", " +
+		sumRemaining(expired, "expired") + ", " +
+		sumRemaining(void, "void") +
+		" FROM (SELECT name, quota, status, expired_time, deleted_at, max_uses, " + uses + " AS uses" +
+		" FROM redemptions WHERE created_time <= @as_of) AS r" + groupBy
+
+	result := make([]RedemptionLiability, 0)
+	err := DB.Raw(query, map[string]interface{}{
+		"as_of":          asOf,
+		"deleted_before": time.Unix(asOf, 0),
+	}).Scan(&result).Error
+	return result, err
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestGetRedemptionLiability_AsOfAndByCampaign(t *testing.T) {
+	setupTestDB(t, &Redemption{}, &RedemptionUse{})
+	const asOf int64 = 1_000_000
+	used, enabled, disabled := common.RedemptionCodeStatusUsed, common.RedemptionCodeStatusEnabled, common.RedemptionCodeStatusDisabled
+	deletedAt := func(ts int64) gorm.DeletedAt { return gorm.DeletedAt{Time: time.Unix(ts, 0), Valid: true} }
+	codes := []Redemption{
+		{Key: "k1", Name: "spring", Quota: 100, CreatedTime: 100, Status: used, RedeemedTime: 500},                           // 已兑换
+		{Key: "k2", Name: "spring", Quota: 200, CreatedTime: 100, Status: enabled},                                           // 未兑换未过期
+		{Key: "k3", Name: "spring", Quota: 300, CreatedTime: 100, Status: used, RedeemedTime: asOf + 10},                     // 截止时尚未兑换
+		{Key: "k4", Name: "summer", Quota: 400, CreatedTime: 200, Status: enabled, ExpiredTime: 800},                         // 已过期
+		{Key: "k5", Name: "summer", Quota: 500, CreatedTime: 200, Status: disabled},                                          // 作废
+		{Key: "k6", Name: "summer", Quota: 600, CreatedTime: 200, Status: enabled, DeletedAt: deletedAt(900)},                // 截止前删除，作废
+		{Key: "k7", Name: "summer", Quota: 700, CreatedTime: asOf + 1, Status: enabled},                                      // 截止后发行
+		{Key: "k8", Name: "summer", Quota: 50, CreatedTime: 100, Status: used, RedeemedTime: 600, DeletedAt: deletedAt(950)}, // 已兑换后删除
+	}
+	require.NoError(t, DB.Create(&codes).Error)
+
+	total, err := GetRedemptionLiability(asOf, false)
+	require.NoError(t, err)
+	require.Len(t, total, 1)
+	require.Equal(t, RedemptionLiability{
+		IssuedCount: 7, IssuedQuota: 2150,
+		RedeemedCount: 2, RedeemedQuota: 150,
+		OutstandingCount: 2, OutstandingQuota: 500,
+		ExpiredCount: 1, ExpiredQuota: 400,
+		VoidCount: 2, VoidQuota: 1100,
+	}, total[0])
+
+	campaigns, err := GetRedemptionLiability(asOf, true)
+	require.NoError(t, err)
+	require.Len(t, campaigns, 2)
+	require.Equal(t, "spring", campaigns[0].Campaign)
+	require.EqualValues(t, 500, campaigns[0].OutstandingQuota)
+	require.EqualValues(t, 100, campaigns[0].RedeemedQuota)
+	require.Equal(t, "summer", campaigns[1].Campaign)
+	require.EqualValues(t, 400, campaigns[1].ExpiredQuota)
+	require.EqualValues(t, 1100, campaigns[1].VoidQuota)
+	require.EqualValues(t, 50, campaigns[1].RedeemedQuota)
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const redemptionStatsRowId = 1
+
+// RedemptionStats 兑换码累计统计，在生成与兑换时增量更新，避免全表统计
+type RedemptionStats struct {
+	Id             int   `json:"id"`
+	TotalGenerated int64 `json:"total_generated" gorm:"bigint;default:0"`
+	TotalRedeemed  int64 `json:"total_redeemed" gorm:"bigint;default:0"`
+	QuotaIssued    int64 `json:"quota_issued" gorm:"bigint;default:0"`
+	QuotaRedeemed  int64 `json:"quota_redeemed" gorm:"bigint;default:0"`
+	UpdatedAt      int64 `json:"updated_at" gorm:"bigint"`
+}
+
+func GetRedemptionStats() (*RedemptionStats, error) {
+	stats := &RedemptionStats{}
+	err := DB.First(stats, redemptionStatsRowId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &RedemptionStats{Id: redemptionStatsRowId}, nil
+	}
+	return stats, err
+}
+
+// incrRedemptionStats 在事务内累加统计
+func incrRedemptionStats(tx *gorm.DB, generated int64, redeemed int64, quotaIssued int64, quotaRedeemed int64) error {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&RedemptionStats{Id: redemptionStatsRowId}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&RedemptionStats{}).Where("id = ?", redemptionStatsRowId).Updates(map[string]interface{}{
+		"total_generated": gorm.Expr("total_generated + ?", generated),
+		"total_redeemed":  gorm.Expr("total_redeemed + ?", redeemed),
+		"quota_issued":    gorm.Expr("quota_issued + ?", quotaIssued),
+		"quota_redeemed":  gorm.Expr("quota_redeemed + ?", quotaRedeemed),
+		"updated_at":      common.GetTimestamp(),
+	}).Error
+}
+
+// BatchInsertRedemptions 批量插入兑换码并在同一事务内更新生成统计，多次使用的兑换码按可兑换总额度计入
+func BatchInsertRedemptions(redemptions []Redemption) error {
+	if len(redemptions) == 0 {
+		return nil
+	}
+	var quota int64
+	for _, redemption := range redemptions {
+		quota += int64(redemption.Quota) * int64(max(redemption.MaxUses, 1))
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(redemptions, 50).Error; err != nil {
+			return err
+		}
+		return incrRedemptionStats(tx, int64(len(redemptions)), 0, quota, 0)
+	})
+}
+
+// ReconcileRedemptionStats 按兑换码表（含已删除记录）重新计算统计，修正增量计数的偏差
+func ReconcileRedemptionStats() (*RedemptionStats, error) {
+	type aggregate struct {
+		Count int64
+		Quota int64
+	}
+	var generated, redeemed aggregate
+	err := DB.Unscoped().Model(&Redemption{}).Select("count(*) as count, coalesce(sum(quota * max_uses), 0) as quota").Scan(&generated).Error
+	if err != nil {
+		return nil, err
+	}
+	// 早期的兑换码没有 used_count，已使用即视为兑换一次
+	uses := fmt.Sprintf("(CASE WHEN max_uses > 1 THEN used_count WHEN status = %d THEN 1 ELSE 0 END)", common.RedemptionCodeStatusUsed)
+	err = DB.Unscoped().Model(&Redemption{}).
+		Select(fmt.Sprintf("coalesce(sum(%s), 0) as count, coalesce(sum(quota * %s), 0) as quota", uses, uses)).Scan(&redeemed).Error
+	if err != nil {
+		return nil, err
+	}
+	stats := &RedemptionStats{
+		Id:             redemptionStatsRowId,
+		TotalGenerated: generated.Count,
+		TotalRedeemed:  redeemed.Count,
+		QuotaIssued:    generated.Quota,
+		QuotaRedeemed:  redeemed.Quota,
+		UpdatedAt:      common.GetTimestamp(),
+	}
+	if err := DB.Save(stats).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireRedemptionStats(t *testing.T, generated, redeemed, quotaIssued, quotaRedeemed int64) {
+	t.Helper()
+	stats, err := GetRedemptionStats()
+	require.NoError(t, err)
+	require.Equal(t, generated, stats.TotalGenerated)
+	require.Equal(t, redeemed, stats.TotalRedeemed)
+	require.Equal(t, quotaIssued, stats.QuotaIssued)
+	require.Equal(t, quotaRedeemed, stats.QuotaRedeemed)
+}
+
+func TestRedemptionStats_UpdatedOnGenerateAndRedeem(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{}, &Redemption{}, &RedemptionUse{}, &RedemptionStats{})
+	user := User{Username: "redeemer", Password: "12345678", AffCode: "r1"}
+	require.NoError(t, DB.Create(&user).Error)
+
+	now := common.GetTimestamp()
+	require.NoError(t, BatchInsertRedemptions([]Redemption{
+		{Name: "batch", Key: "key-1", Quota: 100, CreatedTime: now},
+		{Name: "batch", Key: "key-2", Quota: 300, CreatedTime: now},
+	}))
+	requireRedemptionStats(t, 2, 0, 400, 0)
+
+	quota, err := Redeem("key-2", user.Id)
+	require.NoError(t, err)
+	require.Equal(t, 300, quota)
+	requireRedemptionStats(t, 2, 1, 400, 300)
+
+	// 重复兑换失败时统计不变
+	_, err = Redeem("key-2", user.Id)
+	require.Error(t, err)
+	requireRedemptionStats(t, 2, 1, 400, 300)
+}
+
+func TestReconcileRedemptionStats_FixesDrift(t *testing.T) {
+	setupTestDB(t, &Redemption{}, &RedemptionStats{})
+	now := common.GetTimestamp()
+	require.NoError(t, BatchInsertRedemptions([]Redemption{
+		{Name: "batch", Key: "key-1", Quota: 100, CreatedTime: now},
+		{Name: "batch", Key: "key-2", Quota: 200, CreatedTime: now, Status: common.RedemptionCodeStatusUsed},
+	}))
+	// 绕过统计直接写入的记录与已删除的记录都会导致偏差
+	drifted := Redemption{Name: "manual", Key: "key-3", Quota: 50, CreatedTime: now}
+	require.NoError(t, DB.Create(&drifted).Error)
+	require.NoError(t, drifted.Delete())
+	requireRedemptionStats(t, 2, 0, 300, 0)
+
+	_, err := ReconcileRedemptionStats()
+	require.NoError(t, err)
+	requireRedemptionStats(t, 3, 1, 350, 200)
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 兑换码长度，与 redemptions.key 列的长度一致
+const redemptionKeyLength = 32
+
+// 兑换码前缀的最大长度，剩余部分使用随机字符填充
+const redemptionKeyPrefixMaxLength = 16
+
+var redemptionKeyPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// RedemptionTemplate 兑换码生成模板，只保存生成参数，不保存兑换码
+type RedemptionTemplate struct {
+	Id               int    `json:"id"`
+	Name             string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Campaign         string `json:"campaign" gorm:"type:varchar(64)"` // 生成的兑换码名称
+	Count            int    `json:"count"`
+	Quota            int    `json:"quota"`
+	RandomMode       bool   `json:"random_mode"`
+	MinQuota         int    `json:"min_quota"`
+	MaxQuota         int    `json:"max_quota"`
+	ExpiresInSeconds int64  `json:"expires_in_seconds" gorm:"bigint"` // 生成后多久过期，0 表示不过期
+	KeyPrefix        string `json:"key_prefix" gorm:"type:varchar(16)"`
+	MaxUses          int    `json:"max_uses" gorm:"default:1"`
+	TenantId         int    `json:"tenant_id" gorm:"index;default:0"` // 所属租户，生成的兑换码属于同一租户
+	CreatedTime      int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime      int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// ExpiredTimeAt 返回在 now 生成时兑换码的过期时间
+func (t *RedemptionTemplate) ExpiredTimeAt(now int64) int64 {
+	if t.ExpiresInSeconds <= 0 {
+		return 0
+	}
+	return now + t.ExpiresInSeconds
+}
+
+// ValidateRedemptionKeyPrefix 校验兑换码前缀
+func ValidateRedemptionKeyPrefix(prefix string) error {
+	if len(prefix) > redemptionKeyPrefixMaxLength {
+		return fmt.Errorf("兑换码前缀长度不能超过 %d", redemptionKeyPrefixMaxLength)
+	}
+	if !redemptionKeyPrefixPattern.MatchString(prefix) {
+		return errors.New("兑换码前缀只能包含字母、数字、下划线和短横线")
+	}
+	return nil
+}
+
+// NewRedemptionKey 生成兑换码，prefix 为空且未开启校验位时与原有格式一致
+func NewRedemptionKey(prefix string) string {
+	key := common.GetUUID()
+	if !operation_setting.GetRedemptionSetting().ChecksumEnabled {
+		return prefix + key[:redemptionKeyLength-len(prefix)]
+	}
+	payload := prefix + key[:redemptionKeyLength-1-len(prefix)]
+	checksum, _ := redemptionKeyChecksum(payload)
+	return payload + string(checksum)
+}
+
+func GetAllRedemptionTemplates(tenantId int) ([]*RedemptionTemplate, error) {
+	var templates []*RedemptionTemplate
+	err := DB.Scopes(TenantScope(tenantId)).Order("id desc").Find(&templates).Error
+	return templates, err
+}
+
+func GetRedemptionTemplateById(id int) (*RedemptionTemplate, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	template := RedemptionTemplate{Id: id}
+	err := DB.First(&template, "id = ?", id).Error
+	return &template, err
+}
+
+func (t *RedemptionTemplate) Insert() error {
+	t.CreatedTime = common.GetTimestamp()
+	t.UpdatedTime = t.CreatedTime
+	return DB.Create(t).Error
+}
+
+func (t *RedemptionTemplate) Update() error {
+	t.UpdatedTime = common.GetTimestamp()
+	return DB.Model(t).Select("name", "campaign", "count", "quota", "random_mode", "min_quota", "max_quota",
+		"expires_in_seconds", "key_prefix", "max_uses", "updated_time").Updates(t).Error
+}
+
+func DeleteRedemptionTemplateById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&RedemptionTemplate{}, "id = ?", id).Error
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedeem_MultiUseCodeCapsDistinctUsers(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{}, &Redemption{}, &RedemptionUse{}, &RedemptionStats{})
+	users := []User{
+		{Username: "cohort_a", Password: "12345678", AffCode: "c1"},
+		{Username: "cohort_b", Password: "12345678", AffCode: "c2"},
+		{Username: "cohort_c", Password: "12345678", AffCode: "c3"},
+	}
+	require.NoError(t, DB.Create(&users).Error)
+	require.NoError(t, BatchInsertRedemptions([]Redemption{
+		{Name: "onboarding", Key: "cohort-key", Quota: 500, MaxUses: 2, CreatedTime: common.GetTimestamp()},
+	}))
+	requireRedemptionStats(t, 1, 0, 1000, 0)
+
+	quota, err := Redeem("cohort-key", users[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, 500, quota)
+
+	// 同一用户不能重复兑换
+	_, err = Redeem("cohort-key", users[0].Id)
+	require.ErrorContains(t, err, "已兑换过该兑换码")
+
+	redemption := Redemption{}
+	require.NoError(t, DB.First(&redemption, "name = ?", "onboarding").Error)
+	require.Equal(t, 1, redemption.UsedCount)
+	require.Equal(t, common.RedemptionCodeStatusEnabled, redemption.Status)
+
+	_, err = Redeem("cohort-key", users[1].Id)
+	require.NoError(t, err)
+	require.NoError(t, DB.First(&redemption, redemption.Id).Error)
+	require.Equal(t, 2, redemption.UsedCount)
+	require.Equal(t, common.RedemptionCodeStatusUsed, redemption.Status)
+
+	// 达到使用上限后其他用户无法兑换
+	_, err = Redeem("cohort-key", users[2].Id)
+	require.ErrorContains(t, err, "该兑换码已被使用")
+
+	var uses int64
+	require.NoError(t, DB.Model(&RedemptionUse{}).Where("redemption_id = ?", redemption.Id).Count(&uses).Error)
+	require.EqualValues(t, 2, uses)
+	for i, want := range []int{500, 500, 0} {
+		got, err := GetUserQuota(users[i].Id, true)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	requireRedemptionStats(t, 1, 2, 1000, 1000)
+
+	liability, err := GetRedemptionLiability(common.GetTimestamp(), false)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, liability[0].IssuedCount)
+	require.EqualValues(t, 1000, liability[0].RedeemedQuota)
+	require.EqualValues(t, 0, liability[0].OutstandingQuota)
+}
+
+func TestRedeem_ChecksumRejectsMistypedCodes(t *testing.T) {
+	setupTestDB(t, &User{}, &Log{}, &Redemption{}, &RedemptionUse{}, &RedemptionStats{})
+	setting := operation_setting.GetRedemptionSetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.ChecksumEnabled = true
+	setting.ChecksumRequired = true
+
+	user := User{Username: "checksum", Password: "12345678", AffCode: "k1"}
+	require.NoError(t, DB.Create(&user).Error)
+	key := NewRedemptionKey("VIP-")
+	require.Len(t, key, redemptionKeyLength)
+	require.True(t, ValidRedemptionKeyChecksum(key))
+	require.NoError(t, BatchInsertRedemptions([]Redemption{
+		{Name: "checksum", Key: key, Quota: 100, MaxUses: 1, CreatedTime: common.GetTimestamp()},
+	}))
+
+	// 修改任意一位或互换相邻两位都会使校验失败
+	mistyped := []byte(key)
+	mistyped[10] = redemptionKeyAlphabet[(strings.IndexByte(redemptionKeyAlphabet, mistyped[10])+1)%len(redemptionKeyAlphabet)]
+	_, err := Redeem(string(mistyped), user.Id)
+	require.ErrorContains(t, err, "请检查是否输入有误")
+	swapped := []byte(key)
+	swapped[len(key)-3], swapped[len(key)-2] = swapped[len(key)-2], swapped[len(key)-3]
+	if string(swapped) != key {
+		require.False(t, ValidRedemptionKeyChecksum(string(swapped)))
+	}
+
+	// 校验位正确但不存在的兑换码
+	_, err = Redeem(NewRedemptionKey("VIP-"), user.Id)
+	require.ErrorContains(t, err, "无效的兑换码")
+
+	quota, err := Redeem(key, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, 100, quota)
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTenantId 默认租户，未开启多租户前的用户与渠道都属于该租户
+const DefaultTenantId = 0
+
+// AllTenants 查询时不按租户过滤，仅供超级管理员使用
+const AllTenants = -1
+
+const (
+	TenantStatusEnabled  = 1
+	TenantStatusDisabled = 2
+)
+
+var tenantCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Tenant 租户，隔离用户与渠道，价格与倍率等配置仍为全局配置
+type Tenant struct {
+	Id          int    `json:"id"`
+	Code        string `json:"code" gorm:"type:varchar(32);uniqueIndex"` // 子域名或请求头中使用的标识
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+var (
+	tenantCodeCache   = make(map[string]*Tenant)
+	tenantCodeCacheMu sync.RWMutex
+)
+
+// TenantScope 按租户过滤查询，tenantId 为 AllTenants 时不过滤
+func TenantScope(tenantId int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantId == AllTenants {
+			return db
+		}
+		return db.Where("tenant_id = ?", tenantId)
+	}
+}
+
+// abilityTenantScope 按渠道所属租户过滤 abilities
+func abilityTenantScope(tenantId int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantId == AllTenants {
+			return db
+		}
+		return db.Where("channel_id IN (?)", DB.Model(&Channel{}).Select("id").Where("tenant_id = ?", tenantId))
+	}
+}
+
+// LogTenantScope 按用户所属租户过滤日志，日志库与主库分离时先查出租户下的用户 id
+func LogTenantScope(tenantId int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantId == AllTenants {
+			return db
+		}
+		users := DB.Unscoped().Model(&User{}).Select("id").Where("tenant_id = ?", tenantId)
+		if LOG_DB == DB {
+			return db.Where("logs.user_id IN (?)", users)
+		}
+		var userIds []int
+		if err := users.Pluck("id", &userIds).Error; err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Where("logs.user_id IN ?", userIds)
+	}
+}
+
+// Validate 校验租户标识
+func (tenant *Tenant) Validate() error {
+	if !tenantCodePattern.MatchString(tenant.Code) {
+		return errors.New("租户标识只能包含小写字母、数字和短横线，且不超过 32 个字符")
+	}
+	return nil
+}
+
+func GetAllTenants() ([]*Tenant, error) {
+	var tenants []*Tenant
+	err := DB.Order("id asc").Find(&tenants).Error
+	return tenants, err
+}
+
+func GetTenantById(id int) (*Tenant, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	tenant := Tenant{}
+	err := DB.First(&tenant, "id = ?", id).Error
+	return &tenant, err
+}
+
+// GetTenantByCode 按标识获取租户，结果在进程内缓存，租户变更时清空
+func GetTenantByCode(code string) (*Tenant, error) {
+	tenantCodeCacheMu.RLock()
+	tenant, ok := tenantCodeCache[code]
+	tenantCodeCacheMu.RUnlock()
+	if ok {
+		return tenant, nil
+	}
+	tenant = &Tenant{}
+	if err := DB.First(tenant, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	tenantCodeCacheMu.Lock()
+	tenantCodeCache[code] = tenant
+	tenantCodeCacheMu.Unlock()
+	return tenant, nil
+}
+
+func invalidateTenantCache() {
+	tenantCodeCacheMu.Lock()
+	tenantCodeCache = make(map[string]*Tenant)
+	tenantCodeCacheMu.Unlock()
+}
+
+func (tenant *Tenant) Insert() error {
+	tenant.CreatedTime = common.GetTimestamp()
+	if err := DB.Create(tenant).Error; err != nil {
+		return err
+	}
+	invalidateTenantCache()
+	return nil
+}
+
+func (tenant *Tenant) Update() error {
+	err := DB.Model(tenant).Select("code", "name", "status").Updates(tenant).Error
+	if err != nil {
+		return err
+	}
+	invalidateTenantCache()
+	return nil
+}
+
+// DeleteTenantById 删除租户，租户下仍有用户或渠道时拒绝删除
+func DeleteTenantById(id int) error {
+	if id == DefaultTenantId {
+		return errors.New("不能删除默认租户")
+	}
+	var users, channels int64
+	if err := DB.Model(&User{}).Where("tenant_id = ?", id).Count(&users).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&Channel{}).Where("tenant_id = ?", id).Count(&channels).Error; err != nil {
+		return err
+	}
+	if users > 0 || channels > 0 {
+		return fmt.Errorf("租户下仍有 %d 个用户和 %d 个渠道，无法删除", users, channels)
+	}
+	if err := DB.Delete(&Tenant{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	invalidateTenantCache()
+	return nil
+}
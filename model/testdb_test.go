@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupTestDB 使用内存 SQLite 初始化 DB 与 LOG_DB，并迁移给定的表
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	origDB, origLogDB := DB, LOG_DB
+	origSQLite, origRedis := common.UsingSQLite, common.RedisEnabled
+	t.Cleanup(func() {
+		DB, LOG_DB = origDB, origLogDB
+		common.UsingSQLite, common.RedisEnabled = origSQLite, origRedis
+		initCol()
+	})
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	common.UsingSQLite = true
+	common.RedisEnabled = false
+	DB = db
+	LOG_DB = db
+	initCol()
+	require.NoError(t, DB.AutoMigrate(models...))
+}
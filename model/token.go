@@ -17,7 +17,7 @@ type Token struct {
 	Status             int            `json:"status" gorm:"default:1"`
 	Name               string         `json:"name" gorm:"index" `
 	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
-	AccessedTime       int64          `json:"accessed_time" gorm:"bigint"`
+	AccessedTime       int64          `json:"accessed_time" gorm:"bigint"`           // 最近使用时间
 	ExpiredTime        int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
 	RemainQuota        int            `json:"remain_quota" gorm:"default:0"`
 	UnlimitedQuota     bool           `json:"unlimited_quota"`
@@ -26,7 +26,9 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                                     // 跨分组重试，仅auto分组有效
+	AllowedEndpoints   string         `json:"allowed_endpoints" gorm:"type:varchar(255);default:''"` // 允许访问的接口类别，逗号分隔，为空表示不限制
+	LastUsedIp         string         `json:"last_used_ip" gorm:"type:varchar(64);default:''"`       // 最近使用的 IP
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -190,7 +192,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "allowed_endpoints").Updates(token).Error
 	return err
 }
 
@@ -235,6 +237,18 @@ func (token *Token) GetModelLimits() []string {
 	return strings.Split(token.ModelLimits, ",")
 }
 
+// GetAllowedEndpoints 返回令牌允许访问的接口类别，为空表示不限制
+func (token *Token) GetAllowedEndpoints() []string {
+	endpoints := make([]string, 0)
+	for _, endpoint := range strings.Split(token.AllowedEndpoints, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
 func (token *Token) GetModelLimitsMap() map[string]bool {
 	limits := token.GetModelLimits()
 	limitsMap := make(map[string]bool)
@@ -367,3 +381,85 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 
 	return len(tokens), nil
 }
+
+// SuspendUser 禁用用户并在同一事务中禁用其全部启用中的令牌，提交后立即清除用户与令牌缓存，
+// 使后续的令牌鉴权直接失败。返回被禁用的令牌数量。
+// 注意：重新启用用户不会自动恢复这些令牌，需要用户或管理员手动启用。
+func SuspendUser(userId int) (int, error) {
+	if userId == 0 {
+		return 0, errors.New("userId 为空！")
+	}
+	var keys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userId).Update("status", common.UserStatusDisabled).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Token{}).Where("user_id = ? AND status = ?", userId, common.TokenStatusEnabled).
+			Pluck("key", &keys).Error; err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		return tx.Model(&Token{}).Where("user_id = ? AND status = ?", userId, common.TokenStatusEnabled).
+			Update("status", common.TokenStatusDisabled).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if common.RedisEnabled {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysLog("failed to invalidate user cache: " + err.Error())
+		}
+		for _, key := range keys {
+			if err := cacheDeleteToken(key); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		}
+	}
+	return len(keys), nil
+}
+
+// SuspendToken 将启用中的令牌标记为暂停并清除缓存，返回是否实际发生了状态变更
+func SuspendToken(id int) (bool, error) {
+	token := &Token{}
+	// 不使用 GetTokenById，避免其异步回写的缓存覆盖状态变更
+	if err := DB.First(token, "id = ?", id).Error; err != nil {
+		return false, err
+	}
+	return setTokenStatusFrom(token, common.TokenStatusEnabled, common.TokenStatusSuspended)
+}
+
+// RestoreSuspendedToken 将被暂停的令牌恢复为启用状态
+func RestoreSuspendedToken(id int) (*Token, error) {
+	token := &Token{}
+	if err := DB.First(token, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	changed, err := setTokenStatusFrom(token, common.TokenStatusSuspended, common.TokenStatusEnabled)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, errors.New("该令牌未处于暂停状态")
+	}
+	return token, nil
+}
+
+func setTokenStatusFrom(token *Token, from int, to int) (bool, error) {
+	result := DB.Model(&Token{}).Where("id = ? AND status = ?", token.Id, from).Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	token.Status = to
+	if common.RedisEnabled {
+		if err := cacheDeleteToken(token.Key); err != nil {
+			common.SysLog("failed to delete token cache: " + err.Error())
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspendUser_DisablesAllTokens(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := User{Username: "suspended", Password: "12345678", AffCode: "s1"}
+	other := User{Username: "other", Password: "12345678", AffCode: "o1"}
+	require.NoError(t, DB.Create(&user).Error)
+	require.NoError(t, DB.Create(&other).Error)
+	keys := []string{"suspend-key-1", "suspend-key-2"}
+	for _, key := range keys {
+		require.NoError(t, DB.Create(&Token{UserId: user.Id, Key: key, Name: key, Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}).Error)
+	}
+	require.NoError(t, DB.Create(&Token{UserId: other.Id, Key: "other-key-1", Name: "o", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}).Error)
+	for _, key := range keys {
+		_, err := ValidateUserToken(key)
+		require.NoError(t, err)
+	}
+
+	disabled, err := SuspendUser(user.Id)
+	require.NoError(t, err)
+	require.Equal(t, 2, disabled)
+
+	suspended, err := GetUserById(user.Id, false)
+	require.NoError(t, err)
+	require.Equal(t, common.UserStatusDisabled, suspended.Status)
+	for _, key := range keys {
+		_, err := ValidateUserToken(key)
+		require.EqualError(t, err, "该令牌状态不可用")
+	}
+	_, err = ValidateUserToken("other-key-1")
+	require.NoError(t, err)
+}
@@ -47,6 +47,9 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	LastUsedTime     int64          `json:"last_used_time" gorm:"bigint;default:0"`          // 最近通过令牌调用的时间
+	LastUsedIp       string         `json:"last_used_ip" gorm:"type:varchar(64);default:''"` // 最近通过令牌调用的 IP
+	TenantId         int            `json:"tenant_id" gorm:"index;default:0"`                // 所属租户，0 为默认租户
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -58,6 +61,7 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+		TenantId: user.TenantId,
 	}
 	return cache
 }
@@ -185,7 +189,8 @@ func GetMaxUserId() int {
 	return user.Id
 }
 
-func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err error) {
+// GetAllUsers 分页获取租户 tenantId 下的用户，tenantId 为 AllTenants 时获取全部用户
+func GetAllUsers(pageInfo *common.PageInfo, tenantId int) (users []*User, total int64, err error) {
 	// Start transaction
 	tx := DB.Begin()
 	if tx.Error != nil {
@@ -198,14 +203,14 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 	}()
 
 	// Get total count within transaction
-	err = tx.Unscoped().Model(&User{}).Count(&total).Error
+	err = tx.Unscoped().Model(&User{}).Scopes(TenantScope(tenantId)).Count(&total).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
 	}
 
 	// Get paginated users within same transaction
-	err = tx.Unscoped().Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password").Find(&users).Error
+	err = tx.Unscoped().Scopes(TenantScope(tenantId)).Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password").Find(&users).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
@@ -219,7 +224,7 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 	return users, total, nil
 }
 
-func SearchUsers(keyword string, group string, startIdx int, num int) ([]*User, int64, error) {
+func SearchUsers(keyword string, group string, startIdx int, num int, tenantId int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
 	var err error
@@ -236,7 +241,7 @@ func SearchUsers(keyword string, group string, startIdx int, num int) ([]*User,
 	}()
 
 	// 构建基础查询
-	query := tx.Unscoped().Model(&User{})
+	query := tx.Unscoped().Model(&User{}).Scopes(TenantScope(tenantId))
 
 	// 构建搜索条件
 	likeCondition := "username LIKE ? OR email LIKE ? OR display_name LIKE ?"
@@ -412,6 +417,9 @@ func (user *User) Insert(inviterId int) error {
 		}
 	}
 
+	if err := user.syncIdentityLinks(); err != nil {
+		common.SysLog(fmt.Sprintf("failed to record identity links for user %d: %s", user.Id, err.Error()))
+	}
 	if common.QuotaForNewUser > 0 {
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", logger.LogQuota(common.QuotaForNewUser)))
 	}
@@ -442,6 +450,9 @@ func (user *User) Update(updatePassword bool) error {
 	if err = DB.Model(user).Updates(newUser).Error; err != nil {
 		return err
 	}
+	if err = user.syncIdentityLinks(); err != nil {
+		common.SysLog(fmt.Sprintf("failed to record identity links for user %d: %s", user.Id, err.Error()))
+	}
 
 	// Update cache
 	return updateUserCache(*user)
@@ -929,3 +940,26 @@ func RootUserExists() bool {
 	}
 	return true
 }
+
+// SwitchUserGroup 仅在用户当前分组仍为 from 时将其改为 to，返回是否实际修改，用于自动调整分组时避免覆盖并发修改
+func SwitchUserGroup(userId int, from string, to string) (bool, error) {
+	result := DB.Model(&User{}).Where(map[string]interface{}{"id": userId, "group": from}).Update("group", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError(fmt.Sprintf("failed to invalidate user cache: user_id=%d, error=%v", userId, err))
+	}
+	return true, nil
+}
+
+// GetUsersInGroups 按 id 升序分批获取指定分组的用户
+func GetUsersInGroups(groups []string, afterId int, limit int) ([]*User, error) {
+	var users []*User
+	err := DB.Where(map[string]interface{}{"group": groups}).Where("id > ?", afterId).
+		Order("id asc").Limit(limit).Find(&users).Error
+	return users, err
+}
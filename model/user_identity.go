@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentityLink 记录用户绑定外部身份的时间，绑定关系本身保存在 users 表对应列中
+type UserIdentityLink struct {
+	Id       int    `json:"id"`
+	UserId   int    `json:"user_id" gorm:"uniqueIndex:idx_user_identity_provider"`
+	Provider string `json:"provider" gorm:"type:varchar(32);uniqueIndex:idx_user_identity_provider"`
+	LinkedAt int64  `json:"linked_at" gorm:"bigint"`
+}
+
+// UserIdentity 用户已绑定的外部身份
+type UserIdentity struct {
+	Provider   string `json:"provider"`
+	ExternalId string `json:"external_id"`
+	LinkedAt   int64  `json:"linked_at"`
+}
+
+type identityProvider struct {
+	name   string
+	column string
+	get    func(user *User) string
+}
+
+// identityProviders 支持解绑的外部身份，name 为接口中使用的提供方名称
+var identityProviders = []identityProvider{
+	{name: "github", column: "github_id", get: func(user *User) string { return user.GitHubId }},
+	{name: "discord", column: "discord_id", get: func(user *User) string { return user.DiscordId }},
+	{name: "oidc", column: "oidc_id", get: func(user *User) string { return user.OidcId }},
+	{name: "wechat", column: "wechat_id", get: func(user *User) string { return user.WeChatId }},
+	{name: "telegram", column: "telegram_id", get: func(user *User) string { return user.TelegramId }},
+	{name: "linuxdo", column: "linux_do_id", get: func(user *User) string { return user.LinuxDOId }},
+}
+
+var (
+	ErrUnknownIdentityProvider = errors.New("未知的登录方式")
+	ErrIdentityNotLinked       = errors.New("未绑定该登录方式")
+	ErrLastLoginMethod         = errors.New("这是账户唯一的登录方式，请先设置密码后再解绑")
+)
+
+func getUserIdentityLinks(userId int) (map[string]UserIdentityLink, error) {
+	var links []UserIdentityLink
+	if err := DB.Where("user_id = ?", userId).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]UserIdentityLink, len(links))
+	for _, link := range links {
+		result[link.Provider] = link
+	}
+	return result, nil
+}
+
+// syncIdentityLinks 按用户当前绑定的外部身份补充或清理绑定时间记录
+func (user *User) syncIdentityLinks() error {
+	if user.Id == 0 {
+		return nil
+	}
+	links, err := getUserIdentityLinks(user.Id)
+	if err != nil {
+		return err
+	}
+	for _, provider := range identityProviders {
+		_, recorded := links[provider.name]
+		linked := provider.get(user) != ""
+		if linked && !recorded {
+			err = DB.Create(&UserIdentityLink{UserId: user.Id, Provider: provider.name, LinkedAt: common.GetTimestamp()}).Error
+		} else if !linked && recorded {
+			err = DB.Where("user_id = ? AND provider = ?", user.Id, provider.name).Delete(&UserIdentityLink{}).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserIdentities 列出用户已绑定的外部身份，早于绑定时间记录的绑定 linked_at 为 0
+func GetUserIdentities(userId int) ([]UserIdentity, error) {
+	user, err := GetUserById(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	links, err := getUserIdentityLinks(userId)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]UserIdentity, 0)
+	for _, provider := range identityProviders {
+		externalId := provider.get(user)
+		if externalId == "" {
+			continue
+		}
+		identities = append(identities, UserIdentity{
+			Provider:   provider.name,
+			ExternalId: externalId,
+			LinkedAt:   links[provider.name].LinkedAt,
+		})
+	}
+	return identities, nil
+}
+
+// UnlinkUserIdentity 解绑外部身份，未设置密码且没有其他登录方式时拒绝解绑
+func UnlinkUserIdentity(userId int, providerName string) error {
+	user, err := GetUserById(userId, true)
+	if err != nil {
+		return err
+	}
+	var target *identityProvider
+	otherMethods := 0
+	for i, provider := range identityProviders {
+		if provider.name == providerName {
+			target = &identityProviders[i]
+		} else if provider.get(user) != "" {
+			otherMethods++
+		}
+	}
+	if target == nil {
+		return ErrUnknownIdentityProvider
+	}
+	if target.get(user) == "" {
+		return ErrIdentityNotLinked
+	}
+	if user.Password != "" {
+		otherMethods++
+	}
+	if _, err := GetPasskeyByUserID(userId); err == nil {
+		otherMethods++
+	}
+	if otherMethods == 0 {
+		return ErrLastLoginMethod
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userId).Update(target.column, "").Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND provider = ?", userId, target.name).Delete(&UserIdentityLink{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
@@ -0,0 +1,198 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// UserMergeSummary 用户合并的结果（或预览）
+type UserMergeSummary struct {
+	SourceId     int   `json:"source_id"`
+	TargetId     int   `json:"target_id"`
+	DryRun       bool  `json:"dry_run"`
+	Tokens       int64 `json:"tokens"`
+	Logs         int64 `json:"logs"`
+	TopUps       int64 `json:"top_ups"`
+	Midjourneys  int64 `json:"midjourneys"`
+	Tasks        int64 `json:"tasks"`
+	Checkins     int64 `json:"checkins"`
+	QuotaData    int64 `json:"quota_data"`
+	Redemptions  int64 `json:"redemptions"`
+	Invitees     int64 `json:"invitees"`
+	Quota        int   `json:"quota"`
+	UsedQuota    int   `json:"used_quota"`
+	RequestCount int   `json:"request_count"`
+	AffQuota     int   `json:"aff_quota"`
+}
+
+// userMergeCount 统计 source 用户在某张表中关联的记录数
+func userMergeCount(tx *gorm.DB, model interface{}, column string, sourceId int, count *int64) error {
+	return tx.Model(model).Where(column+" = ?", sourceId).Count(count).Error
+}
+
+// MergeUsers 将 source 用户的令牌、日志、充值、任务、签到、用量统计、兑换记录与额度（含邀请额度）
+// 合并到 target 用户，完成后禁用并删除 source 用户。target 必须处于启用状态。
+// 与 target 同一天的签到记录无法迁移（唯一索引），其奖励已计入 source 额度，合并时直接删除。
+// dryRun 为 true 时仅统计将要迁移的数据，不做任何修改。
+func MergeUsers(sourceId int, targetId int, dryRun bool) (*UserMergeSummary, error) {
+	if sourceId == 0 || targetId == 0 {
+		return nil, errors.New("用户 id 为空！")
+	}
+	if sourceId == targetId {
+		return nil, errors.New("不能将用户合并到自身")
+	}
+
+	summary := &UserMergeSummary{SourceId: sourceId, TargetId: targetId, DryRun: dryRun}
+	var movedTokenKeys []string
+	var target User
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var source User
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&source, "id = ?", sourceId).Error; err != nil {
+			return fmt.Errorf("源用户不存在: %w", err)
+		}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&target, "id = ?", targetId).Error; err != nil {
+			return fmt.Errorf("目标用户不存在: %w", err)
+		}
+		if source.Role == common.RoleRootUser {
+			return errors.New("无法合并超级管理员用户")
+		}
+		if target.Status != common.UserStatusEnabled {
+			return errors.New("目标用户已被禁用，无法合并")
+		}
+		// 日志库与主库相同时，日志的统计与迁移与其他数据在同一事务中完成
+		logDB := LOG_DB
+		if LOG_DB == DB {
+			logDB = tx
+		}
+
+		summary.Quota = source.Quota
+		summary.UsedQuota = source.UsedQuota
+		summary.RequestCount = source.RequestCount
+		summary.AffQuota = source.AffQuota
+		if err := tx.Model(&Token{}).Where("user_id = ?", sourceId).Pluck("key", &movedTokenKeys).Error; err != nil {
+			return err
+		}
+		summary.Tokens = int64(len(movedTokenKeys))
+		counts := []struct {
+			model  interface{}
+			column string
+			count  *int64
+		}{
+			{&TopUp{}, "user_id", &summary.TopUps},
+			{&Midjourney{}, "user_id", &summary.Midjourneys},
+			{&Task{}, "user_id", &summary.Tasks},
+			{&Checkin{}, "user_id", &summary.Checkins},
+			{&QuotaData{}, "user_id", &summary.QuotaData},
+			{&Redemption{}, "used_user_id", &summary.Redemptions},
+			{&User{}, "inviter_id", &summary.Invitees},
+		}
+		for _, item := range counts {
+			if err := userMergeCount(tx, item.model, item.column, sourceId, item.count); err != nil {
+				return err
+			}
+		}
+		if err := userMergeCount(logDB, &Log{}, "user_id", sourceId, &summary.Logs); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+
+		moves := []struct {
+			model  interface{}
+			column string
+		}{
+			{&Token{}, "user_id"},
+			{&TopUp{}, "user_id"},
+			{&Midjourney{}, "user_id"},
+			{&Task{}, "user_id"},
+			{&Redemption{}, "used_user_id"},
+			{&User{}, "inviter_id"},
+		}
+		for _, item := range moves {
+			if err := tx.Model(item.model).Where(item.column+" = ?", sourceId).Update(item.column, targetId).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&QuotaData{}).Where("user_id = ?", sourceId).Updates(map[string]interface{}{
+			"user_id":  targetId,
+			"username": target.Username,
+		}).Error; err != nil {
+			return err
+		}
+		var targetDates []string
+		if err := tx.Model(&Checkin{}).Where("user_id = ?", targetId).Pluck("checkin_date", &targetDates).Error; err != nil {
+			return err
+		}
+		if len(targetDates) > 0 {
+			if err := tx.Where("user_id = ? AND checkin_date IN ?", sourceId, targetDates).Delete(&Checkin{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Checkin{}).Where("user_id = ?", sourceId).Update("user_id", targetId).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", targetId).Updates(map[string]interface{}{
+			"quota":         gorm.Expr("quota + ?", source.Quota),
+			"used_quota":    gorm.Expr("used_quota + ?", source.UsedQuota),
+			"request_count": gorm.Expr("request_count + ?", source.RequestCount),
+			"aff_quota":     gorm.Expr("aff_quota + ?", source.AffQuota),
+			"aff_history":   gorm.Expr("aff_history + ?", source.AffHistoryQuota),
+			"aff_count":     gorm.Expr("aff_count + ?", source.AffCount),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", sourceId).Updates(map[string]interface{}{
+			"quota":     0,
+			"aff_quota": 0,
+			"status":    common.UserStatusDisabled,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&User{}, "id = ?", sourceId).Error; err != nil {
+			return err
+		}
+		if logDB == tx {
+			return tx.Model(&Log{}).Where("user_id = ?", sourceId).Updates(map[string]interface{}{
+				"user_id":  targetId,
+				"username": target.Username,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return summary, nil
+	}
+
+	if LOG_DB != DB {
+		err = LOG_DB.Model(&Log{}).Where("user_id = ?", sourceId).Updates(map[string]interface{}{
+			"user_id":  targetId,
+			"username": target.Username,
+		}).Error
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to move logs from user %d to user %d: %s", sourceId, targetId, err.Error()))
+		}
+	}
+
+	_ = invalidateUserCache(sourceId)
+	_ = invalidateUserCache(targetId)
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			for _, key := range movedTokenKeys {
+				_ = cacheDeleteToken(key)
+			}
+		})
+	}
+	RecordLog(targetId, LogTypeManage, fmt.Sprintf("用户 %d 已合并至当前账户，迁移令牌 %d 个，额度 %s", sourceId, summary.Tokens, logger.LogQuota(summary.Quota)))
+	return summary, nil
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func seedMergeUsers(t *testing.T) (source User, target User) {
+	source = User{Username: "source", Password: "12345678", Quota: 300, UsedQuota: 50, RequestCount: 5, AffCode: "src", AffQuota: 40, AffHistoryQuota: 60}
+	target = User{Username: "target", Password: "12345678", Quota: 700, UsedQuota: 20, RequestCount: 2, AffCode: "tgt"}
+	require.NoError(t, DB.Create(&source).Error)
+	require.NoError(t, DB.Create(&target).Error)
+	require.NoError(t, DB.Create(&Token{UserId: source.Id, Key: "source-key-1", Name: "a"}).Error)
+	require.NoError(t, DB.Create(&Token{UserId: source.Id, Key: "source-key-2", Name: "b"}).Error)
+	require.NoError(t, DB.Create(&Token{UserId: target.Id, Key: "target-key-1", Name: "c"}).Error)
+	require.NoError(t, LOG_DB.Create(&Log{UserId: source.Id, Username: source.Username, Type: LogTypeConsume, Quota: 50}).Error)
+	require.NoError(t, DB.Create(&Task{UserId: source.Id, TaskID: "task-1"}).Error)
+	require.NoError(t, DB.Create(&Midjourney{UserId: source.Id, MjId: "mj-1"}).Error)
+	require.NoError(t, DB.Create(&QuotaData{UserID: source.Id, Username: source.Username, ModelName: "gpt-4o", Quota: 50}).Error)
+	require.NoError(t, DB.Create(&Redemption{Key: "redeem-1", Name: "r", UsedUserId: source.Id}).Error)
+	require.NoError(t, DB.Create(&Checkin{UserId: source.Id, CheckinDate: "2024-01-01", QuotaAwarded: 1}).Error)
+	require.NoError(t, DB.Create(&Checkin{UserId: source.Id, CheckinDate: "2024-01-02", QuotaAwarded: 1}).Error)
+	require.NoError(t, DB.Create(&Checkin{UserId: target.Id, CheckinDate: "2024-01-02", QuotaAwarded: 1}).Error)
+	return source, target
+}
+
+var mergeTestModels = []interface{}{&User{}, &Token{}, &Log{}, &TopUp{}, &Task{}, &Midjourney{}, &QuotaData{}, &Redemption{}, &Checkin{}}
+
+func TestMergeUsers_ReassignsTokensLogsAndSumsQuota(t *testing.T) {
+	setupTestDB(t, mergeTestModels...)
+	source, target := seedMergeUsers(t)
+
+	summary, err := MergeUsers(source.Id, target.Id, false)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, summary.Tokens)
+	require.EqualValues(t, 1, summary.Logs)
+	require.Equal(t, 300, summary.Quota)
+
+	var tokenCount int64
+	require.NoError(t, DB.Model(&Token{}).Where("user_id = ?", target.Id).Count(&tokenCount).Error)
+	require.EqualValues(t, 3, tokenCount)
+
+	var logCount int64
+	require.NoError(t, LOG_DB.Model(&Log{}).Where("user_id = ? AND username = ? AND type = ?", target.Id, "target", LogTypeConsume).Count(&logCount).Error)
+	require.EqualValues(t, 1, logCount)
+
+	merged, err := GetUserById(target.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, 1000, merged.Quota)
+	require.Equal(t, 70, merged.UsedQuota)
+	require.Equal(t, 7, merged.RequestCount)
+	require.Equal(t, 40, merged.AffQuota)
+	require.Equal(t, 60, merged.AffHistoryQuota)
+
+	for _, item := range []struct {
+		model  interface{}
+		column string
+		want   int64
+	}{
+		{&Task{}, "user_id", 1},
+		{&Midjourney{}, "user_id", 1},
+		{&QuotaData{}, "user_id", 1},
+		{&Redemption{}, "used_user_id", 1},
+		// 同一天的签到只保留 target 的记录
+		{&Checkin{}, "user_id", 2},
+	} {
+		var count int64
+		require.NoError(t, DB.Model(item.model).Where(item.column+" = ?", target.Id).Count(&count).Error)
+		require.Equal(t, item.want, count, "%T", item.model)
+	}
+
+	_, err = GetUserById(source.Id, true)
+	require.Error(t, err)
+	var deleted User
+	require.NoError(t, DB.Unscoped().First(&deleted, "id = ?", source.Id).Error)
+	require.Equal(t, common.UserStatusDisabled, deleted.Status)
+	require.Equal(t, 0, deleted.Quota)
+}
+
+func TestMergeUsers_DryRunChangesNothing(t *testing.T) {
+	setupTestDB(t, mergeTestModels...)
+	source, target := seedMergeUsers(t)
+
+	summary, err := MergeUsers(source.Id, target.Id, true)
+	require.NoError(t, err)
+	require.True(t, summary.DryRun)
+	require.EqualValues(t, 2, summary.Tokens)
+
+	var tokenCount int64
+	require.NoError(t, DB.Model(&Token{}).Where("user_id = ?", source.Id).Count(&tokenCount).Error)
+	require.EqualValues(t, 2, tokenCount)
+	unchanged, err := GetUserById(target.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, 700, unchanged.Quota)
+}
+
+func TestMergeUsers_RejectsDisabledTarget(t *testing.T) {
+	setupTestDB(t, mergeTestModels...)
+	source, target := seedMergeUsers(t)
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", target.Id).Update("status", common.UserStatusDisabled).Error)
+
+	_, err := MergeUsers(source.Id, target.Id, false)
+	require.Error(t, err)
+	var tokenCount int64
+	require.NoError(t, DB.Model(&Token{}).Where("user_id = ?", source.Id).Count(&tokenCount).Error)
+	require.EqualValues(t, 2, tokenCount)
+}
+
+func TestMergeUsers_RejectsSelfMerge(t *testing.T) {
+	_, err := MergeUsers(1, 1, false)
+	require.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// webhook 投递状态
+const (
+	WebhookDeliveryStatusPending   = "pending"   // 等待（重新）投递
+	WebhookDeliveryStatusSucceeded = "succeeded" // 投递成功
+	WebhookDeliveryStatusDead      = "dead"      // 重试次数耗尽，进入死信
+)
+
+// WebhookDeliveryMaxAttempts 自动重试的最大次数，超过后进入死信
+const WebhookDeliveryMaxAttempts = 5
+
+// WebhookDelivery webhook 投递记录（outbox），payload 完整保存以便重放时原样发送
+type WebhookDelivery struct {
+	Id          int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	DeliveryId  string `json:"delivery_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Url         string `json:"url" gorm:"type:varchar(1024)"`
+	EventType   string `json:"event_type" gorm:"type:varchar(64)"`
+	Payload     string `json:"payload" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	Attempts    int    `json:"attempts" gorm:"default:0"`
+	LastError   string `json:"last_error" gorm:"type:text"`
+	NextRetryAt int64  `json:"next_retry_at" gorm:"bigint;index"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	now := common.GetTimestamp()
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	if delivery.Status == "" {
+		delivery.Status = WebhookDeliveryStatusPending
+	}
+	return DB.Create(delivery).Error
+}
+
+// RecordAttempt 记录一次投递结果：成功置为 succeeded；失败按指数退避安排下次重试，
+// 次数耗尽后进入死信
+func (delivery *WebhookDelivery) RecordAttempt(sendErr error, now time.Time) error {
+	delivery.Attempts++
+	delivery.UpdatedAt = now.Unix()
+	if sendErr == nil {
+		delivery.Status = WebhookDeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.NextRetryAt = 0
+	} else {
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= WebhookDeliveryMaxAttempts {
+			delivery.Status = WebhookDeliveryStatusDead
+			delivery.NextRetryAt = 0
+		} else {
+			delivery.Status = WebhookDeliveryStatusPending
+			backoff := time.Minute << (delivery.Attempts - 1)
+			delivery.NextRetryAt = now.Add(backoff).Unix()
+		}
+	}
+	return DB.Model(delivery).Select("attempts", "status", "last_error", "next_retry_at", "updated_at").Updates(delivery).Error
+}
+
+func GetWebhookDeliveryById(id int) (*WebhookDelivery, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	delivery := WebhookDelivery{}
+	err := DB.First(&delivery, "id = ?", id).Error
+	return &delivery, err
+}
+
+// GetDueWebhookDeliveries 获取到期需要重试的投递
+func GetDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? AND attempts > 0 AND next_retry_at <= ?", WebhookDeliveryStatusPending, now).
+		Order("next_retry_at asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// DeleteSucceededWebhookDeliveries 删除 before 之前已重试成功的投递记录，返回删除条数
+func DeleteSucceededWebhookDeliveries(before int64, limit int) (int64, error) {
+	var ids []int
+	err := DB.Model(&WebhookDelivery{}).Where("status = ? AND updated_at < ?", WebhookDeliveryStatusSucceeded, before).
+		Limit(limit).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	result := DB.Where("id IN ?", ids).Delete(&WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// GetFailedWebhookDeliveries 分页获取失败的投递，status 为空时同时返回重试中与死信的记录
+func GetFailedWebhookDeliveries(status string, startIdx int, num int) (deliveries []*WebhookDelivery, total int64, err error) {
+	tx := DB.Model(&WebhookDelivery{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	} else {
+		tx = tx.Where("status = ? OR (status = ? AND attempts > 0)", WebhookDeliveryStatusDead, WebhookDeliveryStatusPending)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -15,7 +16,9 @@ import (
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relay/transformer"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -38,6 +41,26 @@ func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Hea
 	}
 }
 
+// DefaultUserAgent 渠道未配置 User-Agent 时请求上游使用的默认值
+func DefaultUserAgent() string {
+	return "new-api/" + common2.Version
+}
+
+// applyChannelIdentityHeaders 设置渠道配置的 User-Agent 与来源标识头，Header Override 仍可覆盖
+func applyChannelIdentityHeaders(info *common.RelayInfo, headers http.Header) {
+	if info.ChannelSetting.UserAgent != "" {
+		headers.Set("User-Agent", info.ChannelSetting.UserAgent)
+	} else if headers.Get("User-Agent") == "" {
+		headers.Set("User-Agent", DefaultUserAgent())
+	}
+	for key, value := range info.ChannelSetting.AttributionHeaders {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		headers.Set(key, value)
+	}
+}
+
 const clientHeaderPlaceholderPrefix = "{client_header:"
 
 func applyHeaderOverridePlaceholders(template string, c *gin.Context, apiKey string) (string, bool, error) {
@@ -106,6 +129,18 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if common2.DebugEnabled {
 		println("fullRequestURL:", fullRequestURL)
 	}
+	transformers := info.ChannelSetting.Transformers
+	if len(transformers) > 0 && requestBody != nil {
+		body, err := io.ReadAll(requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("read request body failed: %w", err)
+		}
+		body, err = transformer.ApplyRequest(transformers, body)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeChannelTransformerFailed, types.ErrOptionWithSkipRetry())
+		}
+		requestBody = bytes.NewReader(body)
+	}
 	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
@@ -115,6 +150,7 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelIdentityHeaders(info, headers)
 	// 在 SetupRequestHeader 之后应用 Header Override，确保用户设置优先级最高
 	// 这样可以覆盖默认的 Authorization header 设置
 	headerOverride, err := processHeaderOverride(info, c)
@@ -128,6 +164,12 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err := limitResponseSize(c, info, resp); err != nil {
+		return nil, err
+	}
+	if err := transformer.WrapResponse(transformers, resp); err != nil {
+		return nil, fmt.Errorf("transform response failed: %w", err)
+	}
 	return resp, nil
 }
 
@@ -150,6 +192,7 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelIdentityHeaders(info, headers)
 	// 在 SetupRequestHeader 之后应用 Header Override，确保用户设置优先级最高
 	// 这样可以覆盖默认的 Authorization header 设置
 	headerOverride, err := processHeaderOverride(info, c)
@@ -163,6 +206,9 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err := limitResponseSize(c, info, resp); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
@@ -176,6 +222,7 @@ func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	applyChannelIdentityHeaders(info, targetHeader)
 	// 在 SetupRequestHeader 之后应用 Header Override，确保用户设置优先级最高
 	// 这样可以覆盖默认的 Authorization header 设置
 	headerOverride, err := processHeaderOverride(info, c)
@@ -330,20 +377,57 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	// 模型单独配置了超时时，以模型超时作为上游请求的截止时间，替代客户端的默认超时
+	var cancelTimeout context.CancelFunc
+	timeout, hasModelTimeout := model_setting.GetModelTimeout(info.OriginModelName, info.UpstreamModelName)
+	if hasModelTimeout {
+		modelClient := *client
+		modelClient.Timeout = 0
+		client = &modelClient
+		var ctx context.Context
+		ctx, cancelTimeout = context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
+		if cancelTimeout != nil {
+			cancelTimeout()
+		}
 		logger.LogError(c, "do request failed: "+err.Error())
+		if hasModelTimeout && errors.Is(err, context.DeadlineExceeded) {
+			return nil, types.NewErrorWithStatusCode(fmt.Errorf("upstream request exceeded model timeout %s", timeout), types.ErrorCodeChannelResponseTimeExceeded, http.StatusGatewayTimeout)
+		}
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
 	}
 	if resp == nil {
+		if cancelTimeout != nil {
+			cancelTimeout()
+		}
 		return nil, errors.New("resp is nil")
 	}
+	if cancelTimeout != nil {
+		// 截止时间同样约束响应体的读取，响应体关闭时释放
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancelTimeout}
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
 	return resp, nil
 }
 
+// cancelOnCloseBody 关闭响应体时取消请求上下文
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 func DoTaskApiRequest(a TaskAdaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.BuildRequestURL(info)
 	if err != nil {
@@ -0,0 +1,178 @@
+package channel
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRequest_ModelTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(1500 * time.Millisecond):
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	settings := model_setting.GetModelTimeoutSettings()
+	orig := settings.Timeouts
+	t.Cleanup(func() { settings.Timeouts = orig })
+	settings.Timeouts = map[string]int{"o1": 5, "gpt-4o-mini": 1}
+
+	send := func(modelName string) (*http.Response, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+		req, err := http.NewRequest(http.MethodPost, upstream.URL, strings.NewReader("{}"))
+		require.NoError(t, err)
+		return DoRequest(c, req, &common.RelayInfo{OriginModelName: modelName, ChannelMeta: &common.ChannelMeta{}})
+	}
+
+	// 慢模型允许更长的超时
+	resp, err := send("o1")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.JSONEq(t, `{"ok":true}`, string(body))
+
+	// 快模型超时更短，超时后返回渠道响应超时
+	start := time.Now()
+	_, err = send("gpt-4o-mini")
+	require.Less(t, time.Since(start), 1400*time.Millisecond)
+	var apiErr *types.NewAPIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, types.ErrorCodeChannelResponseTimeExceeded, apiErr.GetErrorCode())
+	require.Equal(t, http.StatusGatewayTimeout, apiErr.StatusCode)
+}
+
+type headerStubAdaptor struct {
+	Adaptor
+	url string
+}
+
+func (a *headerStubAdaptor) GetRequestURL(info *common.RelayInfo) (string, error) {
+	return a.url, nil
+}
+
+func (a *headerStubAdaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *common.RelayInfo) error {
+	SetupApiRequestHeader(info, c, req)
+	req.Set("Authorization", "Bearer "+info.ApiKey)
+	return nil
+}
+
+func TestDoApiRequest_ChannelUserAgentAndAttributionHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	send := func(settings dto.ChannelSettings) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+		c.Request.Header.Set("User-Agent", "client-sdk/1.0")
+		info := &common.RelayInfo{ChannelMeta: &common.ChannelMeta{ApiKey: "sk-test", ChannelSetting: settings}}
+		resp, err := DoApiRequest(&headerStubAdaptor{url: upstream.URL}, c, info, strings.NewReader("{}"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// 未配置时使用网关默认 User-Agent，不透传客户端的 User-Agent
+	send(dto.ChannelSettings{})
+	require.Equal(t, DefaultUserAgent(), received.Get("User-Agent"))
+	require.Empty(t, received.Get("X-Title"))
+
+	send(dto.ChannelSettings{
+		UserAgent: "my-gateway/2.0",
+		AttributionHeaders: map[string]string{
+			"HTTP-Referer": "https://gateway.example.com",
+			"X-Title":      "Example Gateway",
+		},
+	})
+	require.Equal(t, "my-gateway/2.0", received.Get("User-Agent"))
+	require.Equal(t, "https://gateway.example.com", received.Get("HTTP-Referer"))
+	require.Equal(t, "Example Gateway", received.Get("X-Title"))
+	require.Equal(t, "Bearer sk-test", received.Get("Authorization"))
+}
+
+func TestDoApiRequest_ResponseSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	setting := operation_setting.GetResponseSizeSetting()
+	orig := *setting
+	t.Cleanup(func() { *setting = orig })
+	setting.MaxResponseBytes = 1024
+	setting.MaxStreamBytes = 2048
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 100; i++ {
+				_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"0123456789\"}}]}\n\n"))
+				w.(http.Flusher).Flush()
+			}
+		case "/chunked":
+			// 不带 Content-Length，读取时才能发现超限
+			for i := 0; i < 10; i++ {
+				_, _ = w.Write([]byte(strings.Repeat("x", 512)))
+				w.(http.Flusher).Flush()
+			}
+		default:
+			w.Header().Set("Content-Length", "4096")
+			_, _ = w.Write([]byte(strings.Repeat("x", 4096)))
+		}
+	}))
+	defer upstream.Close()
+
+	send := func(path string) (*http.Response, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+		return DoApiRequest(&headerStubAdaptor{url: upstream.URL + path}, c, &common.RelayInfo{ChannelMeta: &common.ChannelMeta{}}, strings.NewReader("{}"))
+	}
+	requireTooLarge := func(err error) {
+		var apiErr *types.NewAPIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, types.ErrorCodeChannelResponseTooLarge, apiErr.GetErrorCode())
+		require.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	}
+
+	// 非流式响应：Content-Length 超限时直接中止
+	_, err := send("/")
+	requireTooLarge(err)
+
+	// 非流式响应：读取过程中超限时返回错误
+	resp, err := send("/chunked")
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	requireTooLarge(err)
+	require.NoError(t, resp.Body.Close())
+
+	// 流式响应：截断到上限并正常结束，只保留已收到的内容
+	resp, err = send("/stream")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Len(t, body, 2048)
+}
@@ -0,0 +1,81 @@
+package channel
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func responseTooLargeError(limit int64) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(fmt.Errorf("上游响应超过最大长度 %d 字节", limit),
+		types.ErrorCodeChannelResponseTooLarge, http.StatusBadGateway, types.ErrOptionWithSkipRetry())
+}
+
+// limitedResponseBody 限制读取的响应体长度，超过上限时截断为 EOF 或返回错误
+type limitedResponseBody struct {
+	io.ReadCloser
+	c         *gin.Context
+	limit     int64
+	remaining int64
+	truncate  bool
+	exceeded  bool
+}
+
+func (b *limitedResponseBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, b.exceededErr()
+	}
+	// 多读一个字节用于判断是否超过上限
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	if b.truncate {
+		logger.LogWarn(b.c, fmt.Sprintf("stream response exceeded %d bytes, truncated", b.limit))
+	}
+	return n, b.exceededErr()
+}
+
+func (b *limitedResponseBody) exceededErr() error {
+	if b.truncate {
+		return io.EOF
+	}
+	return responseTooLargeError(b.limit)
+}
+
+// limitResponseSize 按配置限制上游响应大小，非流式响应超限时直接报错，流式响应超限时截断
+func limitResponseSize(c *gin.Context, info *common.RelayInfo, resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	setting := operation_setting.GetResponseSizeSetting()
+	stream := info.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	limit := setting.MaxResponseBytes
+	if stream {
+		limit = setting.MaxStreamBytes
+	}
+	if limit <= 0 {
+		return nil
+	}
+	if !stream && resp.ContentLength > limit {
+		_ = resp.Body.Close()
+		return responseTooLargeError(limit)
+	}
+	resp.Body = &limitedResponseBody{ReadCloser: resp.Body, c: c, limit: limit, remaining: limit, truncate: stream}
+	return nil
+}
@@ -39,6 +39,14 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	clearTopP, newAPIError := applyModelParameterConstraints(info.UpstreamModelName, &request.Temperature, request.TopP != 0)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	if clearTopP {
+		request.TopP = 0
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
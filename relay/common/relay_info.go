@@ -96,6 +96,7 @@ type RelayInfo struct {
 	IsPlayground           bool
 	UsePrice               bool
 	RelayMode              int
+	Endpoint               string // 接口类别（见 operation_setting.Endpoint* 常量），用于分组接口额度
 	OriginModelName        string
 	RequestURLPath         string
 	ShouldIncludeUsage     bool
@@ -49,18 +49,36 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	clearTopP, newAPIError := applyModelParameterConstraints(info.UpstreamModelName, &request.Temperature, request.TopP != 0)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	if clearTopP {
+		request.TopP = 0
+	}
+
 	includeUsage := true
 	// 判断用户是否需要返回使用情况
 	if request.StreamOptions != nil {
 		includeUsage = request.StreamOptions.IncludeUsage
 	}
 
+	// 以流式请求上游，由网关聚合为非流式响应，需要上游返回用量
+	upstreamStream := shouldUseUpstreamStream(info, request) &&
+		!service.ShouldChatCompletionsUseResponsesGlobal(info.ChannelId, info.ChannelType, info.OriginModelName)
+	if upstreamStream {
+		request.Stream = true
+		info.IsStream = true
+		info.DisablePing = true
+		includeUsage = true
+	}
+
 	// 如果不支持StreamOptions，将StreamOptions设置为nil
 	if !info.SupportStreamOptions || !request.Stream {
 		request.StreamOptions = nil
 	} else {
 		// 如果支持StreamOptions，且请求中没有设置StreamOptions，根据配置文件设置StreamOptions
-		if constant.ForceStreamOption {
+		if constant.ForceStreamOption || upstreamStream {
 			request.StreamOptions = &dto.StreamOptions{
 				IncludeUsage: true,
 			}
@@ -200,7 +218,13 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
-	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	var usage any
+	var newApiErr *types.NewAPIError
+	if upstreamStream {
+		usage, newApiErr = doUpstreamStreamResponse(c, info, adaptor, httpResp)
+	} else {
+		usage, newApiErr = adaptor.DoResponse(c, httpResp, info)
+	}
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
@@ -326,6 +350,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	}
 
 	var quotaCalculateDecimal decimal.Decimal
+	exactBilling := service.IsExactBillingEnabled()
 
 	var audioInputQuota decimal.Decimal
 	var audioInputPrice float64
@@ -375,7 +400,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 
 		quotaCalculateDecimal = promptQuota.Add(completionQuota).Mul(ratio)
 
-		if !ratio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
+		if !exactBilling && !ratio.IsZero() && quotaCalculateDecimal.LessThanOrEqual(decimal.Zero) {
 			quotaCalculateDecimal = decimal.NewFromInt(1)
 		}
 	} else {
@@ -397,8 +422,13 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		}
 	}
 
-	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 	totalTokens := promptTokens + completionTokens
+	var quota int
+	if exactBilling && totalTokens != 0 {
+		quota = service.SettleExactQuota(relayInfo.UserId, quotaCalculateDecimal)
+	} else {
+		quota = int(quotaCalculateDecimal.Round(0).IntPart())
+	}
 
 	//var logContent string
 
@@ -411,7 +441,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, relayInfo.FinalPreConsumedQuota))
 	} else {
-		if !ratio.IsZero() && quota == 0 {
+		if !exactBilling && !ratio.IsZero() && quota == 0 {
 			quota = 1
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
@@ -454,6 +484,10 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	}
 	logContent := strings.Join(extraContent, ", ")
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	service.AppendUsageDetailsInfo(other, usage)
+	if exactBilling {
+		other["exact_quota"] = quotaCalculateDecimal.String()
+	}
 	if adminRejectReason != "" {
 		other["reject_reason"] = adminRejectReason
 	}
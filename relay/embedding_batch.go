@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// embeddingBatchItem 上游返回的单条 embedding，embedding 可能是浮点数组或 base64 字符串，原样透传
+type embeddingBatchItem struct {
+	Object    string          `json:"object"`
+	Index     int             `json:"index"`
+	Embedding json.RawMessage `json:"embedding"`
+}
+
+type embeddingBatchItemResponse struct {
+	Model string               `json:"model"`
+	Data  []embeddingBatchItem `json:"data"`
+	Usage dto.Usage            `json:"usage"`
+}
+
+type embeddingBatchError struct {
+	Index int               `json:"index"`
+	Error types.OpenAIError `json:"error"`
+}
+
+// embeddingBatchResponse 部分成功时返回的响应，errors 中列出失败的输入
+type embeddingBatchResponse struct {
+	Object string                `json:"object"`
+	Data   []embeddingBatchItem  `json:"data"`
+	Model  string                `json:"model"`
+	Usage  dto.Usage             `json:"usage"`
+	Errors []embeddingBatchError `json:"errors"`
+}
+
+// shouldFanOutEmbeddingBatch 批量请求因输入问题失败（4xx，鉴权与限流除外）时，才逐条重试以返回部分结果
+func shouldFanOutEmbeddingBatch(request *dto.EmbeddingRequest, apiErr *types.NewAPIError) bool {
+	if !model_setting.GetGlobalSettings().EmbeddingPartialResultEnabled {
+		return false
+	}
+	inputs, ok := request.Input.([]any)
+	if !ok || len(inputs) < 2 {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+}
+
+// fanOutEmbeddingBatch 逐条处理输入并汇总结果，只累计成功条目的用量
+func fanOutEmbeddingBatch(inputs []any, doItem func(input any) (*embeddingBatchItemResponse, *types.NewAPIError)) (*embeddingBatchResponse, int) {
+	result := &embeddingBatchResponse{
+		Object: "list",
+		Data:   make([]embeddingBatchItem, 0, len(inputs)),
+		Errors: make([]embeddingBatchError, 0),
+	}
+	succeeded := 0
+	for i, input := range inputs {
+		itemResp, apiErr := doItem(input)
+		if apiErr == nil && (itemResp == nil || len(itemResp.Data) == 0) {
+			apiErr = types.NewOpenAIError(fmt.Errorf("empty embedding response"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		if apiErr != nil {
+			result.Errors = append(result.Errors, embeddingBatchError{Index: i, Error: apiErr.ToOpenAIError()})
+			continue
+		}
+		succeeded++
+		if result.Model == "" {
+			result.Model = itemResp.Model
+		}
+		item := itemResp.Data[0]
+		item.Index = i
+		result.Data = append(result.Data, item)
+		result.Usage.PromptTokens += itemResp.Usage.PromptTokens
+		result.Usage.TotalTokens += itemResp.Usage.TotalTokens
+	}
+	return result, succeeded
+}
+
+// relayEmbeddingBatchItems 将批量输入逐条发送给同一渠道，至少有一条成功时以 207 返回部分结果并按成功条目计费
+func relayEmbeddingBatchItems(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.EmbeddingRequest) *types.NewAPIError {
+	inputs := request.Input.([]any)
+	result, succeeded := fanOutEmbeddingBatch(inputs, func(input any) (*embeddingBatchItemResponse, *types.NewAPIError) {
+		itemRequest := *request
+		itemRequest.Input = []any{input}
+		httpResp, apiErr := doEmbeddingRequest(c, info, adaptor, itemRequest)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		return captureEmbeddingResponse(c, info, adaptor, httpResp)
+	})
+	if succeeded == 0 {
+		return types.NewOpenAIError(fmt.Errorf("all embedding inputs failed"), types.ErrorCodeBadResponse, http.StatusBadRequest)
+	}
+	logger.LogInfo(c, fmt.Sprintf("embedding batch partially succeeded: %d/%d", succeeded, len(inputs)))
+	c.JSON(http.StatusMultiStatus, result)
+	postConsumeQuota(c, info, &result.Usage)
+	return nil
+}
+
+// captureEmbeddingResponse 复用适配器的响应转换，但将输出写入缓冲区而不是客户端
+func captureEmbeddingResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (*embeddingBatchItemResponse, *types.NewAPIError) {
+	originWriter := c.Writer
+	capture := &responseCaptureWriter{ResponseWriter: originWriter, header: http.Header{}, status: http.StatusOK}
+	c.Writer = capture
+	usage, apiErr := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = originWriter
+	if apiErr != nil {
+		service.ResetStatusCode(apiErr, c.GetString("status_code_mapping"))
+		return nil, apiErr
+	}
+	var itemResp embeddingBatchItemResponse
+	if err := common.Unmarshal(capture.body.Bytes(), &itemResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if u, ok := usage.(*dto.Usage); ok && u != nil {
+		itemResp.Usage = *u
+	}
+	return &itemResp, nil
+}
@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanOutEmbeddingBatch_OneInputFails(t *testing.T) {
+	inputs := []any{"hello", "bad input", "world"}
+	result, succeeded := fanOutEmbeddingBatch(inputs, func(input any) (*embeddingBatchItemResponse, *types.NewAPIError) {
+		if input == "bad input" {
+			return nil, types.NewOpenAIError(errors.New("input is too long"), types.ErrorCodeBadResponseStatusCode, http.StatusBadRequest)
+		}
+		return &embeddingBatchItemResponse{
+			Model: "text-embedding-3-small",
+			Data:  []embeddingBatchItem{{Object: "embedding", Index: 0, Embedding: json.RawMessage(`[0.1,0.2]`)}},
+			Usage: dto.Usage{PromptTokens: 3, TotalTokens: 3},
+		}, nil
+	})
+
+	require.Equal(t, 2, succeeded)
+	require.Len(t, result.Data, 2)
+	require.Equal(t, 0, result.Data[0].Index)
+	require.Equal(t, 2, result.Data[1].Index)
+	require.Len(t, result.Errors, 1)
+	require.Equal(t, 1, result.Errors[0].Index)
+	require.Contains(t, result.Errors[0].Error.Message, "input is too long")
+	// 只对成功的条目计费
+	require.Equal(t, 6, result.Usage.PromptTokens)
+	require.Equal(t, 6, result.Usage.TotalTokens)
+}
+
+func TestShouldFanOutEmbeddingBatch(t *testing.T) {
+	settings := model_setting.GetGlobalSettings()
+	orig := settings.EmbeddingPartialResultEnabled
+	t.Cleanup(func() { settings.EmbeddingPartialResultEnabled = orig })
+
+	batch := &dto.EmbeddingRequest{Input: []any{"a", "b"}}
+	badRequest := types.NewOpenAIError(errors.New("bad"), types.ErrorCodeBadResponseStatusCode, http.StatusBadRequest)
+
+	settings.EmbeddingPartialResultEnabled = false
+	require.False(t, shouldFanOutEmbeddingBatch(batch, badRequest))
+
+	settings.EmbeddingPartialResultEnabled = true
+	require.True(t, shouldFanOutEmbeddingBatch(batch, badRequest))
+	require.False(t, shouldFanOutEmbeddingBatch(&dto.EmbeddingRequest{Input: "a"}, badRequest))
+	require.False(t, shouldFanOutEmbeddingBatch(batch, types.NewOpenAIError(errors.New("rate"), types.ErrorCodeBadResponseStatusCode, http.StatusTooManyRequests)))
+	require.False(t, shouldFanOutEmbeddingBatch(batch, types.NewOpenAIError(errors.New("down"), types.ErrorCodeBadResponseStatusCode, http.StatusBadGateway)))
+}
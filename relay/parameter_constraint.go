@@ -0,0 +1,26 @@
+package relay
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// applyModelParameterConstraints 按模型配置修正或拒绝 temperature/top_p，返回是否需要清除 top_p
+func applyModelParameterConstraints(modelName string, temperature **float64, topPSet bool) (bool, *types.NewAPIError) {
+	constraint, ok := model_setting.GetModelParameterConstraint(modelName)
+	if !ok {
+		return false, nil
+	}
+	fixed, err := constraint.ApplyTemperature(modelName, *temperature)
+	if err != nil {
+		return false, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	*temperature = fixed
+	keepTopP, err := constraint.AllowTopP(modelName, topPSet)
+	if err != nil {
+		return false, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return topPSet && !keepTopP, nil
+}
@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCaptureWriter 将适配器写给客户端的响应缓存在内存中，用于在网关内部二次处理响应
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseCaptureWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseCaptureWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *responseCaptureWriter) WriteHeaderNow() {}
+
+func (w *responseCaptureWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *responseCaptureWriter) Status() int {
+	return w.status
+}
+
+func (w *responseCaptureWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *responseCaptureWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *responseCaptureWriter) Flush() {}
@@ -65,6 +65,14 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	clearTopP, newAPIError := applyModelParameterConstraints(info.UpstreamModelName, &request.Temperature, request.TopP != nil)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	if clearTopP {
+		request.TopP = nil
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
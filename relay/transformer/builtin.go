@@ -0,0 +1,124 @@
+package transformer
+
+import (
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 内置转换器名称
+const (
+	NameDeveloperRoleToSystem       = "developer_role_to_system"
+	NameMaxCompletionTokens         = "max_completion_tokens_to_max_tokens"
+	NameReasoningToReasoningContent = "reasoning_to_reasoning_content"
+)
+
+func init() {
+	Register(&RoleRenameTransformer{name: NameDeveloperRoleToSystem, From: "developer", To: "system"})
+	Register(&FieldRenameTransformer{name: NameMaxCompletionTokens, RequestFields: map[string]string{"max_completion_tokens": "max_tokens"}})
+	Register(&FieldRenameTransformer{name: NameReasoningToReasoningContent, ResponseChoiceFields: map[string]string{"reasoning": "reasoning_content"}})
+}
+
+// NewFieldRenameTransformer 创建字段重命名转换器
+func NewFieldRenameTransformer(name string, requestFields map[string]string, responseFields map[string]string, responseChoiceFields map[string]string) *FieldRenameTransformer {
+	return &FieldRenameTransformer{
+		name:                 name,
+		RequestFields:        requestFields,
+		ResponseFields:       responseFields,
+		ResponseChoiceFields: responseChoiceFields,
+	}
+}
+
+// FieldRenameTransformer 重命名请求与响应中的字段，目标字段已存在时不覆盖
+type FieldRenameTransformer struct {
+	name string
+	// RequestFields 请求体顶层字段，key 为原字段，value 为新字段
+	RequestFields map[string]string
+	// ResponseFields 响应体顶层字段
+	ResponseFields map[string]string
+	// ResponseChoiceFields 响应 choices 中 message 与 delta 内的字段
+	ResponseChoiceFields map[string]string
+}
+
+func (t *FieldRenameTransformer) Name() string {
+	return t.name
+}
+
+func (t *FieldRenameTransformer) TransformRequest(body []byte) ([]byte, error) {
+	return renameFields(body, "", t.RequestFields)
+}
+
+func (t *FieldRenameTransformer) TransformResponse(body []byte) ([]byte, error) {
+	body, err := renameFields(body, "", t.ResponseFields)
+	if err != nil || len(t.ResponseChoiceFields) == 0 {
+		return body, err
+	}
+	choices := gjson.GetBytes(body, "choices")
+	if !choices.IsArray() {
+		return body, nil
+	}
+	for i := range choices.Array() {
+		for _, part := range []string{"message", "delta"} {
+			body, err = renameFields(body, "choices."+strconv.Itoa(i)+"."+part+".", t.ResponseChoiceFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return body, nil
+}
+
+// RoleRenameTransformer 将请求 messages 中的角色 From 替换为 To
+type RoleRenameTransformer struct {
+	name string
+	From string
+	To   string
+}
+
+func (t *RoleRenameTransformer) Name() string {
+	return t.name
+}
+
+func (t *RoleRenameTransformer) TransformRequest(body []byte) ([]byte, error) {
+	messages := gjson.GetBytes(body, "messages")
+	if !messages.IsArray() {
+		return body, nil
+	}
+	var err error
+	for i, message := range messages.Array() {
+		if message.Get("role").String() != t.From {
+			continue
+		}
+		body, err = sjson.SetBytes(body, "messages."+strconv.Itoa(i)+".role", t.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func (t *RoleRenameTransformer) TransformResponse(body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func renameFields(body []byte, prefix string, fields map[string]string) ([]byte, error) {
+	var err error
+	for from, to := range fields {
+		value := gjson.GetBytes(body, prefix+from)
+		if !value.Exists() {
+			continue
+		}
+		if !gjson.GetBytes(body, prefix+to).Exists() {
+			body, err = sjson.SetRawBytes(body, prefix+to, []byte(value.Raw))
+			if err != nil {
+				return nil, err
+			}
+		}
+		body, err = sjson.DeleteBytes(body, prefix+from)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,89 @@
+package transformer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WrapResponse 用转换器处理上游响应体，流式响应逐个转换 SSE data 块
+func WrapResponse(names []string, resp *http.Response) error {
+	if len(names) == 0 || resp == nil || resp.Body == nil {
+		return nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = newStreamBody(names, resp.Body)
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if transformed, err := ApplyResponse(names, body); err == nil {
+		body = transformed
+	}
+	// 非 JSON 响应（如错误页）转换失败时原样返回
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func newStreamBody(names []string, body io.ReadCloser) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+		buffered := bufio.NewReader(body)
+		for {
+			line, err := buffered.ReadBytes('\n')
+			if len(line) > 0 {
+				if _, writeErr := writer.Write(transformStreamLine(names, line)); writeErr != nil {
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					_ = writer.Close()
+				} else {
+					_ = writer.CloseWithError(err)
+				}
+				return
+			}
+		}
+	}()
+	return &streamBody{PipeReader: reader, upstream: body}
+}
+
+func transformStreamLine(names []string, line []byte) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(content, []byte("data:")) {
+		return line
+	}
+	data := bytes.TrimSpace(content[len("data:"):])
+	if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+		return line
+	}
+	transformed, err := ApplyResponse(names, data)
+	if err != nil {
+		return line
+	}
+	result := make([]byte, 0, len(transformed)+len(line)-len(content)+6)
+	result = append(result, "data: "...)
+	result = append(result, transformed...)
+	return append(result, line[len(content):]...)
+}
+
+// streamBody 关闭时同时关闭上游响应体，避免转换协程阻塞
+type streamBody struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+func (b *streamBody) Close() error {
+	_ = b.upstream.Close()
+	return b.PipeReader.Close()
+}
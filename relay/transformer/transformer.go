@@ -0,0 +1,101 @@
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Transformer 渠道请求/响应转换器，用于集中处理上游的非标准字段
+// 转换器按名称注册，渠道在额外设置 transformers 中按名称选用
+type Transformer interface {
+	Name() string
+	// TransformRequest 修改发往上游的请求体
+	TransformRequest(body []byte) ([]byte, error)
+	// TransformResponse 修改上游返回的响应体，流式响应按每个 SSE data 块调用
+	TransformResponse(body []byte) ([]byte, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]Transformer)
+)
+
+// Register 注册转换器，名称重复时覆盖
+func Register(t Transformer) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[t.Name()] = t
+}
+
+// Get 按名称获取转换器
+func Get(name string) (Transformer, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// Names 返回已注册的转换器名称
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 检查转换器名称是否都已注册
+func Validate(names []string) error {
+	for _, name := range names {
+		if _, ok := Get(name); !ok {
+			return fmt.Errorf("未知的转换器: %s", name)
+		}
+	}
+	return nil
+}
+
+func resolve(names []string) ([]Transformer, error) {
+	transformers := make([]Transformer, 0, len(names))
+	for _, name := range names {
+		t, ok := Get(name)
+		if !ok {
+			return nil, fmt.Errorf("未知的转换器: %s", name)
+		}
+		transformers = append(transformers, t)
+	}
+	return transformers, nil
+}
+
+// ApplyRequest 按配置顺序依次转换请求体
+func ApplyRequest(names []string, body []byte) ([]byte, error) {
+	transformers, err := resolve(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transformers {
+		body, err = t.TransformRequest(body)
+		if err != nil {
+			return nil, fmt.Errorf("转换器 %s 处理请求失败: %w", t.Name(), err)
+		}
+	}
+	return body, nil
+}
+
+// ApplyResponse 按配置的逆序依次转换响应体，与请求转换对称
+func ApplyResponse(names []string, body []byte) ([]byte, error) {
+	transformers, err := resolve(names)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(transformers) - 1; i >= 0; i-- {
+		body, err = transformers[i].TransformResponse(body)
+		if err != nil {
+			return nil, fmt.Errorf("转换器 %s 处理响应失败: %w", transformers[i].Name(), err)
+		}
+	}
+	return body, nil
+}
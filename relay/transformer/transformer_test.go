@@ -0,0 +1,52 @@
+package transformer
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestFieldRenameTransformer_BothDirections(t *testing.T) {
+	Register(NewFieldRenameTransformer("test_user_field",
+		map[string]string{"user": "end_user"},
+		map[string]string{"end_user": "user"},
+		nil))
+	names := []string{"test_user_field"}
+
+	request, err := ApplyRequest(names, []byte(`{"model":"m","user":"u-1"}`))
+	require.NoError(t, err)
+	require.False(t, gjson.GetBytes(request, "user").Exists())
+	require.Equal(t, "u-1", gjson.GetBytes(request, "end_user").String())
+
+	response, err := ApplyResponse(names, []byte(`{"id":"1","end_user":"u-1"}`))
+	require.NoError(t, err)
+	require.False(t, gjson.GetBytes(response, "end_user").Exists())
+	require.Equal(t, "u-1", gjson.GetBytes(response, "user").String())
+
+	_, err = ApplyRequest([]string{"missing"}, request)
+	require.Error(t, err)
+}
+
+func TestBuiltinTransformers(t *testing.T) {
+	request, err := ApplyRequest([]string{NameDeveloperRoleToSystem, NameMaxCompletionTokens},
+		[]byte(`{"messages":[{"role":"developer","content":"a"},{"role":"user","content":"b"}],"max_completion_tokens":16}`))
+	require.NoError(t, err)
+	require.Equal(t, "system", gjson.GetBytes(request, "messages.0.role").String())
+	require.Equal(t, "user", gjson.GetBytes(request, "messages.1.role").String())
+	require.EqualValues(t, 16, gjson.GetBytes(request, "max_tokens").Int())
+	require.False(t, gjson.GetBytes(request, "max_completion_tokens").Exists())
+
+	// 流式响应逐块转换 delta 中的字段
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:   io.NopCloser(strings.NewReader("data: {\"choices\":[{\"delta\":{\"reasoning\":\"think\"}}]}\n\ndata: [DONE]\n\n")),
+	}
+	require.NoError(t, WrapResponse([]string{NameReasoningToReasoningContent}, resp))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "data: {\"choices\":[{\"delta\":{\"reasoning_content\":\"think\"}}]}\n\ndata: [DONE]\n\n", string(body))
+}
@@ -0,0 +1,182 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shouldUseUpstreamStream 非流式的 chat completions 请求在渠道或模型开启后改为以流式请求上游，
+// 透传请求体时无法修改 stream 字段，不启用
+func shouldUseUpstreamStream(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if request.Stream || info.RelayMode != relayconstant.RelayModeChatCompletions || info.RelayFormat != types.RelayFormatOpenAI {
+		return false
+	}
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+		return false
+	}
+	return info.ChannelSetting.UpstreamStream || model_setting.IsUpstreamStreamModel(info.OriginModelName)
+}
+
+// doUpstreamStreamResponse 捕获适配器输出的 OpenAI 流式响应，聚合后以非流式响应返回给客户端
+func doUpstreamStreamResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (any, *types.NewAPIError) {
+	// 上游忽略了 stream 参数，直接按非流式处理
+	if httpResp != nil && !strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream") {
+		info.IsStream = false
+		return adaptor.DoResponse(c, httpResp, info)
+	}
+
+	originWriter := c.Writer
+	capture := &responseCaptureWriter{ResponseWriter: originWriter, header: http.Header{}, status: http.StatusOK}
+	c.Writer = capture
+	usage, apiErr := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = originWriter
+	info.IsStream = false
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	response, err := aggregateChatStreamResponse(capture.body.Bytes())
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if u, ok := usage.(*dto.Usage); ok && u != nil {
+		response.Usage = *u
+	}
+	if response.Model == "" {
+		response.Model = info.UpstreamModelName
+	}
+	c.JSON(http.StatusOK, response)
+	return usage, nil
+}
+
+type aggregatedStreamChoice struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	role         string
+	finishReason string
+	toolCalls    map[int]*dto.ToolCallResponse
+}
+
+// aggregateChatStreamResponse 将 SSE 形式的 chat completion chunk 合并为一个非流式响应
+func aggregateChatStreamResponse(body []byte) (*dto.OpenAITextResponse, error) {
+	response := &dto.OpenAITextResponse{Object: "chat.completion"}
+	choices := map[int]*aggregatedStreamChoice{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	chunks := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			return nil, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		chunks++
+		if response.Id == "" {
+			response.Id = chunk.Id
+			response.Created = chunk.Created
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+		for _, streamChoice := range chunk.Choices {
+			choice, ok := choices[streamChoice.Index]
+			if !ok {
+				choice = &aggregatedStreamChoice{role: "assistant", toolCalls: map[int]*dto.ToolCallResponse{}}
+				choices[streamChoice.Index] = choice
+			}
+			delta := streamChoice.Delta
+			if delta.Role != "" {
+				choice.role = delta.Role
+			}
+			choice.content.WriteString(delta.GetContentString())
+			if delta.ReasoningContent != nil {
+				choice.reasoning.WriteString(*delta.ReasoningContent)
+			} else if delta.Reasoning != nil {
+				choice.reasoning.WriteString(*delta.Reasoning)
+			}
+			for i, toolCall := range delta.ToolCalls {
+				index := i
+				if toolCall.Index != nil {
+					index = *toolCall.Index
+				}
+				existing, ok := choice.toolCalls[index]
+				if !ok {
+					call := toolCall
+					call.Index = nil
+					choice.toolCalls[index] = &call
+					continue
+				}
+				if toolCall.ID != "" {
+					existing.ID = toolCall.ID
+				}
+				if toolCall.Function.Name != "" {
+					existing.Function.Name = toolCall.Function.Name
+				}
+				existing.Function.Arguments += toolCall.Function.Arguments
+			}
+			if streamChoice.FinishReason != nil && *streamChoice.FinishReason != "" {
+				choice.finishReason = *streamChoice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if chunks == 0 {
+		return nil, fmt.Errorf("empty stream response")
+	}
+
+	indexes := make([]int, 0, len(choices))
+	for index := range choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	response.Choices = make([]dto.OpenAITextResponseChoice, 0, len(indexes))
+	for _, index := range indexes {
+		choice := choices[index]
+		message := dto.Message{Role: choice.role, ReasoningContent: choice.reasoning.String()}
+		message.SetStringContent(choice.content.String())
+		if len(choice.toolCalls) > 0 {
+			toolIndexes := make([]int, 0, len(choice.toolCalls))
+			for toolIndex := range choice.toolCalls {
+				toolIndexes = append(toolIndexes, toolIndex)
+			}
+			sort.Ints(toolIndexes)
+			toolCalls := make([]dto.ToolCallResponse, 0, len(toolIndexes))
+			for _, toolIndex := range toolIndexes {
+				toolCalls = append(toolCalls, *choice.toolCalls[toolIndex])
+			}
+			message.SetToolCalls(toolCalls)
+		}
+		response.Choices = append(response.Choices, dto.OpenAITextResponseChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: choice.finishReason,
+		})
+	}
+	return response, nil
+}
@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const upstreamStreamBody = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"x\"}"}}]},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":7,"completion_tokens":3,"total_tokens":10}}
+
+data: [DONE]
+
+`
+
+// streamingAdaptor 模拟适配器：把上游的流式响应原样写给客户端
+type streamingAdaptor struct {
+	channel.Adaptor
+}
+
+func (a *streamingAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	body, _ := io.ReadAll(resp.Body)
+	_, _ = c.Writer.Write(body)
+	c.Writer.Flush()
+	return &dto.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}, nil
+}
+
+func TestDoUpstreamStreamResponse_ReturnsNonStreamResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{IsStream: true, ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "gpt-4o"}}
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(upstreamStreamBody)),
+	}
+
+	usage, apiErr := doUpstreamStreamResponse(c, info, &streamingAdaptor{}, httpResp)
+	require.Nil(t, apiErr)
+	require.Equal(t, 10, usage.(*dto.Usage).TotalTokens)
+	require.False(t, info.IsStream)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json"))
+
+	var response dto.OpenAITextResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "chatcmpl-1", response.Id)
+	require.Equal(t, "chat.completion", response.Object)
+	require.Equal(t, "gpt-4o", response.Model)
+	require.Len(t, response.Choices, 1)
+	require.Equal(t, "assistant", response.Choices[0].Role)
+	require.Equal(t, "Hello", response.Choices[0].StringContent())
+	require.Equal(t, "tool_calls", response.Choices[0].FinishReason)
+	require.JSONEq(t, `[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":\"x\"}"}}]`, string(response.Choices[0].ToolCalls))
+	require.Equal(t, 7, response.Usage.PromptTokens)
+	require.Equal(t, 3, response.Usage.CompletionTokens)
+}
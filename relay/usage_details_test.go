@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const usageDetailsResponse = `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,"prompt_tokens_details":{"cached_tokens":400,"audio_tokens":0},"completion_tokens_details":{"reasoning_tokens":60,"audio_tokens":0}}}`
+
+func TestUsageDetails_BilledAndPassedThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	origDB, origLogDB, origRedis, origLogConsume := model.DB, model.LOG_DB, common.RedisEnabled, common.LogConsumeEnabled
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, common.RedisEnabled, common.LogConsumeEnabled = origDB, origLogDB, origRedis, origLogConsume
+	})
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Log{}, &model.Channel{}, &model.DailySpend{}))
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled = false
+	common.LogConsumeEnabled = true
+	user := &model.User{Username: "usage", Quota: 100000, AffCode: "u1"}
+	require.NoError(t, db.Create(user).Error)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{
+		UserId:          user.Id,
+		TokenUnlimited:  true,
+		OriginModelName: "gpt-4o",
+		StartTime:       time.Now(),
+		ChannelMeta:     &relaycommon.ChannelMeta{UpstreamModelName: "gpt-4o"},
+		PriceData: types.PriceData{
+			ModelRatio:      1,
+			CompletionRatio: 2,
+			CacheRatio:      0.5,
+			GroupRatioInfo:  types.GroupRatioInfo{GroupRatio: 1},
+		},
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(usageDetailsResponse)),
+	}
+
+	usage, apiErr := openai.OpenaiHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.Equal(t, 400, usage.PromptTokensDetails.CachedTokens)
+	require.Equal(t, 60, usage.CompletionTokenDetails.ReasoningTokens)
+	// 明细原样返回给客户端
+	require.Contains(t, recorder.Body.String(), `"prompt_tokens_details":{"cached_tokens":400`)
+	require.Contains(t, recorder.Body.String(), `"completion_tokens_details":{"reasoning_tokens":60`)
+
+	postConsumeQuota(c, info, usage)
+
+	var log model.Log
+	require.NoError(t, db.Where("type = ?", model.LogTypeConsume).First(&log).Error)
+	// 未命中缓存 600 + 缓存 400*0.5 + 输出 100*2 = 1000
+	require.Equal(t, 1000, log.Quota)
+	other, err := common.StrToMap(log.Other)
+	require.NoError(t, err)
+	require.EqualValues(t, 400, other["cache_tokens"])
+	require.EqualValues(t, 400, other["prompt_tokens_details"].(map[string]any)["cached_tokens"])
+	require.EqualValues(t, 60, other["completion_tokens_details"].(map[string]any)["reasoning_tokens"])
+}
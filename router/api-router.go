@@ -26,6 +26,7 @@ func SetApiRouter(router *gin.Engine) {
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
 		apiRouter.GET("/home_page_content", controller.GetHomePageContent)
 		apiRouter.GET("/pricing", middleware.TryUserAuth(), controller.GetPricing)
+		apiRouter.GET("/pricing/scheduled", middleware.TryUserAuth(), controller.GetScheduledRatios)
 		apiRouter.GET("/verification", middleware.EmailVerificationRateLimit(), middleware.TurnstileCheck(), controller.SendEmailVerification)
 		apiRouter.GET("/reset_password", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.SendPasswordResetEmail)
 		apiRouter.POST("/user/reset", middleware.CriticalRateLimit(), controller.ResetPassword)
@@ -75,6 +76,8 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/passkey/verify/begin", controller.PasskeyVerifyBegin)
 				selfRoute.POST("/passkey/verify/finish", controller.PasskeyVerifyFinish)
 				selfRoute.DELETE("/passkey", controller.PasskeyDelete)
+				selfRoute.GET("/identities", controller.GetUserIdentities)
+				selfRoute.DELETE("/identities/:provider", controller.UnlinkUserIdentity)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.GET("/topup/self", controller.GetUserTopUps)
@@ -109,6 +112,8 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.GET("/:id", controller.GetUser)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
+				adminRoute.POST("/merge", controller.MergeUsers)
+				adminRoute.POST("/group/:group/topup", controller.TopUpGroupUsers)
 				adminRoute.PUT("/", controller.UpdateUser)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
 				adminRoute.DELETE("/:id/reset_passkey", controller.AdminResetPasskey)
@@ -142,6 +147,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
 			channelRoute.GET("/:id", controller.GetChannel)
+			channelRoute.GET("/:id/usage", controller.GetChannelUsage)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
@@ -158,6 +164,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/fix", controller.FixChannelsAbilities)
 			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
 			channelRoute.POST("/fetch_models", controller.FetchModels)
+			channelRoute.POST("/sync_models", controller.SyncChannelsModels)
 			channelRoute.POST("/codex/oauth/start", controller.StartCodexOAuth)
 			channelRoute.POST("/codex/oauth/complete", controller.CompleteCodexOAuth)
 			channelRoute.POST("/:id/codex/oauth/start", controller.StartCodexOAuthForChannel)
@@ -179,10 +186,12 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/", controller.GetAllTokens)
 			tokenRoute.GET("/search", controller.SearchTokens)
 			tokenRoute.GET("/:id", controller.GetToken)
+			tokenRoute.GET("/:id/logs", controller.GetTokenLogs)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
+			tokenRoute.POST("/:id/restore", middleware.AdminAuth(), controller.RestoreSuspendedToken)
 		}
 
 		usageRoute := apiRouter.Group("/usage")
@@ -195,11 +204,33 @@ func SetApiRouter(router *gin.Engine) {
 			}
 		}
 
+		cacheRoute := apiRouter.Group("/cache")
+		cacheRoute.Use(middleware.AdminAuth())
+		{
+			cacheRoute.POST("/purge", controller.PurgeCache)
+		}
+		tenantRoute := apiRouter.Group("/tenant")
+		tenantRoute.Use(middleware.RootAuth())
+		{
+			tenantRoute.GET("/", controller.GetAllTenants)
+			tenantRoute.POST("/", controller.AddTenant)
+			tenantRoute.PUT("/", controller.UpdateTenant)
+			tenantRoute.DELETE("/:id", controller.DeleteTenant)
+		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
 			redemptionRoute.GET("/", controller.GetAllRedemptions)
 			redemptionRoute.GET("/search", controller.SearchRedemptions)
+			redemptionRoute.GET("/stats", controller.GetRedemptionStats)
+			redemptionRoute.POST("/stats/reconcile", controller.ReconcileRedemptionStats)
+			redemptionRoute.GET("/liability", controller.GetRedemptionLiability)
+			redemptionRoute.POST("/simulate", controller.SimulateRedemptionDistribution)
+			redemptionRoute.GET("/template/", controller.GetAllRedemptionTemplates)
+			redemptionRoute.POST("/template/", controller.AddRedemptionTemplate)
+			redemptionRoute.PUT("/template/", controller.UpdateRedemptionTemplate)
+			redemptionRoute.DELETE("/template/:id", controller.DeleteRedemptionTemplate)
+			redemptionRoute.POST("/template/:id/generate", controller.GenerateRedemptionsFromTemplate)
 			redemptionRoute.GET("/:id", controller.GetRedemption)
 			redemptionRoute.POST("/", controller.AddRedemption)
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
@@ -212,6 +243,7 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/diagnostics/:request_id", middleware.AdminAuth(), controller.GetRequestDiagnostics)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
@@ -227,6 +259,8 @@ func SetApiRouter(router *gin.Engine) {
 		groupRoute.Use(middleware.AdminAuth())
 		{
 			groupRoute.GET("/", controller.GetGroups)
+			groupRoute.GET("/endpoint_quota", controller.GetGroupEndpointQuotas)
+			groupRoute.POST("/endpoint_quota/reset", controller.ResetGroupEndpointQuota)
 		}
 
 		prefillGroupRoute := apiRouter.Group("/prefill_group")
@@ -238,6 +272,13 @@ func SetApiRouter(router *gin.Engine) {
 			prefillGroupRoute.DELETE("/:id", controller.DeletePrefillGroup)
 		}
 
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.AdminAuth())
+		{
+			webhookRoute.GET("/deliveries", controller.GetFailedWebhookDeliveries)
+			webhookRoute.POST("/deliveries/:id/replay", controller.ReplayWebhookDelivery)
+		}
+
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
 		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
@@ -8,11 +8,13 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetRouter(router *gin.Engine, buildFS embed.FS, indexPage []byte) {
+	router.Use(middleware.ResolveTenant())
 	SetApiRouter(router)
 	SetDashboardRouter(router)
 	SetRelayRouter(router)
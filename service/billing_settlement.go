@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const billingSettlementTickInterval = 1 * time.Minute
+
+var (
+	billingSettlementOnce    sync.Once
+	billingSettlementRunning atomic.Bool
+
+	// billingSettlementSender 实际上报结算数据的函数，便于测试替换
+	billingSettlementSender = postBillingSettlement
+	// billingSettlementRetryDelay 单次结算内重试的基础等待时间，按重试次数线性增加
+	billingSettlementRetryDelay = 5 * time.Second
+)
+
+// BillingSettlementPayload 上报给外部计费系统的结算数据，batch_id 同时放在 Idempotency-Key 请求头中，
+// 重试时保持不变，接收方可据此去重
+type BillingSettlementPayload struct {
+	BatchId      string                         `json:"batch_id"`
+	PeriodStart  int64                          `json:"period_start"` // 不含
+	PeriodEnd    int64                          `json:"period_end"`   // 含
+	QuotaPerUnit float64                        `json:"quota_per_unit"`
+	Items        []model.BillingSettlementUsage `json:"items"`
+}
+
+// StartBillingSettlementTask 按配置的间隔定期向外部计费系统上报消费
+func StartBillingSettlementTask() {
+	billingSettlementOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(billingSettlementTickInterval)
+			defer ticker.Stop()
+
+			var lastRun int64
+			for range ticker.C {
+				setting := operation_setting.GetBillingSettlementSetting()
+				if !setting.Enabled {
+					continue
+				}
+				now := common.GetTimestamp()
+				if now-lastRun < int64(max(setting.IntervalMinutes, 1))*60 {
+					continue
+				}
+				lastRun = now
+				if err := RunBillingSettlement(now); err != nil {
+					logger.LogError(context.Background(), fmt.Sprintf("billing settlement: %v", err))
+				}
+			}
+		})
+	})
+}
+
+// RunBillingSettlement 先补报未成功的批次，再把水位线之后的消费汇总为新批次上报。
+// 批次上报成功前水位线不会推进，失败的批次在下次结算时原样重报，不会与新批次重叠。
+// 首次结算只把水位线初始化为当前时间，不上报此前的历史消费
+func RunBillingSettlement(now int64) error {
+	if !billingSettlementRunning.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer billingSettlementRunning.Store(false)
+
+	setting := operation_setting.GetBillingSettlementSetting()
+	if setting.Endpoint == "" {
+		return errors.New("未配置外部计费系统地址")
+	}
+	pending, err := model.GetPendingBillingSettlement()
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		if err := deliverBillingSettlement(pending, setting); err != nil {
+			return err
+		}
+	}
+
+	// 结算按消费日志汇总，日志不完整时不生成新批次，避免少报
+	if !common.LogConsumeEnabled {
+		return errors.New("未开启消费日志，无法结算")
+	}
+	if operation_setting.SuccessLogSamplingActive() {
+		return errors.New("成功请求日志采样低于 100%，消费日志不完整，无法结算")
+	}
+
+	watermark, err := model.GetBillingSettlementWatermark()
+	if err != nil {
+		return err
+	}
+	end := now - setting.SettleDelaySeconds
+	if end <= watermark {
+		return nil
+	}
+	if watermark == 0 {
+		return model.CreateBillingSettlement(&model.BillingSettlement{
+			BatchId:     common.GetUUID(),
+			PeriodStart: end,
+			PeriodEnd:   end,
+			Status:      model.BillingSettlementStatusSucceeded,
+		})
+	}
+	usages, err := model.AggregateUnsettledUsage(watermark, end)
+	if err != nil {
+		return err
+	}
+	payload := BillingSettlementPayload{
+		BatchId:      common.GetUUID(),
+		PeriodStart:  watermark,
+		PeriodEnd:    end,
+		QuotaPerUnit: common.QuotaPerUnit,
+		Items:        usages,
+	}
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	settlement := &model.BillingSettlement{
+		BatchId:     payload.BatchId,
+		PeriodStart: watermark,
+		PeriodEnd:   end,
+		Users:       len(usages),
+		Payload:     string(payloadBytes),
+	}
+	for _, usage := range usages {
+		settlement.Quota += usage.Quota
+	}
+	// 周期内没有消费时不上报，只推进水位线
+	if len(usages) == 0 {
+		settlement.Status = model.BillingSettlementStatusSucceeded
+		return model.CreateBillingSettlement(settlement)
+	}
+	if err := model.CreateBillingSettlement(settlement); err != nil {
+		return err
+	}
+	return deliverBillingSettlement(settlement, setting)
+}
+
+// deliverBillingSettlement 上报一个批次，失败时按 MaxRetries 重试
+func deliverBillingSettlement(settlement *model.BillingSettlement, setting *operation_setting.BillingSettlementSetting) error {
+	var sendErr error
+	for attempt := 0; attempt <= max(setting.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			time.Sleep(billingSettlementRetryDelay * time.Duration(attempt))
+		}
+		sendErr = billingSettlementSender(setting.Endpoint, setting.ApiKey, settlement.BatchId, []byte(settlement.Payload))
+		if err := settlement.RecordAttempt(sendErr); err != nil {
+			return err
+		}
+		if sendErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("batch %s delivery failed after %d attempts: %w", settlement.BatchId, settlement.Attempts, sendErr)
+}
+
+// postBillingSettlement 发送结算数据，非 2xx 响应视为失败
+func postBillingSettlement(endpoint string, apiKey string, batchId string, payloadBytes []byte) error {
+	fetchSetting := system_setting.GetFetchSetting()
+	if err := common.ValidateURLWithFetchSetting(endpoint, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		return fmt.Errorf("request reject: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create settlement request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", batchId)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send settlement request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("settlement request failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
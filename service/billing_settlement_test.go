@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingSettlement_WatermarkAndRetry(t *testing.T) {
+	setupServiceTestDB(t, &model.Log{}, &model.BillingSettlement{})
+	setting := operation_setting.GetBillingSettlementSetting()
+	origSetting, origSender, origDelay := *setting, billingSettlementSender, billingSettlementRetryDelay
+	t.Cleanup(func() {
+		*setting = origSetting
+		billingSettlementSender, billingSettlementRetryDelay = origSender, origDelay
+	})
+	setting.Enabled = true
+	setting.Endpoint = "https://billing.example.com/settle"
+	setting.SettleDelaySeconds = 60
+	setting.MaxRetries = 2
+	billingSettlementRetryDelay = 0
+
+	insertLog := func(userId int, quota int, createdAt int64) {
+		require.NoError(t, model.LOG_DB.Create(&model.Log{UserId: userId, Username: "user", Type: model.LogTypeConsume, Quota: quota, CreatedAt: createdAt}).Error)
+	}
+	// 首次结算只初始化水位线，不上报历史消费
+	const now0 int64 = 1_800_000_000
+	end0 := now0 - setting.SettleDelaySeconds
+	insertLog(1, 1000, end0-100)
+	billingSettlementSender = func(endpoint string, apiKey string, batchId string, payloadBytes []byte) error {
+		t.Fatal("initial run must not report usage")
+		return nil
+	}
+	require.NoError(t, RunBillingSettlement(now0))
+	watermark, err := model.GetBillingSettlementWatermark()
+	require.NoError(t, err)
+	require.Equal(t, end0, watermark)
+
+	const now1 = now0 + 3600
+	end1 := now1 - setting.SettleDelaySeconds
+	insertLog(1, 100, end1-10)
+	insertLog(1, 50, end1)
+	insertLog(2, 30, end1-5)
+	// 尚在结算延迟内，不计入第一批
+	insertLog(2, 7, end1+1)
+
+	type sent struct {
+		batchId string
+		payload BillingSettlementPayload
+	}
+	var sends []sent
+	receiverDown := true
+	billingSettlementSender = func(endpoint string, apiKey string, batchId string, payloadBytes []byte) error {
+		var payload BillingSettlementPayload
+		require.NoError(t, common.Unmarshal(payloadBytes, &payload))
+		sends = append(sends, sent{batchId: batchId, payload: payload})
+		if receiverDown {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	// 全部重试失败：水位线不推进，批次保留为待重报
+	require.Error(t, RunBillingSettlement(now1))
+	require.Len(t, sends, 3)
+	watermark, err = model.GetBillingSettlementWatermark()
+	require.NoError(t, err)
+	require.Equal(t, end0, watermark)
+	pending, err := model.GetPendingBillingSettlement()
+	require.NoError(t, err)
+	require.NotNil(t, pending)
+	require.Equal(t, 3, pending.Attempts)
+	batchId := sends[0].batchId
+
+	// 恢复后补报同一批次，期间新增的消费不会混入
+	receiverDown = false
+	sends = nil
+	const now2 = now1 + 3600
+	end2 := now2 - setting.SettleDelaySeconds
+	insertLog(1, 20, end2-100)
+	require.NoError(t, RunBillingSettlement(now2))
+	require.Len(t, sends, 2)
+	require.Equal(t, batchId, sends[0].batchId)
+	require.Equal(t, end1, sends[0].payload.PeriodEnd)
+	require.Equal(t, []model.BillingSettlementUsage{
+		{UserId: 1, Username: "user", Quota: 150, Requests: 2},
+		{UserId: 2, Username: "user", Quota: 30, Requests: 1},
+	}, sends[0].payload.Items)
+
+	// 新批次从上一批的水位线开始
+	require.NotEqual(t, batchId, sends[1].batchId)
+	require.Equal(t, end1, sends[1].payload.PeriodStart)
+	require.Equal(t, end2, sends[1].payload.PeriodEnd)
+	require.Equal(t, []model.BillingSettlementUsage{
+		{UserId: 1, Username: "user", Quota: 20, Requests: 1},
+		{UserId: 2, Username: "user", Quota: 7, Requests: 1},
+	}, sends[1].payload.Items)
+	watermark, err = model.GetBillingSettlementWatermark()
+	require.NoError(t, err)
+	require.Equal(t, end2, watermark)
+
+	// 没有新消费时不再上报
+	sends = nil
+	require.NoError(t, RunBillingSettlement(now2+600))
+	require.Empty(t, sends)
+	pending, err = model.GetPendingBillingSettlement()
+	require.NoError(t, err)
+	require.Nil(t, pending)
+
+	// 成功日志采样时消费日志不完整，拒绝生成新批次
+	logSetting := operation_setting.GetLogSetting()
+	origPercent := logSetting.SuccessSamplePercent
+	t.Cleanup(func() { logSetting.SuccessSamplePercent = origPercent })
+	logSetting.SuccessSamplePercent = 50
+	insertLog(1, 40, now2+1000)
+	require.Error(t, RunBillingSettlement(now2+7200))
+	require.Empty(t, sends)
+	watermark, err = model.GetBillingSettlementWatermark()
+	require.NoError(t, err)
+	require.Equal(t, now2+600-setting.SettleDelaySeconds, watermark)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 当日消耗的本地缓存时间，避免每个请求都查询数据库
+const dailySpendCacheTTL = 5 * time.Second
+
+// 本地累计的当日消耗写入数据库的间隔，避免每个请求都更新同一行
+const dailySpendFlushInterval = 10 * time.Second
+
+var dailySpendCache = struct {
+	sync.Mutex
+	day       string
+	spent     int64
+	fetchedAt time.Time
+	// pending 为尚未写入数据库的消耗，按日期累计
+	pending map[string]int64
+}{pending: make(map[string]int64)}
+
+var dailySpendFlushOnce sync.Once
+
+func budgetDay(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// RecordDailySpend 累加全站当日消耗，quota 为本次请求的实际消耗
+func RecordDailySpend(relayInfo *relaycommon.RelayInfo, quota int) {
+	if quota <= 0 || relayInfo.IsPlayground {
+		return
+	}
+	if !operation_setting.GetBudgetPressureSetting().Enabled {
+		return
+	}
+	day := budgetDay(time.Now())
+	dailySpendCache.Lock()
+	dailySpendCache.pending[day] += int64(quota)
+	if dailySpendCache.day == day {
+		dailySpendCache.spent += int64(quota)
+	}
+	dailySpendCache.Unlock()
+}
+
+// StartDailySpendFlushTask 定期将本地累计的当日消耗写入数据库，每个节点各自写入自己的增量
+func StartDailySpendFlushTask() {
+	dailySpendFlushOnce.Do(func() {
+		gopool.Go(func() {
+			ticker := time.NewTicker(dailySpendFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				flushDailySpend()
+			}
+		})
+	})
+}
+
+// flushDailySpend 将本地累计的消耗写入数据库，写入失败的部分留待下次重试
+func flushDailySpend() {
+	dailySpendCache.Lock()
+	pending := dailySpendCache.pending
+	dailySpendCache.pending = make(map[string]int64)
+	dailySpendCache.Unlock()
+
+	for day, quota := range pending {
+		if err := model.AddDailySpend(day, quota); err != nil {
+			common.SysLog(fmt.Sprintf("failed to record daily spend: day=%s, error=%s", day, err.Error()))
+			dailySpendCache.Lock()
+			dailySpendCache.pending[day] += quota
+			dailySpendCache.Unlock()
+		}
+	}
+}
+
+func getCachedDailySpend(now time.Time) (int64, error) {
+	day := budgetDay(now)
+	dailySpendCache.Lock()
+	defer dailySpendCache.Unlock()
+	if dailySpendCache.day == day && now.Sub(dailySpendCache.fetchedAt) < dailySpendCacheTTL {
+		return dailySpendCache.spent, nil
+	}
+	spent, err := model.GetDailySpend(day)
+	if err != nil {
+		return 0, err
+	}
+	// 数据库中尚不包含本节点未写入的部分
+	spent += dailySpendCache.pending[day]
+	dailySpendCache.day = day
+	dailySpendCache.spent = spent
+	dailySpendCache.fetchedAt = now
+	return spent, nil
+}
+
+func resetDailySpendCache() {
+	dailySpendCache.Lock()
+	dailySpendCache.day = ""
+	dailySpendCache.spent = 0
+	dailySpendCache.fetchedAt = time.Time{}
+	dailySpendCache.pending = make(map[string]int64)
+	dailySpendCache.Unlock()
+}
+
+// ResolveBudgetPressureModel 当日消耗达到预算阈值时返回配置的替代模型，未降级时返回 false
+func ResolveBudgetPressureModel(modelName string) (string, bool) {
+	setting := operation_setting.GetBudgetPressureSetting()
+	if !setting.Enabled || modelName == "" {
+		return "", false
+	}
+	target, ok := setting.ModelMapping[modelName]
+	if !ok || target == "" || target == modelName {
+		return "", false
+	}
+	spent, err := getCachedDailySpend(time.Now())
+	if err != nil {
+		common.SysLog("failed to get daily spend: " + err.Error())
+		return "", false
+	}
+	if !operation_setting.IsBudgetPressureActive(spent) {
+		return "", false
+	}
+	return target, true
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBudgetPressureModel(t *testing.T) {
+	setupServiceTestDB(t, &model.DailySpend{})
+	setting := operation_setting.GetBudgetPressureSetting()
+	orig := *setting
+	t.Cleanup(func() {
+		*setting = orig
+		resetDailySpendCache()
+	})
+	resetDailySpendCache()
+	setting.Enabled = true
+	setting.DailyBudget = 1000
+	setting.ThresholdPercent = 80
+	setting.ModelMapping = map[string]string{"gpt-4o": "gpt-4o-mini"}
+
+	info := &relaycommon.RelayInfo{UserId: 1}
+	RecordDailySpend(info, 500)
+	// 低于阈值时正常路由
+	_, ok := ResolveBudgetPressureModel("gpt-4o")
+	require.False(t, ok)
+
+	RecordDailySpend(info, 300)
+	// 达到阈值后降级，未配置映射的模型不受影响
+	target, ok := ResolveBudgetPressureModel("gpt-4o")
+	require.True(t, ok)
+	require.Equal(t, "gpt-4o-mini", target)
+	_, ok = ResolveBudgetPressureModel("claude-3-5-sonnet")
+	require.False(t, ok)
+
+	// 未开启时不记录消耗
+	setting.Enabled = false
+	RecordDailySpend(info, 100)
+	setting.Enabled = true
+
+	// 消耗先在本地累计，定期写入数据库
+	spent, err := model.GetDailySpend(budgetDay(time.Now()))
+	require.NoError(t, err)
+	require.Zero(t, spent)
+	flushDailySpend()
+	spent, err = model.GetDailySpend(budgetDay(time.Now()))
+	require.NoError(t, err)
+	require.EqualValues(t, 800, spent)
+}
@@ -101,6 +101,33 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	return search
 }
 
+// IsUpstreamModelNotFoundError 判断上游是否以模型不存在拒绝请求，说明该渠道实际不承接该模型。
+// 仅认可 model_not_found 错误码，或 404 且错误信息中明确包含请求的模型名，避免把路径错误、参数错误误判为模型不存在
+func IsUpstreamModelNotFoundError(err *types.NewAPIError, modelName string) bool {
+	if err == nil || types.IsSkipRetryError(err) || types.IsChannelError(err) {
+		return false
+	}
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusNotFound {
+		return false
+	}
+	if code, ok := err.ToOpenAIError().Code.(string); ok && code == "model_not_found" {
+		return true
+	}
+	if err.StatusCode != http.StatusNotFound || modelName == "" {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), strings.ToLower(modelName))
+}
+
+// MarkChannelModelNotFound 上游返回模型不存在时标记渠道在一段时间内不承接该模型，返回是否已标记
+func MarkChannelModelNotFound(channelId int, modelName string, err *types.NewAPIError) bool {
+	if !operation_setting.GetFailoverSetting().ModelNotFoundRetryEnabled || modelName == "" || !IsUpstreamModelNotFoundError(err, modelName) {
+		return false
+	}
+	model.MarkChannelModelUnavailable(channelId, modelName, operation_setting.GetModelNotFoundTTL())
+	return true
+}
+
 func ShouldEnableChannel(newAPIError *types.NewAPIError, status int) bool {
 	if !common.AutomaticEnableChannelEnabled {
 		return false
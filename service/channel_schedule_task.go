@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const channelScheduleTickInterval = 1 * time.Minute
+
+var (
+	channelScheduleOnce    sync.Once
+	channelScheduleRunning atomic.Bool
+)
+
+// StartChannelScheduleTask 定时将渠道的时间窗口状态同步到 abilities，
+// 使非内存缓存模式下的选路与模型列表也能反映定时配置。
+// 分发选路时也会按请求判断窗口，本任务保证 abilities 与窗口状态一致。
+func StartChannelScheduleTask() {
+	channelScheduleOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(channelScheduleTickInterval)
+			defer ticker.Stop()
+
+			runChannelScheduleOnce()
+			for range ticker.C {
+				runChannelScheduleOnce()
+			}
+		})
+	})
+}
+
+func runChannelScheduleOnce() {
+	if !channelScheduleRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer channelScheduleRunning.Store(false)
+
+	ctx := context.Background()
+	var channels []*model.Channel
+	err := model.DB.
+		Select("id", "name", "status", "setting").
+		Where("status = ? AND setting LIKE ?", common.ChannelStatusEnabled, "%\"schedule\"%").
+		Find(&channels).Error
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("channel schedule: query channels failed: %v", err))
+		return
+	}
+
+	now := time.Now()
+	for _, channel := range channels {
+		active := channel.GetSetting().Schedule.IsActiveAt(now)
+		// 以 abilities 的实际状态为准，渠道被编辑后 abilities 会按渠道状态重建
+		mismatched, err := model.HasAbilityWithStatus(channel.Id, !active)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("channel schedule: query abilities of channel #%d failed: %v", channel.Id, err))
+			continue
+		}
+		if !mismatched {
+			continue
+		}
+		if err := model.UpdateAbilityStatus(channel.Id, active); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("channel schedule: update abilities of channel #%d failed: %v", channel.Id, err))
+			continue
+		}
+		state := "out of window"
+		if active {
+			state = "in window"
+		}
+		logger.LogInfo(ctx, fmt.Sprintf("channel schedule: channel #%d (%s) is now %s", channel.Id, channel.Name, state))
+	}
+}
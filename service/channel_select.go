@@ -45,6 +45,16 @@ func (p *RetryParam) ResetRetryNextTry() {
 	p.resetNextTry = true
 }
 
+// CountTriedChannels 返回当前请求已尝试过的不同渠道数
+func CountTriedChannels(c *gin.Context) int {
+	useChannel := c.GetStringSlice("use_channel")
+	seen := make(map[string]struct{}, len(useChannel))
+	for _, id := range useChannel {
+		seen[id] = struct{}{}
+	}
+	return len(seen)
+}
+
 // CacheGetRandomSatisfiedChannel tries to get a random channel that satisfies the requirements.
 // 尝试获取一个满足要求的随机渠道。
 //
@@ -85,6 +95,8 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 	var err error
 	selectGroup := param.TokenGroup
 	userGroup := common.GetContextKeyString(param.Ctx, constant.ContextKeyUserGroup)
+	regions := common.GetContextKeyStringSlice(param.Ctx, constant.ContextKeyDataResidencyRegions)
+	tenantId := common.GetContextKeyInt(param.Ctx, constant.ContextKeyTenantId)
 
 	if param.TokenGroup == "auto" {
 		if len(setting.GetAutoGroups()) == 0 {
@@ -115,7 +127,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry)
+			channel, _ = model.GetRandomSatisfiedChannelForTenant(autoGroup, param.ModelName, priorityRetry, regions, tenantId)
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -153,7 +165,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			break
 		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry())
+		channel, err = model.GetRandomSatisfiedChannelForTenant(param.TokenGroup, param.ModelName, param.GetRetry(), regions, tenantId)
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
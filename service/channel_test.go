@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkChannelModelNotFound_FailsOverToServingChannel(t *testing.T) {
+	setupServiceTestDB(t, &model.Channel{}, &model.Ability{})
+	origMemoryCache := common.MemoryCacheEnabled
+	t.Cleanup(func() { common.MemoryCacheEnabled = origMemoryCache })
+	common.MemoryCacheEnabled = true
+	failover := operation_setting.GetFailoverSetting()
+	origRetry := failover.ModelNotFoundRetryEnabled
+	t.Cleanup(func() { failover.ModelNotFoundRetryEnabled = origRetry })
+	failover.ModelNotFoundRetryEnabled = true
+
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"message":"The model gpt-4o-mini-test does not exist","type":"invalid_request_error","code":"model_not_found"}}`))
+	}))
+	defer missing.Close()
+	serving := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"ok"}`))
+	}))
+	defer serving.Close()
+
+	const modelName = "gpt-4o-mini-test"
+	channels := make([]*model.Channel, 0, 2)
+	for _, baseURL := range []string{missing.URL, serving.URL} {
+		channel := &model.Channel{Type: constant.ChannelTypeOpenAI, Key: "sk-test", Status: common.ChannelStatusEnabled, Group: "default", Models: modelName, BaseURL: common.GetPointer(baseURL)}
+		require.NoError(t, model.DB.Create(channel).Error)
+		require.NoError(t, model.DB.Create(&model.Ability{Group: "default", Model: modelName, ChannelId: channel.Id, Enabled: true}).Error)
+		channels = append(channels, channel)
+	}
+	model.InitChannelCache()
+
+	call := func(channel *model.Channel) *types.NewAPIError {
+		resp, err := http.Post(channel.GetBaseURL()+"/v1/chat/completions", "application/json", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		return RelayErrorHandler(context.Background(), resp, false)
+	}
+
+	// 首次选中的渠道上游不存在该模型，标记后重试只会选中可用渠道
+	apiErr := call(channels[0])
+	require.NotNil(t, apiErr)
+	require.True(t, MarkChannelModelNotFound(channels[0].Id, modelName, apiErr))
+	for i := 0; i < 20; i++ {
+		channel, err := model.GetRandomSatisfiedChannel("default", modelName, 1)
+		require.NoError(t, err)
+		require.Equal(t, channels[1].Id, channel.Id)
+		require.Nil(t, call(channel))
+	}
+}
+
+func TestIsUpstreamModelNotFoundError(t *testing.T) {
+	byCode := types.WithOpenAIError(types.OpenAIError{Message: "model unavailable", Type: "invalid_request_error", Code: "model_not_found"}, http.StatusBadRequest)
+	require.True(t, IsUpstreamModelNotFoundError(byCode, "claude-x"))
+
+	byName := types.WithOpenAIError(types.OpenAIError{Message: "Unknown model: claude-x"}, http.StatusNotFound)
+	require.True(t, IsUpstreamModelNotFoundError(byName, "claude-x"))
+
+	// 400 且没有错误码时不根据错误信息判断
+	badRequest := types.WithOpenAIError(types.OpenAIError{Message: "Unknown model: claude-x", Type: "invalid_request_error"}, http.StatusBadRequest)
+	require.False(t, IsUpstreamModelNotFoundError(badRequest, "claude-x"))
+
+	pathMissing := types.WithOpenAIError(types.OpenAIError{Message: "404 page not found"}, http.StatusNotFound)
+	require.False(t, IsUpstreamModelNotFoundError(pathMissing, "claude-x"))
+
+	badParam := types.WithOpenAIError(types.OpenAIError{Message: "max_tokens is too large for this model"}, http.StatusBadRequest)
+	require.False(t, IsUpstreamModelNotFoundError(badParam, "claude-x"))
+}
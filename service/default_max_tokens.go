@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+)
+
+// ApplyDefaultMaxTokens 客户端未指定最大输出 token 数时按配置补充默认值，返回补充的值，未补充时返回 false
+func ApplyDefaultMaxTokens(request dto.Request, modelName string, group string) (int, bool) {
+	value := model_setting.GetDefaultMaxTokens(modelName, group)
+	if value <= 0 {
+		return 0, false
+	}
+	limit := uint(value)
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if r.MaxTokens != 0 || r.MaxCompletionTokens != 0 {
+			return 0, false
+		}
+		r.MaxTokens = limit
+	case *dto.ClaudeRequest:
+		if r.MaxTokens != 0 {
+			return 0, false
+		}
+		r.MaxTokens = limit
+	case *dto.OpenAIResponsesRequest:
+		if r.MaxOutputTokens != 0 {
+			return 0, false
+		}
+		r.MaxOutputTokens = limit
+	case *dto.GeminiChatRequest:
+		if r.GenerationConfig.MaxOutputTokens != 0 {
+			return 0, false
+		}
+		r.GenerationConfig.MaxOutputTokens = limit
+	default:
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDefaultMaxTokens(t *testing.T) {
+	settings := model_setting.GetDefaultMaxTokensSettings()
+	orig := *settings
+	t.Cleanup(func() { *settings = orig })
+	settings.Default = 0
+	settings.Models = map[string]int{"gpt-4o": 2048, "o1": 0}
+	settings.Groups = map[string]int{"free": 512}
+
+	// 客户端未指定时补充模型默认值
+	request := &dto.GeneralOpenAIRequest{Model: "gpt-4o"}
+	value, ok := ApplyDefaultMaxTokens(request, "gpt-4o", "free")
+	require.True(t, ok)
+	require.Equal(t, 2048, value)
+	require.EqualValues(t, 2048, request.MaxTokens)
+
+	// 客户端已指定时保持不变
+	request = &dto.GeneralOpenAIRequest{Model: "gpt-4o", MaxCompletionTokens: 100}
+	_, ok = ApplyDefaultMaxTokens(request, "gpt-4o", "free")
+	require.False(t, ok)
+	require.Zero(t, request.MaxTokens)
+	require.EqualValues(t, 100, request.MaxCompletionTokens)
+
+	// 分组默认值与模型级的“不设置默认值”
+	claude := &dto.ClaudeRequest{Model: "claude-3-5-haiku"}
+	_, ok = ApplyDefaultMaxTokens(claude, "claude-3-5-haiku", "free")
+	require.True(t, ok)
+	require.EqualValues(t, 512, claude.MaxTokens)
+	request = &dto.GeneralOpenAIRequest{Model: "o1"}
+	_, ok = ApplyDefaultMaxTokens(request, "o1", "free")
+	require.False(t, ok)
+	require.Zero(t, request.MaxTokens)
+
+	// 未配置时保持原有行为
+	request = &dto.GeneralOpenAIRequest{Model: "gpt-4o-mini"}
+	_, ok = ApplyDefaultMaxTokens(request, "gpt-4o-mini", "default")
+	require.False(t, ok)
+	require.Zero(t, request.MaxTokens)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+var dQuotaMicrosPerQuota = decimal.NewFromInt(model.QuotaMicrosPerQuota)
+
+// IsExactBillingEnabled 是否开启精确计费
+func IsExactBillingEnabled() bool {
+	return operation_setting.GetQuotaSetting().ExactBilling
+}
+
+// SettleExactQuota 按精确额度结算，返回本次应扣除的整数额度
+// 不足 1 额度的部分以微额度累计到用户余数，累计满 1 额度时并入本次扣费，因此多次小额消耗的总和不会丢失
+func SettleExactQuota(userId int, exact decimal.Decimal) int {
+	if exact.LessThanOrEqual(decimal.Zero) {
+		return 0
+	}
+	micros := exact.Mul(dQuotaMicrosPerQuota).Round(0).IntPart()
+	whole := micros / model.QuotaMicrosPerQuota
+	carried, err := model.AccrueQuotaRemainder(userId, micros%model.QuotaMicrosPerQuota)
+	if err != nil {
+		// 余数记录失败时退回四舍五入，避免影响正常扣费
+		common.SysLog(fmt.Sprintf("failed to accrue quota remainder: userId=%d, error=%s", userId, err.Error()))
+		return int(exact.Round(0).IntPart())
+	}
+	return int(whole) + carried
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettleExactQuota_SmallChargesAreNotLost(t *testing.T) {
+	setupServiceTestDB(t, &model.QuotaRemainder{})
+
+	// 每次 0.37 额度，四舍五入后为 0
+	charge := decimal.RequireFromString("0.37")
+	require.Zero(t, int(charge.Round(0).IntPart()))
+
+	const requests = 10000
+	total := 0
+	for i := 0; i < requests; i++ {
+		total += SettleExactQuota(1, charge)
+	}
+	require.Equal(t, 3700, total)
+	remainder, err := model.GetQuotaRemainder(1)
+	require.NoError(t, err)
+	require.Zero(t, remainder)
+
+	// 不足 1 额度的部分保留在余数中，整数部分直接扣除
+	require.Equal(t, 2, SettleExactQuota(2, decimal.RequireFromString("2.3")))
+	require.Equal(t, 0, SettleExactQuota(2, decimal.RequireFromString("0.4")))
+	require.Equal(t, 1, SettleExactQuota(2, decimal.RequireFromString("0.3")))
+	remainder, err = model.GetQuotaRemainder(2)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), remainder)
+
+	require.Equal(t, 0, SettleExactQuota(3, decimal.RequireFromString("0.000123")))
+	remainder, err = model.GetQuotaRemainder(3)
+	require.NoError(t, err)
+	require.Equal(t, int64(123), remainder)
+}
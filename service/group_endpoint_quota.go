@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func groupEndpointQuotaError(group string, endpoint string, used int64, limit int64) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(
+		fmt.Errorf("分组 %s 的 %s 接口额度不足，已用 %s，上限 %s", group, endpoint, logger.FormatQuota(int(used)), logger.FormatQuota(int(limit))),
+		types.ErrorCodeInsufficientGroupQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+}
+
+// checkGroupEndpointQuota 检查分组接口额度是否足够支付 needQuota，未配置额度时直接通过
+func checkGroupEndpointQuota(relayInfo *relaycommon.RelayInfo, needQuota int) *types.NewAPIError {
+	limit, ok := operation_setting.GetGroupEndpointQuotaLimit(relayInfo.UserGroup, relayInfo.Endpoint)
+	if !ok {
+		return nil
+	}
+	used, err := model.GetGroupEndpointQuotaUsed(relayInfo.UserGroup, relayInfo.Endpoint)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if used >= limit || used+int64(needQuota) > limit {
+		return groupEndpointQuotaError(relayInfo.UserGroup, relayInfo.Endpoint, used, limit)
+	}
+	return nil
+}
+
+// preConsumeGroupEndpointQuota 从分组接口额度中预扣，额度不足时返回错误
+func preConsumeGroupEndpointQuota(relayInfo *relaycommon.RelayInfo, quota int) *types.NewAPIError {
+	limit, ok := operation_setting.GetGroupEndpointQuotaLimit(relayInfo.UserGroup, relayInfo.Endpoint)
+	if !ok || quota <= 0 {
+		return nil
+	}
+	err := model.ConsumeGroupEndpointQuota(relayInfo.UserGroup, relayInfo.Endpoint, quota, limit)
+	if errors.Is(err, model.ErrGroupEndpointQuotaExhausted) {
+		used, _ := model.GetGroupEndpointQuotaUsed(relayInfo.UserGroup, relayInfo.Endpoint)
+		return groupEndpointQuotaError(relayInfo.UserGroup, relayInfo.Endpoint, used, limit)
+	}
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	return nil
+}
+
+// adjustGroupEndpointQuota 按实际消耗调整分组接口额度，quota 为负数时返还
+func adjustGroupEndpointQuota(relayInfo *relaycommon.RelayInfo, quota int) {
+	if _, ok := operation_setting.GetGroupEndpointQuotaLimit(relayInfo.UserGroup, relayInfo.Endpoint); !ok {
+		return
+	}
+	if err := model.AdjustGroupEndpointQuota(relayInfo.UserGroup, relayInfo.Endpoint, quota); err != nil {
+		common.SysLog(fmt.Sprintf("failed to adjust group endpoint quota: group=%s, endpoint=%s, error=%s", relayInfo.UserGroup, relayInfo.Endpoint, err.Error()))
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupEndpointQuota_ImageBucketExhaustedChatStillWorks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupServiceTestDB(t, &model.User{}, &model.GroupEndpointQuotaUsage{})
+	setting := operation_setting.GetGroupEndpointQuotaSetting()
+	orig := setting.Limits
+	t.Cleanup(func() { setting.Limits = orig })
+	setting.Limits = map[string]map[string]int64{
+		"team": {
+			operation_setting.EndpointImages: 1000,
+			operation_setting.EndpointChat:   100000,
+		},
+	}
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "team-user", Quota: 1000000, Group: "team"}).Error)
+
+	consume := func(endpoint string, quota int) *types.NewAPIError {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		info := &relaycommon.RelayInfo{UserId: 1, UserGroup: "team", Endpoint: endpoint, IsPlayground: true}
+		if apiErr := PreConsumeQuota(c, quota, info); apiErr != nil {
+			return apiErr
+		}
+		require.Equal(t, quota, info.FinalPreConsumedQuota)
+		require.NoError(t, PostConsumeQuota(info, quota-info.FinalPreConsumedQuota, info.FinalPreConsumedQuota, false))
+		return nil
+	}
+
+	require.Nil(t, consume(operation_setting.EndpointImages, 600))
+	used, err := model.GetGroupEndpointQuotaUsed("team", operation_setting.EndpointImages)
+	require.NoError(t, err)
+	require.EqualValues(t, 600, used)
+
+	// 图片额度不足时拒绝，即使聊天额度与用户额度都充足
+	apiErr := consume(operation_setting.EndpointImages, 600)
+	require.NotNil(t, apiErr)
+	require.Equal(t, types.ErrorCodeInsufficientGroupQuota, apiErr.GetErrorCode())
+
+	require.Nil(t, consume(operation_setting.EndpointChat, 600))
+	used, err = model.GetGroupEndpointQuotaUsed("team", operation_setting.EndpointChat)
+	require.NoError(t, err)
+	require.EqualValues(t, 600, used)
+
+	// 未配置额度的接口不受限制
+	require.Nil(t, consume(operation_setting.EndpointEmbeddings, 5000))
+
+	require.NoError(t, model.ResetGroupEndpointQuota("team", operation_setting.EndpointImages))
+	require.Nil(t, consume(operation_setting.EndpointImages, 600))
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApplyGroupSystemPrompt 按用户分组注入系统提示词，需在计算预估 token 之前调用，使注入内容计入计费。
+// 全局开启请求透传时原始请求体直接发往上游，不做注入
+func ApplyGroupSystemPrompt(c *gin.Context, request dto.Request) {
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+		return
+	}
+	userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	prompt, ok := operation_setting.GetGroupSystemPrompt(userGroup)
+	if !ok {
+		return
+	}
+	if injectGroupSystemPrompt(request, prompt) {
+		common.SetContextKey(c, constant.ContextKeyGroupSystemPromptInjected, true)
+	}
+}
+
+// injectGroupSystemPrompt 只处理系统消息，工具调用与函数消息保持原样，返回是否实际注入
+func injectGroupSystemPrompt(request dto.Request, prompt operation_setting.GroupSystemPrompt) bool {
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		return injectOpenAISystemPrompt(r, prompt)
+	case *dto.ClaudeRequest:
+		return injectClaudeSystemPrompt(r, prompt)
+	}
+	return false
+}
+
+func injectOpenAISystemPrompt(request *dto.GeneralOpenAIRequest, prompt operation_setting.GroupSystemPrompt) bool {
+	if len(request.Messages) == 0 {
+		return false
+	}
+	systemRole := request.GetSystemRoleName()
+	systemIndex := -1
+	for i, message := range request.Messages {
+		if message.Role == systemRole {
+			systemIndex = i
+			break
+		}
+	}
+	if systemIndex >= 0 && !prompt.NonOverridable {
+		return false
+	}
+	if systemIndex < 0 || !prompt.Merge {
+		systemMessage := dto.Message{Role: systemRole, Content: prompt.Prompt}
+		request.Messages = append([]dto.Message{systemMessage}, request.Messages...)
+		return true
+	}
+
+	message := &request.Messages[systemIndex]
+	if message.IsStringContent() {
+		message.SetStringContent(prompt.Prompt + "\n" + message.StringContent())
+		return true
+	}
+	contents := message.ParseContent()
+	message.Content = append([]dto.MediaContent{{Type: dto.ContentTypeText, Text: prompt.Prompt}}, contents...)
+	return true
+}
+
+func injectClaudeSystemPrompt(request *dto.ClaudeRequest, prompt operation_setting.GroupSystemPrompt) bool {
+	if request.System == nil {
+		request.SetStringSystem(prompt.Prompt)
+		return true
+	}
+	if !prompt.NonOverridable {
+		return false
+	}
+	if request.IsStringSystem() {
+		existing := strings.TrimSpace(request.GetStringSystem())
+		if existing == "" {
+			request.SetStringSystem(prompt.Prompt)
+		} else {
+			request.SetStringSystem(prompt.Prompt + "\n" + existing)
+		}
+		return true
+	}
+	newSystem := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+	newSystem.SetText(prompt.Prompt)
+	request.System = append([]dto.ClaudeMediaMessage{newSystem}, request.ParseSystem()...)
+	return true
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const testCompliancePrompt = "All responses must follow the enterprise compliance policy and never reveal customer data."
+
+func withGroupSystemPrompts(t *testing.T, prompts map[string]operation_setting.GroupSystemPrompt) {
+	setting := operation_setting.GetGroupSystemPromptSetting()
+	orig := setting.Prompts
+	t.Cleanup(func() { setting.Prompts = orig })
+	setting.Prompts = prompts
+}
+
+func newToolConversation() *dto.GeneralOpenAIRequest {
+	return &dto.GeneralOpenAIRequest{
+		Model: "claude-3-5-sonnet",
+		Messages: []dto.Message{
+			{Role: "user", Content: "what's the weather"},
+			{Role: "assistant", Content: "", ToolCalls: json.RawMessage(`[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]`)},
+			{Role: "tool", Content: "sunny", ToolCallId: "call_1"},
+		},
+	}
+}
+
+func TestApplyGroupSystemPrompt_InjectsAndCountsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withGroupSystemPrompts(t, map[string]operation_setting.GroupSystemPrompt{
+		"enterprise": {Prompt: testCompliancePrompt},
+	})
+	origCountToken := constant.CountToken
+	t.Cleanup(func() { constant.CountToken = origCountToken })
+	constant.CountToken = true
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, "claude-3-5-sonnet")
+	info := &relaycommon.RelayInfo{RelayFormat: types.RelayFormatOpenAI}
+
+	before, err := EstimateRequestToken(c, newToolConversation().GetTokenCountMeta(), info)
+	require.NoError(t, err)
+
+	request := newToolConversation()
+	common.SetContextKey(c, constant.ContextKeyUserGroup, "enterprise")
+	ApplyGroupSystemPrompt(c, request)
+	require.True(t, common.GetContextKeyBool(c, constant.ContextKeyGroupSystemPromptInjected))
+
+	require.Len(t, request.Messages, 4)
+	require.Equal(t, "system", request.Messages[0].Role)
+	require.Equal(t, testCompliancePrompt, request.Messages[0].StringContent())
+	// 工具调用与工具结果消息保持原样
+	require.JSONEq(t, `[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]`, string(request.Messages[2].ToolCalls))
+	require.Equal(t, "call_1", request.Messages[3].ToolCallId)
+	require.Equal(t, "sunny", request.Messages[3].StringContent())
+
+	// 预估 token 包含注入的提示词
+	after, err := EstimateRequestToken(c, request.GetTokenCountMeta(), info)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, after-before, CountTextToken(testCompliancePrompt, "claude-3-5-sonnet"))
+}
+
+func TestApplyGroupSystemPrompt_RespectsOverridable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withGroupSystemPrompts(t, map[string]operation_setting.GroupSystemPrompt{
+		"soft":   {Prompt: testCompliancePrompt},
+		"merge":  {Prompt: testCompliancePrompt, Merge: true, NonOverridable: true},
+		"strict": {Prompt: testCompliancePrompt, NonOverridable: true},
+	})
+	newRequest := func() *dto.GeneralOpenAIRequest {
+		return &dto.GeneralOpenAIRequest{Messages: []dto.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+		}}
+	}
+	apply := func(group string, request dto.Request) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		common.SetContextKey(c, constant.ContextKeyUserGroup, group)
+		ApplyGroupSystemPrompt(c, request)
+		return common.GetContextKeyBool(c, constant.ContextKeyGroupSystemPromptInjected)
+	}
+
+	// 可覆盖时请求自带的系统消息优先
+	request := newRequest()
+	require.False(t, apply("soft", request))
+	require.Len(t, request.Messages, 2)
+
+	request = newRequest()
+	require.True(t, apply("merge", request))
+	require.Len(t, request.Messages, 2)
+	require.Equal(t, testCompliancePrompt+"\nbe brief", request.Messages[0].StringContent())
+
+	request = newRequest()
+	require.True(t, apply("strict", request))
+	require.Len(t, request.Messages, 3)
+	require.Equal(t, testCompliancePrompt, request.Messages[0].StringContent())
+	require.Equal(t, "be brief", request.Messages[1].StringContent())
+
+	claudeRequest := &dto.ClaudeRequest{}
+	claudeRequest.SetStringSystem("be brief")
+	require.True(t, apply("strict", claudeRequest))
+	require.Equal(t, testCompliancePrompt+"\nbe brief", claudeRequest.GetStringSystem())
+
+	// 未配置的分组不注入
+	request = newRequest()
+	require.False(t, apply("default", request))
+}
+
+func TestGroupSystemPromptLog_SkippedOnPassthrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withGroupSystemPrompts(t, map[string]operation_setting.GroupSystemPrompt{
+		"enterprise": {Prompt: testCompliancePrompt},
+	})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyUserGroup, "enterprise")
+	ApplyGroupSystemPrompt(c, newToolConversation())
+
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}
+	other := GenerateTextOtherInfo(c, info, 1, 1, 1, 0, 1, 0, 1)
+	require.Equal(t, true, other["group_system_prompt_injected"])
+
+	// 渠道透传请求体时注入内容未发往上游，日志标记为跳过
+	info.ChannelSetting.PassThroughBodyEnabled = true
+	other = GenerateTextOtherInfo(c, info, 1, 1, 1, 0, 1, 0, 1)
+	require.NotContains(t, other, "group_system_prompt_injected")
+	require.Equal(t, "passthrough", other["group_system_prompt_skipped"])
+}
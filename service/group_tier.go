@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	groupTierTickInterval = 10 * time.Minute
+	groupTierBatchSize    = 500
+)
+
+var groupTierOnce sync.Once
+
+// EvaluateUserGroupTier 按额度等级调整用户分组，分组已匹配时不做任何修改，返回是否发生了调整
+func EvaluateUserGroupTier(user *model.User) (bool, error) {
+	setting := operation_setting.GetGroupTierSetting()
+	if !setting.Enabled || user == nil {
+		return false, nil
+	}
+	currentRank := operation_setting.GroupTierRank(user.Group)
+	if currentRank < 0 {
+		return false, nil
+	}
+	value := int64(user.UsedQuota)
+	if setting.Metric == operation_setting.GroupTierMetricQuota {
+		value = int64(user.Quota)
+	}
+	target, targetRank, ok := operation_setting.MatchGroupTier(value)
+	if !ok || target == user.Group {
+		return false, nil
+	}
+	if targetRank < currentRank && !setting.AllowDowngrade {
+		return false, nil
+	}
+	switched, err := model.SwitchUserGroup(user.Id, user.Group, target)
+	if err != nil || !switched {
+		return false, err
+	}
+	action := "升级"
+	if targetRank < currentRank {
+		action = "降级"
+	}
+	model.RecordLog(user.Id, model.LogTypeSystem, fmt.Sprintf("额度等级自动%s：分组由 %s 调整为 %s（%s %s）", action, user.Group, target, setting.Metric, logger.LogQuota(int(value))))
+	user.Group = target
+	return true, nil
+}
+
+// EvaluateUserGroupTierById 在充值等额度变化后重新评估用户的额度等级
+func EvaluateUserGroupTierById(userId int) {
+	if !operation_setting.GetGroupTierSetting().Enabled {
+		return
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return
+	}
+	if _, err := EvaluateUserGroupTier(user); err != nil {
+		common.SysError(fmt.Sprintf("group tier: evaluate user %d failed: %v", userId, err))
+	}
+}
+
+// StartGroupTierTask 定时评估所有处于等级分组内的用户
+func StartGroupTierTask() {
+	groupTierOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(groupTierTickInterval)
+			defer ticker.Stop()
+
+			runGroupTierOnce()
+			for range ticker.C {
+				runGroupTierOnce()
+			}
+		})
+	})
+}
+
+// runGroupTierOnce 分批评估等级分组内的用户，返回调整的用户数
+func runGroupTierOnce() int {
+	setting := operation_setting.GetGroupTierSetting()
+	if !setting.Enabled || len(setting.Tiers) == 0 {
+		return 0
+	}
+	groups := make([]string, 0, len(setting.Tiers))
+	for _, tier := range setting.Tiers {
+		groups = append(groups, tier.Group)
+	}
+	switched := 0
+	afterId := 0
+	for {
+		users, err := model.GetUsersInGroups(groups, afterId, groupTierBatchSize)
+		if err != nil {
+			logger.LogError(context.Background(), fmt.Sprintf("group tier: query users failed: %v", err))
+			return switched
+		}
+		for _, user := range users {
+			changed, err := EvaluateUserGroupTier(user)
+			if err != nil {
+				logger.LogError(context.Background(), fmt.Sprintf("group tier: evaluate user %d failed: %v", user.Id, err))
+				continue
+			}
+			if changed {
+				switched++
+			}
+		}
+		if len(users) < groupTierBatchSize {
+			return switched
+		}
+		afterId = users[len(users)-1].Id
+	}
+}
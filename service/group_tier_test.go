@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withGroupTiers(t *testing.T, setting operation_setting.GroupTierSetting) {
+	current := operation_setting.GetGroupTierSetting()
+	orig := *current
+	t.Cleanup(func() { *current = orig })
+	*current = setting
+}
+
+func TestGroupTier_MovesUserOnceWhenCrossingTier(t *testing.T) {
+	setupServiceTestDB(t, &model.User{}, &model.Log{})
+	withGroupTiers(t, operation_setting.GroupTierSetting{
+		Enabled: true,
+		Metric:  operation_setting.GroupTierMetricUsedQuota,
+		Tiers: []operation_setting.GroupTier{
+			{Group: "vip", Threshold: 1000},
+			{Group: "default", Threshold: 0},
+		},
+	})
+	spender := model.User{Username: "spender", Password: "12345678", Group: "default", UsedQuota: 1500, AffCode: "t1"}
+	newbie := model.User{Username: "newbie", Password: "12345678", Group: "default", UsedQuota: 10, AffCode: "t2"}
+	manual := model.User{Username: "manual", Password: "12345678", Group: "partner", UsedQuota: 5000, AffCode: "t3"}
+	for _, user := range []*model.User{&spender, &newbie, &manual} {
+		require.NoError(t, model.DB.Create(user).Error)
+	}
+
+	require.Equal(t, 1, runGroupTierOnce())
+	// 再次评估时分组已匹配，不会重复调整
+	require.Equal(t, 0, runGroupTierOnce())
+
+	groupOf := func(id int) string {
+		user, err := model.GetUserById(id, false)
+		require.NoError(t, err)
+		return user.Group
+	}
+	require.Equal(t, "vip", groupOf(spender.Id))
+	require.Equal(t, "default", groupOf(newbie.Id))
+	require.Equal(t, "partner", groupOf(manual.Id))
+
+	var audits int64
+	require.NoError(t, model.LOG_DB.Model(&model.Log{}).Where("user_id = ? AND type = ?", spender.Id, model.LogTypeSystem).Count(&audits).Error)
+	require.EqualValues(t, 1, audits)
+}
+
+func TestGroupTier_DowngradeRequiresOptIn(t *testing.T) {
+	setupServiceTestDB(t, &model.User{}, &model.Log{})
+	setting := operation_setting.GroupTierSetting{
+		Enabled: true,
+		Metric:  operation_setting.GroupTierMetricQuota,
+		Tiers: []operation_setting.GroupTier{
+			{Group: "default", Threshold: 0},
+			{Group: "vip", Threshold: 1000},
+		},
+	}
+	withGroupTiers(t, setting)
+	user := &model.User{Username: "drained", Password: "12345678", Group: "vip", Quota: 100, AffCode: "t4"}
+	require.NoError(t, model.DB.Create(user).Error)
+
+	changed, err := EvaluateUserGroupTier(user)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	operation_setting.GetGroupTierSetting().AllowDowngrade = true
+	changed, err = EvaluateUserGroupTier(user)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "default", user.Group)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -50,10 +51,30 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
 
+	if region := common.GetContextKeyString(ctx, constant.ContextKeyChannelRegion); region != "" {
+		other["channel_region"] = region
+	}
+	if virtualModel := common.GetContextKeyString(ctx, constant.ContextKeyVirtualModel); virtualModel != "" {
+		other["virtual_model"] = virtualModel
+	}
+	if downgradedFrom := common.GetContextKeyString(ctx, constant.ContextKeyBudgetDowngradedFrom); downgradedFrom != "" {
+		other["budget_downgraded_from"] = downgradedFrom
+	}
+
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
 	}
+	if common.GetContextKeyBool(ctx, constant.ContextKeyGroupSystemPromptInjected) {
+		// 渠道开启请求体透传时注入的提示词不会发往上游
+		if relayInfo.ChannelSetting.PassThroughBodyEnabled || model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+			other["group_system_prompt_skipped"] = "passthrough"
+		} else {
+			other["group_system_prompt_injected"] = true
+		}
+	}
+
+	other["channels_tried"] = CountTriedChannels(ctx)
 
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
@@ -104,6 +125,19 @@ func appendRequestConversionChain(relayInfo *relaycommon.RelayInfo, other map[st
 	other["request_conversion"] = chain
 }
 
+// AppendUsageDetailsInfo 在日志中记录上游返回的 prompt_tokens_details 与 completion_tokens_details
+func AppendUsageDetailsInfo(other map[string]interface{}, usage *dto.Usage) {
+	if other == nil || usage == nil {
+		return
+	}
+	if usage.PromptTokensDetails != (dto.InputTokenDetails{}) {
+		other["prompt_tokens_details"] = usage.PromptTokensDetails
+	}
+	if usage.CompletionTokenDetails != (dto.OutputTokenDetails{}) {
+		other["completion_tokens_details"] = usage.CompletionTokenDetails
+	}
+}
+
 func GenerateWssOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage, modelRatio, groupRatio, completionRatio, audioRatio, audioCompletionRatio, modelPrice, userGroupRatio float64) map[string]interface{} {
 	info := GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, 0, 0.0, modelPrice, userGroupRatio)
 	info["ws"] = true
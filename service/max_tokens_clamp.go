@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// AffordableOutputTokens 按剩余额度与模型输出价格计算最多可负担的输出 token 数，返回 false 表示不限制
+func AffordableOutputTokens(remainingQuota int, promptTokens int, priceData types.PriceData) (int, bool) {
+	if priceData.FreeModel || priceData.UsePrice {
+		return 0, false
+	}
+	ratio := priceData.ModelRatio * priceData.GroupRatioInfo.GroupRatio
+	completionRatio := priceData.CompletionRatio
+	if completionRatio <= 0 {
+		completionRatio = 1
+	}
+	if ratio <= 0 {
+		return 0, false
+	}
+	affordable := (float64(remainingQuota) - float64(promptTokens)*ratio) / (ratio * completionRatio)
+	if affordable < 1 {
+		// 连输入都无法负担时交由预扣费返回额度不足
+		return 0, false
+	}
+	return int(affordable), true
+}
+
+// remainingQuotaForClamp 返回用于限制输出的剩余额度，无限额度令牌返回 false
+func remainingQuotaForClamp(relayInfo *relaycommon.RelayInfo, userQuota int, tokenQuota int) (int, bool) {
+	if relayInfo.TokenUnlimited {
+		return 0, false
+	}
+	return min(userQuota, tokenQuota), true
+}
+
+// ClampRequestMaxTokens 将请求的最大输出 token 数下调到可负担的数量，返回下调前后的值，未下调时返回 false。
+// 请求未指定最大输出 token 数（原值为 0）时上游可能按模型上限输出，此时直接设为可负担的数量
+func ClampRequestMaxTokens(request dto.Request, relayInfo *relaycommon.RelayInfo, userQuota int, tokenQuota int, priceData types.PriceData) (int, int, bool) {
+	remaining, ok := remainingQuotaForClamp(relayInfo, userQuota, tokenQuota)
+	if !ok {
+		return 0, 0, false
+	}
+	affordable, ok := AffordableOutputTokens(remaining, relayInfo.GetEstimatePromptTokens(), priceData)
+	if !ok {
+		return 0, 0, false
+	}
+	limit := uint(affordable)
+	var original uint
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		original = max(r.MaxTokens, r.MaxCompletionTokens)
+		if original != 0 && original <= limit {
+			return 0, 0, false
+		}
+		if original == 0 {
+			r.MaxTokens = limit
+		}
+		if r.MaxCompletionTokens > limit {
+			r.MaxCompletionTokens = limit
+		}
+		if r.MaxTokens > limit {
+			r.MaxTokens = limit
+		}
+	case *dto.ClaudeRequest:
+		original = r.MaxTokens
+		if original != 0 && original <= limit {
+			return 0, 0, false
+		}
+		r.MaxTokens = limit
+	case *dto.OpenAIResponsesRequest:
+		original = r.MaxOutputTokens
+		if original != 0 && original <= limit {
+			return 0, 0, false
+		}
+		r.MaxOutputTokens = limit
+	case *dto.GeminiChatRequest:
+		original = r.GenerationConfig.MaxOutputTokens
+		if original != 0 && original <= limit {
+			return 0, 0, false
+		}
+		r.GenerationConfig.MaxOutputTokens = limit
+	default:
+		return 0, 0, false
+	}
+	return int(original), affordable, true
+}
+
+// MaxTokensClampWarning 生成输出 token 数被下调时的 Warning 响应头
+func MaxTokensClampWarning(original int, clamped int) string {
+	if original == 0 {
+		return fmt.Sprintf("299 - %q", fmt.Sprintf("max_tokens set to %d by remaining quota", clamped))
+	}
+	return fmt.Sprintf("299 - %q", fmt.Sprintf("max_tokens clamped from %d to %d by remaining quota", original, clamped))
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClampRequestMaxTokens(t *testing.T) {
+	priceData := types.PriceData{
+		ModelRatio:      2,
+		CompletionRatio: 4,
+		GroupRatioInfo:  types.GroupRatioInfo{GroupRatio: 1},
+	}
+	info := &relaycommon.RelayInfo{}
+	info.SetEstimatePromptTokens(100)
+
+	// 剩余 1000 额度：输入消耗 100*2=200，每个输出 token 消耗 2*4=8，最多 100 个输出 token
+	request := &dto.GeneralOpenAIRequest{MaxTokens: 4096}
+	original, clamped, ok := ClampRequestMaxTokens(request, info, 5000, 1000, priceData)
+	require.True(t, ok)
+	require.Equal(t, 4096, original)
+	require.Equal(t, 100, clamped)
+	require.EqualValues(t, 100, request.MaxTokens)
+
+	// 可负担时不下调
+	request = &dto.GeneralOpenAIRequest{MaxTokens: 50}
+	_, _, ok = ClampRequestMaxTokens(request, info, 5000, 1000, priceData)
+	require.False(t, ok)
+	require.EqualValues(t, 50, request.MaxTokens)
+
+	// 未指定最大输出 token 数时设为可负担的数量
+	request = &dto.GeneralOpenAIRequest{}
+	original, clamped, ok = ClampRequestMaxTokens(request, info, 5000, 1000, priceData)
+	require.True(t, ok)
+	require.Zero(t, original)
+	require.Equal(t, 100, clamped)
+	require.EqualValues(t, 100, request.MaxTokens)
+	require.Zero(t, request.MaxCompletionTokens)
+
+	claudeRequest := &dto.ClaudeRequest{}
+	_, _, ok = ClampRequestMaxTokens(claudeRequest, info, 5000, 1000, priceData)
+	require.True(t, ok)
+	require.EqualValues(t, 100, claudeRequest.MaxTokens)
+
+	// 无限额度令牌不下调
+	info.TokenUnlimited = true
+	request = &dto.GeneralOpenAIRequest{MaxTokens: 4096}
+	_, _, ok = ClampRequestMaxTokens(request, info, 5000, 1000, priceData)
+	require.False(t, ok)
+	require.EqualValues(t, 4096, request.MaxTokens)
+}
@@ -42,6 +42,10 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		return types.NewErrorWithStatusCode(fmt.Errorf("预扣费额度失败, 用户剩余额度: %s, 需要预扣费额度: %s", logger.FormatQuota(userQuota), logger.FormatQuota(preConsumedQuota)), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 	}
 
+	if apiErr := checkGroupEndpointQuota(relayInfo, preConsumedQuota); apiErr != nil {
+		return apiErr
+	}
+
 	trustQuota := common.GetTrustQuota()
 
 	relayInfo.UserQuota = userQuota
@@ -64,12 +68,17 @@ func PreConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 	}
 
 	if preConsumedQuota > 0 {
+		if apiErr := preConsumeGroupEndpointQuota(relayInfo, preConsumedQuota); apiErr != nil {
+			return apiErr
+		}
 		err := PreConsumeTokenQuota(relayInfo, preConsumedQuota)
 		if err != nil {
+			adjustGroupEndpointQuota(relayInfo, -preConsumedQuota)
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
 		if err != nil {
+			adjustGroupEndpointQuota(relayInfo, -preConsumedQuota)
 			return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 		}
 		logger.LogInfo(c, fmt.Sprintf("用户 %d 预扣费 %s, 预扣费后剩余额度: %s", relayInfo.UserId, logger.FormatQuota(preConsumedQuota), logger.FormatQuota(userQuota-preConsumedQuota)))
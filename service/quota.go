@@ -47,14 +47,14 @@ func hasCustomModelRatio(modelName string, currentRatio float64) bool {
 	return currentRatio != defaultRatio
 }
 
-func calculateAudioQuota(info QuotaInfo) int {
+// calculateAudioQuotaDecimal 计算音频请求的精确额度，不做取整
+func calculateAudioQuotaDecimal(info QuotaInfo) decimal.Decimal {
 	if info.UsePrice {
 		modelPrice := decimal.NewFromFloat(info.ModelPrice)
 		quotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		groupRatio := decimal.NewFromFloat(info.GroupRatio)
 
-		quota := modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
-		return int(quota.IntPart())
+		return modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
 	}
 
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
@@ -76,9 +76,17 @@ func calculateAudioQuota(info QuotaInfo) int {
 	quota = quota.Add(inputAudioTokens.Mul(audioRatio))
 	quota = quota.Add(outputAudioTokens.Mul(audioRatio).Mul(audioCompletionRatio))
 
-	quota = quota.Mul(ratio)
+	return quota.Mul(ratio)
+}
+
+func calculateAudioQuota(info QuotaInfo) int {
+	quota := calculateAudioQuotaDecimal(info)
+	if info.UsePrice {
+		return int(quota.IntPart())
+	}
 
 	// If ratio is not zero and quota is less than or equal to zero, set quota to 1
+	ratio := decimal.NewFromFloat(info.GroupRatio).Mul(decimal.NewFromFloat(info.ModelRatio))
 	if !ratio.IsZero() && quota.LessThanOrEqual(decimal.Zero) {
 		quota = decimal.NewFromInt(1)
 	}
@@ -136,7 +144,12 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		GroupRatio: actualGroupRatio,
 	}
 
-	quota := calculateAudioQuota(quotaInfo)
+	var quota int
+	if IsExactBillingEnabled() && usage.TotalTokens != 0 {
+		quota = SettleExactQuota(relayInfo.UserId, calculateAudioQuotaDecimal(quotaInfo))
+	} else {
+		quota = calculateAudioQuota(quotaInfo)
+	}
 
 	if userQuota < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
@@ -189,7 +202,15 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		GroupRatio: groupRatio,
 	}
 
-	quota := calculateAudioQuota(quotaInfo)
+	// 实时会话的扣费已在 PreWssConsumeQuota 中逐段完成，这里只用于统计和日志，精确计费时不再累计余数
+	exactBilling := IsExactBillingEnabled()
+	exactQuota := calculateAudioQuotaDecimal(quotaInfo)
+	var quota int
+	if exactBilling {
+		quota = int(exactQuota.Round(0).IntPart())
+	} else {
+		quota = calculateAudioQuota(quotaInfo)
+	}
 
 	totalTokens := usage.TotalTokens
 	var logContent string
@@ -219,6 +240,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	if exactBilling {
+		other["exact_quota"] = exactQuota.String()
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -285,13 +309,18 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		calculateQuota = modelPrice * common.QuotaPerUnit * groupRatio
 	}
 
-	if modelRatio != 0 && calculateQuota <= 0 {
+	exactBilling := IsExactBillingEnabled()
+	if !exactBilling && modelRatio != 0 && calculateQuota <= 0 {
 		calculateQuota = 1
 	}
 
-	quota := int(calculateQuota)
-
 	totalTokens := promptTokens + completionTokens
+	var quota int
+	if exactBilling && totalTokens != 0 {
+		quota = SettleExactQuota(relayInfo.UserId, decimal.NewFromFloat(calculateQuota))
+	} else {
+		quota = int(calculateQuota)
+	}
 
 	var logContent string
 	// record all the consume log even if quota is 0
@@ -336,6 +365,9 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		cacheCreationTokens5m, cacheCreationRatio5m,
 		cacheCreationTokens1h, cacheCreationRatio1h,
 		modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+	if exactBilling {
+		other["exact_quota"] = decimal.NewFromFloat(calculateQuota).String()
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -408,9 +440,15 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		GroupRatio: groupRatio,
 	}
 
-	quota := calculateAudioQuota(quotaInfo)
-
 	totalTokens := usage.TotalTokens
+	exactBilling := IsExactBillingEnabled()
+	var quota int
+	if exactBilling && totalTokens != 0 {
+		quota = SettleExactQuota(relayInfo.UserId, calculateAudioQuotaDecimal(quotaInfo))
+	} else {
+		quota = calculateAudioQuota(quotaInfo)
+	}
+
 	var logContent string
 	if !usePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f，分组倍率 %.2f",
@@ -523,6 +561,12 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
+	adjustGroupEndpointQuota(relayInfo, quota)
+
+	// quota 为相对预扣费的差额，用量统计需使用本次请求的实际消耗
+	RecordTokenUsageForAnomaly(relayInfo, quota+preConsumedQuota)
+	RecordDailySpend(relayInfo, quota+preConsumedQuota)
+
 	if sendEmail {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
@@ -535,6 +579,10 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
+		if len(userSetting.QuotaWarningPercents) > 0 {
+			checkQuotaAlertPercents(relayInfo, quota+preConsumedQuota)
+			return
+		}
 		threshold := common.QuotaRemindThreshold
 		if userSetting.QuotaWarningThreshold != 0 {
 			threshold = int(userSetting.QuotaWarningThreshold)
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// evaluateQuotaAlerts 根据当前余额计算多级预警的新状态与本次下穿的阈值。
+// base 为百分比基准（观测到的最高余额，余额上升超过基准时随之提高），
+// level 为已触发的最低阈值，余额回升超过某个已触发阈值时该阈值重新生效。
+func evaluateQuotaAlerts(percents []float64, base int, level float64, balance int) (int, float64, []float64) {
+	if balance > base {
+		base = balance
+	}
+	if base <= 0 {
+		return base, level, nil
+	}
+	ratio := float64(balance) * 100 / float64(base)
+
+	thresholds := append([]float64(nil), percents...)
+	sort.Float64s(thresholds)
+
+	// 回升：已触发且低于当前比例的阈值重新生效，level 变为仍处于触发状态的最低阈值
+	if level > 0 && ratio > level {
+		level = 0
+		for _, t := range thresholds {
+			if t >= ratio {
+				level = t
+				break
+			}
+		}
+	}
+
+	var crossed []float64
+	for i := len(thresholds) - 1; i >= 0; i-- {
+		t := thresholds[i]
+		if t <= 0 || (level > 0 && t >= level) {
+			continue
+		}
+		if ratio <= t {
+			crossed = append(crossed, t)
+		}
+	}
+	if len(crossed) > 0 {
+		level = crossed[len(crossed)-1]
+	}
+	return base, level, crossed
+}
+
+// checkQuotaAlertPercents 按用户配置的多级百分比检查用户与令牌余额，每个阈值每次下穿只通知一次
+func checkQuotaAlertPercents(relayInfo *relaycommon.RelayInfo, consumeQuota int) {
+	percents := relayInfo.UserSetting.QuotaWarningPercents
+	notifyQuotaAlert(relayInfo, model.QuotaAlertOwnerUser, relayInfo.UserId, relayInfo.UserQuota-consumeQuota, percents, "")
+	if relayInfo.TokenUnlimited || relayInfo.IsPlayground || relayInfo.TokenKey == "" {
+		return
+	}
+	token, err := model.GetTokenByKey(relayInfo.TokenKey, false)
+	if err != nil {
+		return
+	}
+	notifyQuotaAlert(relayInfo, model.QuotaAlertOwnerToken, token.Id, token.RemainQuota, percents, token.Name)
+}
+
+func notifyQuotaAlert(relayInfo *relaycommon.RelayInfo, ownerType string, ownerId int, balance int, percents []float64, tokenName string) {
+	state, err := model.GetQuotaAlertState(ownerType, ownerId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get quota alert state of %s %d: %s", ownerType, ownerId, err.Error()))
+		return
+	}
+	prevBase, prevLevel := state.Base, state.Level
+	base, level, crossed := evaluateQuotaAlerts(percents, state.Base, state.Level, balance)
+	if base == prevBase && level == prevLevel {
+		return
+	}
+	state.Base, state.Level = base, level
+	saved, err := model.SaveQuotaAlertState(state, prevBase, prevLevel)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to save quota alert state of %s %d: %s", ownerType, ownerId, err.Error()))
+		return
+	}
+	// 状态已被并发请求更新，由该请求负责通知
+	if !saved {
+		return
+	}
+	for _, percent := range crossed {
+		prompt := fmt.Sprintf("您的额度已低于 %s%%", strconv.FormatFloat(percent, 'f', -1, 64))
+		if tokenName != "" {
+			prompt = fmt.Sprintf("您的令牌 %s 额度已低于 %s%%", tokenName, strconv.FormatFloat(percent, 'f', -1, 64))
+		}
+		content := "{{value}}，当前剩余额度为 {{value}}，请及时充值。"
+		values := []interface{}{prompt, logger.FormatQuota(balance)}
+		notifyType := relayInfo.UserSetting.NotifyType
+		if notifyType == "" || notifyType == dto.NotifyTypeEmail || notifyType == dto.NotifyTypeWebhook {
+			topUpLink := fmt.Sprintf("%s/console/topup", system_setting.ServerAddress)
+			content = "{{value}}，当前剩余额度为 {{value}}，为了不影响您的使用，请及时充值。<br/>充值链接：<a href='{{value}}'>{{value}}</a>"
+			values = append(values, topUpLink, topUpLink)
+		}
+		err := NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeQuotaExceed, prompt, content, values))
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to send quota notify to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateQuotaAlerts_FiresEachThresholdOnce(t *testing.T) {
+	percents := []float64{50, 20, 5}
+	base, level := 0, 0.0
+	fired := map[float64]int{}
+	for balance := 1000; balance >= 0; balance -= 10 {
+		var crossed []float64
+		base, level, crossed = evaluateQuotaAlerts(percents, base, level, balance)
+		for _, percent := range crossed {
+			fired[percent]++
+		}
+	}
+	require.Equal(t, 1000, base)
+	require.Equal(t, map[float64]int{50: 1, 20: 1, 5: 1}, fired)
+}
+
+func TestEvaluateQuotaAlerts_RearmsAfterBalanceRises(t *testing.T) {
+	percents := []float64{50, 20, 5}
+	base, level, crossed := evaluateQuotaAlerts(percents, 1000, 0, 40)
+	require.Equal(t, []float64{50, 20, 5}, crossed)
+	require.Equal(t, 5.0, level)
+
+	// 回升到 30%：5% 与 20% 重新生效，50% 仍处于触发状态
+	base, level, crossed = evaluateQuotaAlerts(percents, base, level, 300)
+	require.Empty(t, crossed)
+	require.Equal(t, 50.0, level)
+
+	base, level, crossed = evaluateQuotaAlerts(percents, base, level, 150)
+	require.Equal(t, []float64{20}, crossed)
+	require.Equal(t, 20.0, level)
+
+	// 充值超过基准后全部阈值重新生效
+	base, level, crossed = evaluateQuotaAlerts(percents, base, level, 2000)
+	require.Empty(t, crossed)
+	require.Equal(t, 2000, base)
+	require.Equal(t, 0.0, level)
+
+	_, _, crossed = evaluateQuotaAlerts(percents, base, level, 900)
+	require.Equal(t, []float64{50}, crossed)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+type redeemFailureState struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+var redeemFailures = struct {
+	sync.Mutex
+	states map[string]*redeemFailureState
+}{states: make(map[string]*redeemFailureState)}
+
+var redeemNow = time.Now
+
+// 内存记录超过该数量时清理过期记录
+const redeemFailureStatesPruneSize = 1024
+
+func redeemLimitKeys(userId int, ip string) []string {
+	keys := []string{fmt.Sprintf("user:%d", userId)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// CheckRedeemAllowed 判断用户或 IP 是否因兑换失败次数过多处于锁定期，锁定时返回剩余秒数
+func CheckRedeemAllowed(userId int, ip string) (bool, int64) {
+	setting := operation_setting.GetRedeemLimitSetting()
+	if !setting.Enabled || setting.MaxFailures <= 0 {
+		return true, 0
+	}
+	for _, key := range redeemLimitKeys(userId, ip) {
+		if remaining := redeemLockRemaining(key); remaining > 0 {
+			return false, int64(remaining.Seconds() + 0.999)
+		}
+	}
+	return true, 0
+}
+
+// RecordRedeemFailure 记录一次兑换失败，窗口内失败次数达到上限时锁定
+func RecordRedeemFailure(userId int, ip string) {
+	setting := operation_setting.GetRedeemLimitSetting()
+	if !setting.Enabled || setting.MaxFailures <= 0 {
+		return
+	}
+	window := time.Duration(setting.WindowSeconds) * time.Second
+	cooldown := time.Duration(setting.CooldownSeconds) * time.Second
+	for _, key := range redeemLimitKeys(userId, ip) {
+		recordRedeemFailure(key, setting.MaxFailures, window, cooldown)
+	}
+}
+
+func redeemLockRemaining(key string) time.Duration {
+	if common.RedisEnabled {
+		ttl, err := common.RDB.TTL(context.Background(), "redeem_lock:"+key).Result()
+		if err != nil || ttl <= 0 {
+			return 0
+		}
+		return ttl
+	}
+	redeemFailures.Lock()
+	defer redeemFailures.Unlock()
+	state, ok := redeemFailures.states[key]
+	if !ok {
+		return 0
+	}
+	return state.lockedUntil.Sub(redeemNow())
+}
+
+func recordRedeemFailure(key string, maxFailures int, window time.Duration, cooldown time.Duration) {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		countKey := "redeem_fail:" + key
+		count, err := common.RDB.Incr(ctx, countKey).Result()
+		if err != nil {
+			common.SysLog("failed to record redeem failure: " + err.Error())
+			return
+		}
+		if count == 1 {
+			common.RDB.Expire(ctx, countKey, window)
+		}
+		if count >= int64(maxFailures) {
+			common.RDB.Set(ctx, "redeem_lock:"+key, 1, cooldown)
+			common.RDB.Del(ctx, countKey)
+		}
+		return
+	}
+	now := redeemNow()
+	redeemFailures.Lock()
+	defer redeemFailures.Unlock()
+	if len(redeemFailures.states) > redeemFailureStatesPruneSize {
+		pruneRedeemFailures(now, window)
+	}
+	state, ok := redeemFailures.states[key]
+	if !ok {
+		state = &redeemFailureState{windowStart: now}
+		redeemFailures.states[key] = state
+	}
+	if now.Sub(state.windowStart) >= window {
+		state.count = 0
+		state.windowStart = now
+	}
+	state.count++
+	if state.count >= maxFailures {
+		state.lockedUntil = now.Add(cooldown)
+		state.count = 0
+	}
+}
+
+// pruneRedeemFailures 清理窗口与锁定均已过期的记录，调用方需持有锁
+func pruneRedeemFailures(now time.Time, window time.Duration) {
+	for key, state := range redeemFailures.states {
+		if now.Sub(state.windowStart) >= window && !now.Before(state.lockedUntil) {
+			delete(redeemFailures.states, key)
+		}
+	}
+}
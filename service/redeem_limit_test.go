@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedeemFailureLimit_LocksThenCoolsDown(t *testing.T) {
+	setting := operation_setting.GetRedeemLimitSetting()
+	orig, origRedis, origNow := *setting, common.RedisEnabled, redeemNow
+	now := time.Unix(1_800_000_000, 0)
+	t.Cleanup(func() {
+		*setting = orig
+		common.RedisEnabled = origRedis
+		redeemNow = origNow
+		redeemFailures.Lock()
+		redeemFailures.states = make(map[string]*redeemFailureState)
+		redeemFailures.Unlock()
+	})
+	common.RedisEnabled = false
+	redeemNow = func() time.Time { return now }
+	*setting = operation_setting.RedeemLimitSetting{Enabled: true, MaxFailures: 3, WindowSeconds: 60, CooldownSeconds: 300}
+
+	for i := 0; i < 2; i++ {
+		RecordRedeemFailure(1, "10.0.0.1")
+		allowed, _ := CheckRedeemAllowed(1, "10.0.0.1")
+		require.True(t, allowed)
+	}
+	RecordRedeemFailure(1, "10.0.0.1")
+	allowed, retryAfter := CheckRedeemAllowed(1, "10.0.0.1")
+	require.False(t, allowed)
+	require.EqualValues(t, 300, retryAfter)
+	// 同一 IP 的其他用户同样被锁定，其他 IP 的其他用户不受影响
+	allowed, _ = CheckRedeemAllowed(2, "10.0.0.1")
+	require.False(t, allowed)
+	allowed, _ = CheckRedeemAllowed(2, "10.0.0.2")
+	require.True(t, allowed)
+
+	// 冷却结束后恢复
+	now = now.Add(301 * time.Second)
+	allowed, _ = CheckRedeemAllowed(1, "10.0.0.1")
+	require.True(t, allowed)
+
+	// 超出统计窗口的失败不累计
+	RecordRedeemFailure(3, "")
+	RecordRedeemFailure(3, "")
+	now = now.Add(61 * time.Second)
+	RecordRedeemFailure(3, "")
+	allowed, _ = CheckRedeemAllowed(3, "")
+	require.True(t, allowed)
+}
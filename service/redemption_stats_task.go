@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const redemptionStatsReconcileInterval = 1 * time.Hour
+
+var redemptionStatsReconcileOnce sync.Once
+
+// StartRedemptionStatsReconcileTask 定时按兑换码表校准累计统计，修正增量计数可能出现的偏差
+func StartRedemptionStatsReconcileTask() {
+	redemptionStatsReconcileOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(redemptionStatsReconcileInterval)
+			defer ticker.Stop()
+
+			runRedemptionStatsReconcileOnce()
+			for range ticker.C {
+				runRedemptionStatsReconcileOnce()
+			}
+		})
+	})
+}
+
+func runRedemptionStatsReconcileOnce() {
+	if _, err := model.ReconcileRedemptionStats(); err != nil {
+		logger.LogError(context.Background(), fmt.Sprintf("redemption stats: reconcile failed: %v", err))
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 请求诊断的结果
+const (
+	DiagnosticsOutcomeSuccess = "success"
+	DiagnosticsOutcomeFailed  = "failed"
+	// 只有错误日志且成功日志在采样时，无法判断最终是否成功
+	DiagnosticsOutcomeUnknown = "unknown"
+)
+
+// RequestAttemptDiagnosis 一次失败的上游尝试，来自错误日志
+type RequestAttemptDiagnosis struct {
+	ChannelId   int    `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	StatusCode  int    `json:"status_code"`
+	ErrorCode   string `json:"error_code"`
+	ErrorType   string `json:"error_type"`
+	Message     string `json:"message"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// RequestBillingDiagnosis 请求的计费结果，来自消费日志
+type RequestBillingDiagnosis struct {
+	Charged          bool `json:"charged"`
+	Quota            int  `json:"quota"`
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	ChannelId        int  `json:"channel_id"`
+}
+
+// ChannelEligibility 渠道当前是否可被该请求选中及原因
+type ChannelEligibility struct {
+	Group    string   `json:"group"`
+	Id       int      `json:"id"`
+	Name     string   `json:"name"`
+	Status   int      `json:"status"`
+	Priority int64    `json:"priority"`
+	Weight   int      `json:"weight"`
+	Region   string   `json:"region,omitempty"`
+	Eligible bool     `json:"eligible"`
+	Tried    bool     `json:"tried"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// RequestDiagnostics 单个请求的诊断报告
+type RequestDiagnostics struct {
+	RequestId     string                    `json:"request_id"`
+	UserId        int                       `json:"user_id"`
+	Username      string                    `json:"username"`
+	TokenId       int                       `json:"token_id"`
+	ModelName     string                    `json:"model_name"`
+	Group         string                    `json:"group"`
+	Outcome       string                    `json:"outcome"`
+	FailureReason string                    `json:"failure_reason,omitempty"`
+	UsedChannels  []string                  `json:"used_channels"`
+	Attempts      []RequestAttemptDiagnosis `json:"attempts"`
+	Billing       RequestBillingDiagnosis   `json:"billing"`
+	Channels      []ChannelEligibility      `json:"channels"`
+}
+
+// BuildRequestDiagnostics 根据请求 id 的日志与当前渠道状态生成诊断报告
+func BuildRequestDiagnostics(requestId string) (*RequestDiagnostics, error) {
+	if requestId == "" {
+		return nil, errors.New("请求 id 不能为空")
+	}
+	logs, err := model.GetLogsByRequestId(requestId)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		if operation_setting.SuccessLogSamplingActive() {
+			return nil, errors.New("未找到该请求的日志，成功请求日志按采样记录，该请求可能未被采样")
+		}
+		return nil, errors.New("未找到该请求的日志，可能未开启日志记录或日志已被清理")
+	}
+
+	report := &RequestDiagnostics{
+		RequestId:    requestId,
+		Outcome:      DiagnosticsOutcomeFailed,
+		UsedChannels: []string{},
+		Attempts:     []RequestAttemptDiagnosis{},
+	}
+	for _, log := range logs {
+		if log.Type != model.LogTypeConsume && log.Type != model.LogTypeError {
+			continue
+		}
+		report.UserId = log.UserId
+		report.Username = log.Username
+		report.TokenId = log.TokenId
+		report.ModelName = log.ModelName
+		report.Group = log.Group
+		other, _ := common.StrToMap(log.Other)
+		if used := usedChannelsFromOther(other); len(used) > 0 {
+			report.UsedChannels = used
+		}
+		switch log.Type {
+		case model.LogTypeError:
+			statusCode, _ := other["status_code"].(float64)
+			report.Attempts = append(report.Attempts, RequestAttemptDiagnosis{
+				ChannelId:   log.ChannelId,
+				ChannelName: common.Interface2String(other["channel_name"]),
+				StatusCode:  int(statusCode),
+				ErrorCode:   common.Interface2String(other["error_code"]),
+				ErrorType:   common.Interface2String(other["error_type"]),
+				Message:     log.Content,
+				CreatedAt:   log.CreatedAt,
+			})
+		case model.LogTypeConsume:
+			report.Outcome = DiagnosticsOutcomeSuccess
+			report.Billing = RequestBillingDiagnosis{
+				Charged:          log.Quota > 0,
+				Quota:            log.Quota,
+				PromptTokens:     log.PromptTokens,
+				CompletionTokens: log.CompletionTokens,
+				ChannelId:        log.ChannelId,
+			}
+		}
+	}
+	if report.Outcome == DiagnosticsOutcomeFailed && len(report.Attempts) > 0 {
+		report.FailureReason = report.Attempts[len(report.Attempts)-1].Message
+		// 重试成功但消费日志未被采样时，错误日志之后可能还有一次成功的尝试
+		if operation_setting.GetSuccessLogSamplePercent(report.Group) < 100 {
+			report.Outcome = DiagnosticsOutcomeUnknown
+		}
+	}
+
+	channels, err := diagnoseChannelEligibility(report)
+	if err != nil {
+		return nil, err
+	}
+	report.Channels = channels
+	return report, nil
+}
+
+func usedChannelsFromOther(other map[string]interface{}) []string {
+	adminInfo, ok := other["admin_info"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	items, ok := adminInfo["use_channel"].([]interface{})
+	if !ok {
+		return nil
+	}
+	used := make([]string, 0, len(items))
+	for _, item := range items {
+		used = append(used, fmt.Sprint(item))
+	}
+	return used
+}
+
+// diagnoseChannelEligibility 按当前配置判断分组下每个渠道能否被该请求选中
+func diagnoseChannelEligibility(report *RequestDiagnostics) ([]ChannelEligibility, error) {
+	result := make([]ChannelEligibility, 0)
+	if report.ModelName == "" {
+		return result, nil
+	}
+	userGroup := ""
+	if user, err := model.GetUserById(report.UserId, false); err == nil {
+		userGroup = user.Group
+	}
+	groups := []string{report.Group}
+	if report.Group == "auto" {
+		groups = GetUserAutoGroup(userGroup)
+	}
+	regions := operation_setting.GetRequiredRegions(report.UserId, userGroup)
+	now := time.Now()
+
+	for _, group := range groups {
+		abilities, err := model.GetGroupModelAbilities(group, report.ModelName)
+		if err != nil {
+			return nil, err
+		}
+		for _, ability := range abilities {
+			item := ChannelEligibility{Group: group, Id: ability.ChannelId, Tried: slices.Contains(report.UsedChannels, strconv.Itoa(ability.ChannelId))}
+			channel, err := model.CacheGetChannel(ability.ChannelId)
+			if err != nil {
+				item.Reasons = append(item.Reasons, "渠道不存在")
+				result = append(result, item)
+				continue
+			}
+			item.Name = channel.Name
+			item.Status = channel.Status
+			item.Priority = channel.GetPriority()
+			item.Weight = channel.GetWeight()
+			item.Region = channel.GetRegion()
+			if channel.Status != common.ChannelStatusEnabled || !ability.Enabled {
+				item.Reasons = append(item.Reasons, "渠道已禁用")
+			}
+			if !channel.IsInSchedule(now) {
+				item.Reasons = append(item.Reasons, "当前不在定时可用窗口内")
+			}
+			if !channel.IsInRegions(regions) {
+				item.Reasons = append(item.Reasons, "渠道区域不满足用户的数据驻留要求")
+			}
+			item.Eligible = len(item.Reasons) == 0
+			result = append(result, item)
+		}
+	}
+	markLowerPriorityChannels(result)
+	return result, nil
+}
+
+// markLowerPriorityChannels 为可选但优先级较低的渠道补充说明，这些渠道只会在重试时被选中
+func markLowerPriorityChannels(channels []ChannelEligibility) {
+	topPriority := make(map[string]int64)
+	for _, channel := range channels {
+		if top, ok := topPriority[channel.Group]; channel.Eligible && (!ok || channel.Priority > top) {
+			topPriority[channel.Group] = channel.Priority
+		}
+	}
+	for i := range channels {
+		if channels[i].Eligible && channels[i].Priority < topPriority[channels[i].Group] {
+			channels[i].Reasons = append(channels[i].Reasons, fmt.Sprintf("优先级低于 %d，仅在重试时可能被选中", topPriority[channels[i].Group]))
+		}
+	}
+}
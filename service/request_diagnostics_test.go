@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRequestDiagnostics_FailedRequest(t *testing.T) {
+	setupServiceTestDB(t, &model.User{}, &model.Log{}, &model.Channel{}, &model.Ability{})
+	residency := operation_setting.GetDataResidencySetting()
+	origUserRegions := residency.UserRegions
+	t.Cleanup(func() { residency.UserRegions = origUserRegions })
+	residency.UserRegions = map[string][]string{"1": {"eu"}}
+
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "alice", Group: "default"}).Error)
+	eu := `{"region":"eu"}`
+	us := `{"region":"us"}`
+	high, low := int64(10), int64(0)
+	channels := []*model.Channel{
+		{Id: 1, Name: "primary", Key: "k1", Status: common.ChannelStatusEnabled, Priority: &high, Setting: &eu},
+		{Id: 2, Name: "backup", Key: "k2", Status: common.ChannelStatusEnabled, Priority: &high, Setting: &eu},
+		{Id: 3, Name: "disabled", Key: "k3", Status: common.ChannelStatusManuallyDisabled, Priority: &high, Setting: &eu},
+		{Id: 4, Name: "fallback", Key: "k4", Status: common.ChannelStatusEnabled, Priority: &low, Setting: &eu},
+		{Id: 5, Name: "us-only", Key: "k5", Status: common.ChannelStatusEnabled, Priority: &high, Setting: &us},
+	}
+	for _, channel := range channels {
+		require.NoError(t, model.DB.Create(channel).Error)
+		require.NoError(t, model.DB.Create(&model.Ability{
+			Group: "default", Model: "gpt-4o", ChannelId: channel.Id,
+			Enabled: channel.Status == common.ChannelStatusEnabled, Priority: channel.Priority,
+		}).Error)
+	}
+	require.NoError(t, model.LOG_DB.Create(&model.Log{
+		UserId: 1, Username: "alice", Type: model.LogTypeError, ModelName: "gpt-4o", Group: "default",
+		ChannelId: 1, Content: "upstream error: 500 internal server error", RequestId: "req-1", CreatedAt: 100,
+		Other: `{"status_code":500,"error_code":"bad_response_status_code","channel_name":"primary","admin_info":{"use_channel":["1"]}}`,
+	}).Error)
+
+	report, err := BuildRequestDiagnostics("req-1")
+	require.NoError(t, err)
+	require.Equal(t, DiagnosticsOutcomeFailed, report.Outcome)
+	require.Equal(t, "upstream error: 500 internal server error", report.FailureReason)
+	require.False(t, report.Billing.Charged)
+	require.Len(t, report.Attempts, 1)
+	require.Equal(t, 500, report.Attempts[0].StatusCode)
+	require.Equal(t, "bad_response_status_code", report.Attempts[0].ErrorCode)
+	require.Equal(t, []string{"1"}, report.UsedChannels)
+
+	byId := make(map[int]ChannelEligibility)
+	for _, channel := range report.Channels {
+		byId[channel.Id] = channel
+	}
+	require.Len(t, byId, 5)
+	require.True(t, byId[1].Eligible)
+	require.True(t, byId[1].Tried)
+	require.True(t, byId[2].Eligible)
+	require.False(t, byId[2].Tried)
+	require.False(t, byId[3].Eligible)
+	require.Contains(t, byId[3].Reasons, "渠道已禁用")
+	require.True(t, byId[4].Eligible)
+	require.NotEmpty(t, byId[4].Reasons)
+	require.False(t, byId[5].Eligible)
+	require.Contains(t, byId[5].Reasons, "渠道区域不满足用户的数据驻留要求")
+
+	_, err = BuildRequestDiagnostics("missing")
+	require.Error(t, err)
+
+	// 成功日志采样时，只有错误日志无法断定请求失败
+	logSetting := operation_setting.GetLogSetting()
+	origGroupPercent := logSetting.GroupSuccessSamplePercent
+	t.Cleanup(func() { logSetting.GroupSuccessSamplePercent = origGroupPercent })
+	logSetting.GroupSuccessSamplePercent = map[string]int{"default": 10}
+	report, err = BuildRequestDiagnostics("req-1")
+	require.NoError(t, err)
+	require.Equal(t, DiagnosticsOutcomeUnknown, report.Outcome)
+}
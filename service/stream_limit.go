@@ -0,0 +1,25 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+var streamLimiter = limiter.NewConnectionLimiter()
+
+// AcquireStreamSlot 流式请求开始时占用连接名额，超过全局或用户上限时返回 429；
+// 返回的 release 需在流结束或客户端断开后调用
+func AcquireStreamSlot(userId int) (release func(), apiErr *types.NewAPIError) {
+	setting := operation_setting.GetStreamLimitSetting()
+	if !setting.Enabled {
+		return func() {}, nil
+	}
+	if !streamLimiter.TryAcquire(userId, setting.MaxStreams, setting.MaxUserStreams) {
+		return nil, types.NewErrorWithStatusCode(errors.New("当前流式连接数已达上限，请稍后重试"), types.ErrorCodeStreamLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	return func() { streamLimiter.Release(userId) }, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireStreamSlot_RejectsOverLimitAndFreesOnRelease(t *testing.T) {
+	setting := operation_setting.GetStreamLimitSetting()
+	orig, origLimiter := *setting, streamLimiter
+	t.Cleanup(func() {
+		*setting = orig
+		streamLimiter = origLimiter
+	})
+	streamLimiter = limiter.NewConnectionLimiter()
+	setting.Enabled = true
+	setting.MaxStreams = 3
+	setting.MaxUserStreams = 2
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, apiErr := AcquireStreamSlot(1)
+		require.Nil(t, apiErr)
+		releases = append(releases, release)
+	}
+	// 单用户第 3 个流被拒绝
+	_, apiErr := AcquireStreamSlot(1)
+	require.NotNil(t, apiErr)
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+
+	release, apiErr := AcquireStreamSlot(2)
+	require.Nil(t, apiErr)
+	releases = append(releases, release)
+	// 全局第 4 个流被拒绝
+	_, apiErr = AcquireStreamSlot(3)
+	require.NotNil(t, apiErr)
+
+	// 流结束后释放名额
+	releases[0]()
+	release, apiErr = AcquireStreamSlot(1)
+	require.Nil(t, apiErr)
+	release()
+	for _, release := range releases[1:] {
+		release()
+	}
+	total, perUser := streamLimiter.InUse(1)
+	require.Zero(t, total)
+	require.Zero(t, perUser)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// tokenAnomalyBaselineAlpha 基线的指数滑动平均系数
+const tokenAnomalyBaselineAlpha = 0.3
+
+// tokenUsageWindow 单个令牌的当前窗口用量与历史基线
+type tokenUsageWindow struct {
+	start            time.Time
+	requests         int
+	quota            int
+	baselineRequests float64
+	baselineQuota    float64
+	samples          int
+	suspended        bool
+}
+
+// tokenAnomalyDetector 按令牌统计窗口用量，基线只保存在本节点内存中
+type tokenAnomalyDetector struct {
+	mu        sync.Mutex
+	windows   map[int]*tokenUsageWindow
+	lastPrune time.Time
+}
+
+var defaultTokenAnomalyDetector = newTokenAnomalyDetector()
+
+func newTokenAnomalyDetector() *tokenAnomalyDetector {
+	return &tokenAnomalyDetector{windows: make(map[int]*tokenUsageWindow)}
+}
+
+// observe 记录一次请求，返回是否命中异常及原因；同一令牌命中后在重置前不会重复返回
+func (d *tokenAnomalyDetector) observe(tokenId int, quota int, now time.Time, setting *operation_setting.TokenAnomalySetting) (string, bool) {
+	window := time.Duration(setting.WindowSeconds) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(now, window, setting.BaselineWindows)
+
+	w, ok := d.windows[tokenId]
+	if !ok {
+		w = &tokenUsageWindow{start: now}
+		d.windows[tokenId] = w
+	}
+	if elapsed := now.Sub(w.start); elapsed >= window {
+		rolled := int(elapsed / window)
+		w.rollover(rolled)
+		w.start = w.start.Add(time.Duration(rolled) * window)
+	}
+	w.requests++
+	if quota > 0 {
+		w.quota += quota
+	}
+	if w.suspended {
+		return "", false
+	}
+
+	reason := w.check(setting)
+	if reason == "" {
+		return "", false
+	}
+	w.suspended = true
+	return reason, true
+}
+
+// rollover 将当前窗口计入基线，期间空闲的窗口按 0 用量计入
+func (w *tokenUsageWindow) rollover(rolled int) {
+	w.baselineRequests, w.baselineQuota = w.mix(float64(w.requests), float64(w.quota))
+	w.samples++
+	for i := 1; i < rolled && w.samples < 1000; i++ {
+		w.baselineRequests, w.baselineQuota = w.mix(0, 0)
+		w.samples++
+	}
+	w.requests = 0
+	w.quota = 0
+}
+
+func (w *tokenUsageWindow) mix(requests float64, quota float64) (float64, float64) {
+	if w.samples == 0 {
+		return requests, quota
+	}
+	return w.baselineRequests + tokenAnomalyBaselineAlpha*(requests-w.baselineRequests),
+		w.baselineQuota + tokenAnomalyBaselineAlpha*(quota-w.baselineQuota)
+}
+
+func (w *tokenUsageWindow) check(setting *operation_setting.TokenAnomalySetting) string {
+	if setting.MaxRequestsPerWindow > 0 && w.requests > setting.MaxRequestsPerWindow {
+		return fmt.Sprintf("窗口内请求数 %d 超过上限 %d", w.requests, setting.MaxRequestsPerWindow)
+	}
+	if setting.MaxQuotaPerWindow > 0 && w.quota > setting.MaxQuotaPerWindow {
+		return fmt.Sprintf("窗口内消耗额度 %s 超过上限 %s", logger.LogQuota(w.quota), logger.LogQuota(setting.MaxQuotaPerWindow))
+	}
+	if setting.SpikeMultiplier <= 0 || w.samples < setting.BaselineWindows || w.requests < setting.MinRequests {
+		return ""
+	}
+	if limit := setting.SpikeMultiplier * max(w.baselineRequests, 1); float64(w.requests) > limit {
+		return fmt.Sprintf("窗口内请求数 %d 超过基线 %.1f 的 %v 倍", w.requests, w.baselineRequests, setting.SpikeMultiplier)
+	}
+	if limit := setting.SpikeMultiplier * max(w.baselineQuota, 1); float64(w.quota) > limit {
+		return fmt.Sprintf("窗口内消耗额度 %s 超过基线 %s 的 %v 倍", logger.LogQuota(w.quota), logger.LogQuota(int(w.baselineQuota)), setting.SpikeMultiplier)
+	}
+	return ""
+}
+
+// prune 定期清理长时间没有请求的令牌，调用方需持有锁
+func (d *tokenAnomalyDetector) prune(now time.Time, window time.Duration, baselineWindows int) {
+	if now.Sub(d.lastPrune) < 10*window {
+		return
+	}
+	d.lastPrune = now
+	ttl := window * time.Duration(max(baselineWindows, 1)*4)
+	for id, w := range d.windows {
+		if !w.suspended && now.Sub(w.start) > ttl {
+			delete(d.windows, id)
+		}
+	}
+}
+
+func (d *tokenAnomalyDetector) reset(tokenId int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.windows, tokenId)
+}
+
+// RecordTokenUsageForAnomaly 记录令牌用量，命中异常时自动暂停令牌并通知管理员
+func RecordTokenUsageForAnomaly(relayInfo *relaycommon.RelayInfo, quota int) {
+	setting := operation_setting.GetTokenAnomalySetting()
+	if !setting.Enabled || relayInfo.IsPlayground || relayInfo.TokenId == 0 || quota <= 0 {
+		return
+	}
+	reason, spike := defaultTokenAnomalyDetector.observe(relayInfo.TokenId, quota, time.Now(), setting)
+	if !spike {
+		return
+	}
+	tokenId := relayInfo.TokenId
+	userId := relayInfo.UserId
+	gopool.Go(func() {
+		changed, err := model.SuspendToken(tokenId)
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to suspend token %d: %s", tokenId, err.Error()))
+			defaultTokenAnomalyDetector.reset(tokenId)
+			return
+		}
+		if !changed {
+			return
+		}
+		content := fmt.Sprintf("令牌 #%d 因用量异常被自动暂停：%s", tokenId, reason)
+		model.RecordLog(userId, model.LogTypeManage, content)
+		NotifyRootUser(dto.NotifyTypeTokenAnomaly, "令牌用量异常", fmt.Sprintf("用户 #%d 的%s，如确认无误请在管理后台恢复该令牌", userId, content))
+	})
+}
+
+// ResetTokenAnomaly 清除令牌的用量统计，管理员恢复令牌后调用
+func ResetTokenAnomaly(tokenId int) {
+	defaultTokenAnomalyDetector.reset(tokenId)
+}
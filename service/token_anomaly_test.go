@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAnomalyDetectorSpikeOverBaseline(t *testing.T) {
+	setting := &operation_setting.TokenAnomalySetting{
+		Enabled:         true,
+		WindowSeconds:   60,
+		SpikeMultiplier: 5,
+		MinRequests:     20,
+		BaselineWindows: 3,
+	}
+	d := newTokenAnomalyDetector()
+	now := time.Unix(1700000000, 0)
+
+	// 前 5 个窗口每个窗口 4 次请求，形成稳定基线
+	for window := 0; window < 5; window++ {
+		for i := 0; i < 4; i++ {
+			_, spike := d.observe(1, 100, now.Add(time.Duration(window)*time.Minute+time.Duration(i)*time.Second), setting)
+			require.False(t, spike)
+		}
+	}
+
+	// 第 6 个窗口请求激增
+	spikeStart := now.Add(5 * time.Minute)
+	triggered := 0
+	var reason string
+	for i := 0; i < 40; i++ {
+		r, spike := d.observe(1, 100, spikeStart.Add(time.Duration(i)*time.Second), setting)
+		if spike {
+			triggered++
+			reason = r
+		}
+	}
+	require.Equal(t, 1, triggered)
+	require.Contains(t, reason, "超过基线")
+
+	// 其他令牌不受影响，重置后重新累积
+	_, spike := d.observe(2, 100, spikeStart, setting)
+	require.False(t, spike)
+	d.reset(1)
+	_, spike = d.observe(1, 100, spikeStart.Add(50*time.Second), setting)
+	require.False(t, spike)
+}
+
+func TestTokenAnomalyDetectorAbsoluteThreshold(t *testing.T) {
+	setting := &operation_setting.TokenAnomalySetting{
+		Enabled:           true,
+		WindowSeconds:     60,
+		BaselineWindows:   10,
+		MaxQuotaPerWindow: 1000,
+	}
+	d := newTokenAnomalyDetector()
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 10; i++ {
+		_, spike := d.observe(1, 100, now.Add(time.Duration(i)*time.Second), setting)
+		require.False(t, spike)
+	}
+	reason, spike := d.observe(1, 100, now.Add(10*time.Second), setting)
+	require.True(t, spike)
+	require.Contains(t, reason, "超过上限")
+
+	// 重置后额度重新统计
+	d.reset(1)
+	_, spike = d.observe(1, 500, now.Add(2*time.Minute), setting)
+	require.False(t, spike)
+}
@@ -6,22 +6,25 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting/system_setting"
 )
 
 // WebhookPayload webhook 通知的负载数据
 type WebhookPayload struct {
-	Type      string        `json:"type"`
-	Title     string        `json:"title"`
-	Content   string        `json:"content"`
-	Values    []interface{} `json:"values,omitempty"`
-	Timestamp int64         `json:"timestamp"`
+	DeliveryId string        `json:"delivery_id"`
+	Type       string        `json:"type"`
+	Title      string        `json:"title"`
+	Content    string        `json:"content"`
+	Values     []interface{} `json:"values,omitempty"`
+	Timestamp  int64         `json:"timestamp"`
 }
 
 // generateSignature 生成 webhook 签名
@@ -31,8 +34,13 @@ func generateSignature(secret string, payload []byte) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// SendWebhookNotify 发送 webhook 通知
-func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error {
+// webhookSender 实际发送 webhook 请求的函数，便于测试替换
+var webhookSender = postWebhook
+
+// SendWebhookNotify 发送 webhook 通知。首次投递失败时才写入投递记录（outbox），
+// 由重试任务按退避策略重新投递，重试耗尽后进入死信，可由管理员重放。
+// 负载与请求头中携带 delivery_id，接收方可据此对重复投递去重。
+func SendWebhookNotify(userId int, webhookURL string, secret string, data dto.Notify) error {
 	// 处理占位符
 	content := data.Content
 	for _, value := range data.Values {
@@ -41,11 +49,12 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 
 	// 构建 webhook 负载
 	payload := WebhookPayload{
-		Type:      data.Type,
-		Title:     data.Title,
-		Content:   content,
-		Values:    data.Values,
-		Timestamp: time.Now().Unix(),
+		DeliveryId: common.GetUUID(),
+		Type:       data.Type,
+		Title:      data.Title,
+		Content:    content,
+		Values:     data.Values,
+		Timestamp:  time.Now().Unix(),
 	}
 
 	// 序列化负载
@@ -54,7 +63,69 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
+	sendErr := webhookSender(webhookURL, secret, payload.DeliveryId, payloadBytes)
+	if sendErr == nil {
+		return nil
+	}
+
+	delivery := &model.WebhookDelivery{
+		DeliveryId: payload.DeliveryId,
+		UserId:     userId,
+		Url:        webhookURL,
+		EventType:  data.Type,
+		Payload:    string(payloadBytes),
+	}
+	if err := model.CreateWebhookDelivery(delivery); err != nil {
+		common.SysLog(fmt.Sprintf("failed to save webhook delivery %s, it will not be retried: %s", delivery.DeliveryId, err.Error()))
+		return sendErr
+	}
+	if err := delivery.RecordAttempt(sendErr, time.Now()); err != nil {
+		common.SysLog(fmt.Sprintf("failed to update webhook delivery %s: %s", delivery.DeliveryId, err.Error()))
+	}
+	return sendErr
+}
+
+// attemptWebhookDelivery 投递一次并记录结果
+func attemptWebhookDelivery(delivery *model.WebhookDelivery, secret string) error {
+	sendErr := webhookSender(delivery.Url, secret, delivery.DeliveryId, []byte(delivery.Payload))
+	if err := delivery.RecordAttempt(sendErr, time.Now()); err != nil {
+		common.SysLog(fmt.Sprintf("failed to update webhook delivery %s: %s", delivery.DeliveryId, err.Error()))
+	}
+	return sendErr
+}
+
+// getWebhookSecret 投递时读取用户当前的 webhook 密钥，outbox 中不保存密钥
+func getWebhookSecret(userId int) string {
+	userSetting, err := model.GetUserSetting(userId, false)
+	if err != nil {
+		return ""
+	}
+	return userSetting.WebhookSecret
+}
+
+// RetryWebhookDelivery 重新投递一条记录，用于自动重试与管理员重放
+func RetryWebhookDelivery(delivery *model.WebhookDelivery) error {
+	return attemptWebhookDelivery(delivery, getWebhookSecret(delivery.UserId))
+}
+
+// ReplayWebhookDelivery 管理员手动重放失败的投递，使用原始 payload 与 delivery_id
+func ReplayWebhookDelivery(id int) (*model.WebhookDelivery, error) {
+	delivery, err := model.GetWebhookDeliveryById(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == model.WebhookDeliveryStatusSucceeded {
+		return delivery, errors.New("该投递已成功，无需重放")
+	}
+	// 重放后重新计算重试次数，失败时仍可继续自动重试
+	delivery.Attempts = 0
+	return delivery, RetryWebhookDelivery(delivery)
+}
+
+// postWebhook 发送 webhook 请求，非 2xx 响应视为失败
+func postWebhook(webhookURL string, secret string, deliveryId string, payloadBytes []byte) error {
 	// 创建 HTTP 请求
+	var err error
 	var req *http.Request
 	var resp *http.Response
 
@@ -65,7 +136,8 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 			Key:    system_setting.WorkerValidKey,
 			Method: http.MethodPost,
 			Headers: map[string]string{
-				"Content-Type": "application/json",
+				"Content-Type":          "application/json",
+				"X-Webhook-Delivery-Id": deliveryId,
 			},
 			Body: payloadBytes,
 		}
@@ -101,6 +173,7 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 
 		// 设置请求头
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Webhook-Delivery-Id", deliveryId)
 
 		// 如果有 secret，生成签名
 		if secret != "" {
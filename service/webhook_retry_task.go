@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	webhookRetryTickInterval = 1 * time.Minute
+	webhookRetryBatchSize    = 100
+	// 重试成功的投递记录保留时长，死信保留以便管理员重放
+	webhookSucceededRetention = 7 * 24 * time.Hour
+)
+
+var (
+	webhookRetryOnce    sync.Once
+	webhookRetryRunning atomic.Bool
+)
+
+// StartWebhookRetryTask 定时重新投递失败的 webhook
+func StartWebhookRetryTask() {
+	webhookRetryOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(webhookRetryTickInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				runWebhookRetryOnce()
+			}
+		})
+	})
+}
+
+func runWebhookRetryOnce() {
+	if !webhookRetryRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer webhookRetryRunning.Store(false)
+
+	ctx := context.Background()
+	deliveries, err := model.GetDueWebhookDeliveries(common.GetTimestamp(), webhookRetryBatchSize)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("webhook retry: query deliveries failed: %v", err))
+		return
+	}
+	for _, delivery := range deliveries {
+		if err := RetryWebhookDelivery(delivery); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("webhook retry: delivery %s attempt %d failed: %v", delivery.DeliveryId, delivery.Attempts, err))
+		}
+	}
+
+	before := time.Now().Add(-webhookSucceededRetention).Unix()
+	if _, err := model.DeleteSucceededWebhookDeliveries(before, webhookRetryBatchSize); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("webhook retry: prune succeeded deliveries failed: %v", err))
+	}
+}
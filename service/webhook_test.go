@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupWebhookTestDB(t *testing.T) {
+	t.Helper()
+	setupServiceTestDB(t, &model.User{}, &model.WebhookDelivery{})
+}
+
+// setupServiceTestDB 使用内存 sqlite 替换 model.DB 与 model.LOG_DB 并关闭 Redis
+func setupServiceTestDB(t *testing.T, models ...any) {
+	t.Helper()
+	origDB, origLogDB, origRedis := model.DB, model.LOG_DB, common.RedisEnabled
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, common.RedisEnabled = origDB, origLogDB, origRedis
+	})
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(models...))
+	model.DB = db
+	model.LOG_DB = db
+	common.RedisEnabled = false
+}
+
+func TestWebhookDelivery_DeadLetterThenReplay(t *testing.T) {
+	setupWebhookTestDB(t)
+	origSender := webhookSender
+	t.Cleanup(func() { webhookSender = origSender })
+
+	var deliveryIds []string
+	receiverDown := true
+	webhookSender = func(webhookURL string, secret string, deliveryId string, payloadBytes []byte) error {
+		deliveryIds = append(deliveryIds, deliveryId)
+		if receiverDown {
+			return errors.New("webhook request failed with status code: 503")
+		}
+		return nil
+	}
+
+	// 投递成功时不写入记录
+	receiverDown = false
+	require.NoError(t, SendWebhookNotify(1, "https://example.com/hook", "", dto.NewNotify(dto.NotifyTypeQuotaExceed, "quota", "ok", nil)))
+	var count int64
+	require.NoError(t, model.DB.Model(&model.WebhookDelivery{}).Count(&count).Error)
+	require.Zero(t, count)
+	deliveryIds = nil
+
+	receiverDown = true
+	err := SendWebhookNotify(1, "https://example.com/hook", "", dto.NewNotify(dto.NotifyTypeQuotaExceed, "quota", "low", nil))
+	require.Error(t, err)
+
+	var delivery model.WebhookDelivery
+	require.NoError(t, model.DB.First(&delivery).Error)
+	for delivery.Status == model.WebhookDeliveryStatusPending {
+		require.Error(t, RetryWebhookDelivery(&delivery))
+	}
+	require.Equal(t, model.WebhookDeliveryStatusDead, delivery.Status)
+	require.Equal(t, model.WebhookDeliveryMaxAttempts, delivery.Attempts)
+	require.Contains(t, delivery.LastError, "503")
+
+	failed, total, err := model.GetFailedWebhookDeliveries(model.WebhookDeliveryStatusDead, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, delivery.DeliveryId, failed[0].DeliveryId)
+
+	receiverDown = false
+	replayed, err := ReplayWebhookDelivery(delivery.Id)
+	require.NoError(t, err)
+	require.Equal(t, model.WebhookDeliveryStatusSucceeded, replayed.Status)
+
+	// 所有投递（含重放）使用同一个 delivery_id，接收方据此去重
+	require.Len(t, deliveryIds, model.WebhookDeliveryMaxAttempts+1)
+	for _, id := range deliveryIds {
+		require.Equal(t, delivery.DeliveryId, id)
+	}
+
+	// 重试成功的记录超过保留期后被清理
+	deleted, err := model.DeleteSucceededWebhookDeliveries(common.GetTimestamp()+1, 100)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+}
@@ -0,0 +1,39 @@
+package model_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// DefaultMaxTokensSettings 客户端未指定最大输出 token 数时使用的默认值，0 表示不设置默认值
+type DefaultMaxTokensSettings struct {
+	Default int `json:"default"`
+	// 按模型覆盖，优先级最高，配置为 0 表示该模型不设置默认值
+	Models map[string]int `json:"models"`
+	// 按分组覆盖，优先级低于模型
+	Groups map[string]int `json:"groups"`
+}
+
+// 默认配置
+var defaultMaxTokensSettings = DefaultMaxTokensSettings{
+	Default: 0,
+	Models:  map[string]int{},
+	Groups:  map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("default_max_tokens", &defaultMaxTokensSettings)
+}
+
+func GetDefaultMaxTokensSettings() *DefaultMaxTokensSettings {
+	return &defaultMaxTokensSettings
+}
+
+// GetDefaultMaxTokens 按模型、分组、全局的顺序取默认最大输出 token 数，返回 0 表示不设置
+func GetDefaultMaxTokens(modelName string, group string) int {
+	if value, ok := defaultMaxTokensSettings.Models[modelName]; ok {
+		return max(value, 0)
+	}
+	if value, ok := defaultMaxTokensSettings.Groups[group]; ok {
+		return max(value, 0)
+	}
+	return max(defaultMaxTokensSettings.Default, 0)
+}
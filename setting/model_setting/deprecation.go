@@ -0,0 +1,65 @@
+package model_setting
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelDeprecation 模型下线计划
+type ModelDeprecation struct {
+	Deprecated  bool   `json:"deprecated"`
+	SunsetDate  string `json:"sunset_date"` // 下线日期，格式 2006-01-02
+	Replacement string `json:"replacement"` // 建议迁移的替代模型
+}
+
+type ModelDeprecationSettings struct {
+	// key 为模型名
+	Models map[string]ModelDeprecation `json:"models"`
+}
+
+// 默认配置
+var modelDeprecationSettings = ModelDeprecationSettings{
+	Models: map[string]ModelDeprecation{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_deprecation", &modelDeprecationSettings)
+}
+
+func GetModelDeprecationSettings() *ModelDeprecationSettings {
+	return &modelDeprecationSettings
+}
+
+// GetModelDeprecation 获取模型的下线计划，未标记为废弃时返回 false
+func GetModelDeprecation(modelName string) (ModelDeprecation, bool) {
+	deprecation, ok := modelDeprecationSettings.Models[modelName]
+	if !ok || !deprecation.Deprecated {
+		return ModelDeprecation{}, false
+	}
+	return deprecation, true
+}
+
+// WarningMessage 返回给客户端的废弃提示
+func (d ModelDeprecation) WarningMessage(modelName string) string {
+	message := fmt.Sprintf("model %s is deprecated", modelName)
+	if d.SunsetDate != "" {
+		message += " and will be removed on " + d.SunsetDate
+	}
+	if d.Replacement != "" {
+		message += ", please migrate to " + d.Replacement
+	}
+	return message
+}
+
+// SunsetHTTPDate 将下线日期转换为 Sunset 响应头使用的 HTTP 日期，日期无效时返回空
+func (d ModelDeprecation) SunsetHTTPDate() string {
+	sunset, err := time.Parse(time.DateOnly, d.SunsetDate)
+	if err != nil {
+		return ""
+	}
+	return sunset.UTC().Format(http.TimeFormat)
+}
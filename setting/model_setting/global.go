@@ -36,11 +36,17 @@ type GlobalSettings struct {
 	PassThroughRequestEnabled        bool                             `json:"pass_through_request_enabled"`
 	ThinkingModelBlacklist           []string                         `json:"thinking_model_blacklist"`
 	ChatCompletionsToResponsesPolicy ChatCompletionsToResponsesPolicy `json:"chat_completions_to_responses_policy"`
+	// 批量 embedding 因部分输入失败时逐条重试，返回 207 与每条输入的错误信息
+	EmbeddingPartialResultEnabled bool `json:"embedding_partial_result_enabled"`
+	// 非流式的 chat completions 请求以流式请求上游再聚合返回的模型，支持以 * 结尾的前缀匹配
+	UpstreamStreamModels []string `json:"upstream_stream_models"`
 }
 
 // 默认配置
 var defaultOpenaiSettings = GlobalSettings{
-	PassThroughRequestEnabled: false,
+	PassThroughRequestEnabled:     false,
+	EmbeddingPartialResultEnabled: false,
+	UpstreamStreamModels:          []string{},
 	ThinkingModelBlacklist: []string{
 		"moonshotai/kimi-k2-thinking",
 		"kimi-k2-thinking",
@@ -77,3 +83,21 @@ func ShouldPreserveThinkingSuffix(modelName string) bool {
 	}
 	return false
 }
+
+// IsUpstreamStreamModel 判断模型是否配置为以流式请求上游
+func IsUpstreamStreamModel(modelName string) bool {
+	for _, pattern := range globalSettings.UpstreamStreamModels {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
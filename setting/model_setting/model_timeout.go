@@ -0,0 +1,36 @@
+package model_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+type ModelTimeoutSettings struct {
+	// key 为模型名，value 为上游请求超时秒数，配置后覆盖渠道默认的 RELAY_TIMEOUT
+	Timeouts map[string]int `json:"timeouts"`
+}
+
+// 默认配置
+var modelTimeoutSettings = ModelTimeoutSettings{
+	Timeouts: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_timeout", &modelTimeoutSettings)
+}
+
+func GetModelTimeoutSettings() *ModelTimeoutSettings {
+	return &modelTimeoutSettings
+}
+
+// GetModelTimeout 获取模型的上游请求超时，依次匹配给定的模型名，未配置时返回 false
+func GetModelTimeout(modelNames ...string) (time.Duration, bool) {
+	for _, name := range modelNames {
+		if seconds, ok := modelTimeoutSettings.Timeouts[name]; ok && seconds > 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package model_setting
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelParameterConstraint 单个模型的采样参数约束
+type ModelParameterConstraint struct {
+	AllowedTemperatures []float64 `json:"allowed_temperatures,omitempty"` // 仅允许的 temperature 取值，例如 [1]
+	MinTemperature      *float64  `json:"min_temperature,omitempty"`
+	MaxTemperature      *float64  `json:"max_temperature,omitempty"`
+	DisallowTopP        bool      `json:"disallow_top_p,omitempty"` // 模型不接受 top_p
+	Reject              bool      `json:"reject,omitempty"`         // true: 不满足约束时拒绝请求；false: 自动修正后转发
+}
+
+type ModelParameterSettings struct {
+	// key 为模型名，支持以 * 结尾的前缀匹配，例如 o1*
+	Constraints map[string]ModelParameterConstraint `json:"constraints"`
+}
+
+// 默认配置
+var modelParameterSettings = ModelParameterSettings{
+	Constraints: map[string]ModelParameterConstraint{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_parameter", &modelParameterSettings)
+}
+
+func GetModelParameterSettings() *ModelParameterSettings {
+	return &modelParameterSettings
+}
+
+// GetModelParameterConstraint 获取模型的参数约束，精确匹配优先，其次为最长的前缀匹配
+func GetModelParameterConstraint(modelName string) (*ModelParameterConstraint, bool) {
+	if constraint, ok := modelParameterSettings.Constraints[modelName]; ok {
+		return &constraint, true
+	}
+	var matched *ModelParameterConstraint
+	matchedLen := -1
+	for pattern, constraint := range modelParameterSettings.Constraints {
+		if !strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, "*")
+		if strings.HasPrefix(modelName, prefix) && len(prefix) > matchedLen {
+			c := constraint
+			matched = &c
+			matchedLen = len(prefix)
+		}
+	}
+	return matched, matched != nil
+}
+
+// ApplyTemperature 按约束修正 temperature，Reject 模式下不满足约束时返回错误；未传入时保持不传
+func (c *ModelParameterConstraint) ApplyTemperature(modelName string, temperature *float64) (*float64, error) {
+	if temperature == nil {
+		return nil, nil
+	}
+	value := *temperature
+	fixed := value
+	if len(c.AllowedTemperatures) > 0 {
+		fixed = c.AllowedTemperatures[0]
+		for _, allowed := range c.AllowedTemperatures {
+			if math.Abs(allowed-value) < math.Abs(fixed-value) {
+				fixed = allowed
+			}
+		}
+	}
+	if c.MinTemperature != nil && fixed < *c.MinTemperature {
+		fixed = *c.MinTemperature
+	}
+	if c.MaxTemperature != nil && fixed > *c.MaxTemperature {
+		fixed = *c.MaxTemperature
+	}
+	if fixed == value {
+		return temperature, nil
+	}
+	if c.Reject {
+		return temperature, fmt.Errorf("模型 %s 不支持 temperature=%v", modelName, value)
+	}
+	return &fixed, nil
+}
+
+// AllowTopP 判断是否保留 top_p，Reject 模式下模型不接受 top_p 时返回错误
+func (c *ModelParameterConstraint) AllowTopP(modelName string, set bool) (bool, error) {
+	if !set || !c.DisallowTopP {
+		return set, nil
+	}
+	if c.Reject {
+		return true, fmt.Errorf("模型 %s 不支持 top_p 参数", modelName)
+	}
+	return false, nil
+}
@@ -0,0 +1,66 @@
+package model_setting
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withModelParameterConstraints(t *testing.T, constraints map[string]ModelParameterConstraint) {
+	orig := modelParameterSettings
+	t.Cleanup(func() { modelParameterSettings = orig })
+	modelParameterSettings = ModelParameterSettings{Constraints: constraints}
+}
+
+func TestModelParameterConstraint_ClampsToOnlyAllowedTemperature(t *testing.T) {
+	withModelParameterConstraints(t, map[string]ModelParameterConstraint{
+		"o1*": {AllowedTemperatures: []float64{1}, DisallowTopP: true},
+	})
+
+	constraint, ok := GetModelParameterConstraint("o1-mini")
+	require.True(t, ok)
+
+	fixed, err := constraint.ApplyTemperature("o1-mini", common.GetPointer(0.2))
+	require.NoError(t, err)
+	require.Equal(t, 1.0, *fixed)
+
+	// 未传 temperature 时保持不传
+	fixed, err = constraint.ApplyTemperature("o1-mini", nil)
+	require.NoError(t, err)
+	require.Nil(t, fixed)
+
+	keep, err := constraint.AllowTopP("o1-mini", true)
+	require.NoError(t, err)
+	require.False(t, keep)
+}
+
+func TestModelParameterConstraint_RejectMode(t *testing.T) {
+	withModelParameterConstraints(t, map[string]ModelParameterConstraint{
+		"reasoner": {AllowedTemperatures: []float64{1}, Reject: true},
+	})
+
+	constraint, ok := GetModelParameterConstraint("reasoner")
+	require.True(t, ok)
+	_, err := constraint.ApplyTemperature("reasoner", common.GetPointer(0.7))
+	require.Error(t, err)
+	_, err = constraint.ApplyTemperature("reasoner", common.GetPointer(1.0))
+	require.NoError(t, err)
+}
+
+func TestModelParameterConstraint_RangeAndPrefixPriority(t *testing.T) {
+	withModelParameterConstraints(t, map[string]ModelParameterConstraint{
+		"claude-*":        {MaxTemperature: common.GetPointer(1.0)},
+		"claude-3-7-son*": {MinTemperature: common.GetPointer(0.5), MaxTemperature: common.GetPointer(0.9)},
+	})
+
+	constraint, ok := GetModelParameterConstraint("claude-3-7-sonnet")
+	require.True(t, ok)
+	fixed, err := constraint.ApplyTemperature("claude-3-7-sonnet", common.GetPointer(1.0))
+	require.NoError(t, err)
+	require.Equal(t, 0.9, *fixed)
+
+	_, ok = GetModelParameterConstraint("gpt-4o")
+	require.False(t, ok)
+}
@@ -0,0 +1,70 @@
+package model_setting
+
+import (
+	"math/rand"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// VirtualModelTarget 虚拟模型的一个实际模型及其流量权重
+type VirtualModelTarget struct {
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+type VirtualModelSettings struct {
+	// key 为虚拟模型名，每次请求按权重选择一个实际模型，并按实际模型计费
+	Models map[string][]VirtualModelTarget `json:"models"`
+}
+
+// 默认配置
+var virtualModelSettings = VirtualModelSettings{
+	Models: map[string][]VirtualModelTarget{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("virtual_model", &virtualModelSettings)
+}
+
+func GetVirtualModelSettings() *VirtualModelSettings {
+	return &virtualModelSettings
+}
+
+// ResolveVirtualModel 若 modelName 为虚拟模型，按权重随机选择一个实际模型；
+// 不是虚拟模型或没有可选目标时原样返回
+func ResolveVirtualModel(modelName string) (string, bool) {
+	targets, ok := virtualModelSettings.Models[modelName]
+	if !ok {
+		return modelName, false
+	}
+	target, ok := selectVirtualModelTarget(targets, rand.Intn)
+	if !ok {
+		return modelName, false
+	}
+	return target, true
+}
+
+// selectVirtualModelTarget 按权重选择目标，权重不大于 0 的目标不参与选择
+func selectVirtualModelTarget(targets []VirtualModelTarget, intn func(int) int) (string, bool) {
+	total := 0
+	for _, target := range targets {
+		if target.Model != "" && target.Weight > 0 {
+			total += target.Weight
+		}
+	}
+	if total == 0 {
+		return "", false
+	}
+	n := intn(total)
+	for _, target := range targets {
+		if target.Model == "" || target.Weight <= 0 {
+			continue
+		}
+		if n < target.Weight {
+			return target.Model, true
+		}
+		n -= target.Weight
+	}
+	return "", false
+}
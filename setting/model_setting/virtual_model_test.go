@@ -0,0 +1,66 @@
+package model_setting
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectVirtualModelTarget_SplitsByWeight(t *testing.T) {
+	targets := []VirtualModelTarget{
+		{Model: "gpt-4o", Weight: 1},
+		{Model: "gpt-4o-mini", Weight: 3},
+		{Model: "disabled", Weight: 0},
+	}
+
+	// 遍历所有取值时，每个目标被选中的次数与权重完全一致
+	counts := map[string]int{}
+	n := 0
+	for i := 0; i < 4; i++ {
+		model, ok := selectVirtualModelTarget(targets, func(total int) int {
+			require.Equal(t, 4, total)
+			v := n
+			n++
+			return v
+		})
+		require.True(t, ok)
+		counts[model]++
+	}
+	require.Equal(t, map[string]int{"gpt-4o": 1, "gpt-4o-mini": 3}, counts)
+
+	// 随机选择时流量比例接近权重
+	r := rand.New(rand.NewSource(1))
+	counts = map[string]int{}
+	const draws = 20000
+	for i := 0; i < draws; i++ {
+		model, ok := selectVirtualModelTarget(targets, r.Intn)
+		require.True(t, ok)
+		counts[model]++
+	}
+	require.InDelta(t, 0.25, float64(counts["gpt-4o"])/draws, 0.02)
+	require.InDelta(t, 0.75, float64(counts["gpt-4o-mini"])/draws, 0.02)
+	require.Zero(t, counts["disabled"])
+}
+
+func TestResolveVirtualModel(t *testing.T) {
+	orig := virtualModelSettings
+	t.Cleanup(func() { virtualModelSettings = orig })
+	virtualModelSettings = VirtualModelSettings{Models: map[string][]VirtualModelTarget{
+		"gpt-4o-auto": {{Model: "gpt-4o-mini", Weight: 1}},
+		"empty":       {{Model: "gpt-4o", Weight: 0}},
+	}}
+
+	model, ok := ResolveVirtualModel("gpt-4o-auto")
+	require.True(t, ok)
+	require.Equal(t, "gpt-4o-mini", model)
+
+	model, ok = ResolveVirtualModel("gpt-4o")
+	require.False(t, ok)
+	require.Equal(t, "gpt-4o", model)
+
+	// 没有可选目标时原样返回
+	model, ok = ResolveVirtualModel("empty")
+	require.False(t, ok)
+	require.Equal(t, "empty", model)
+}
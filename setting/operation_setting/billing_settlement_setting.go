@@ -0,0 +1,32 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// BillingSettlementSetting 定期向外部计费系统上报用户消费的配置
+type BillingSettlementSetting struct {
+	Enabled            bool   `json:"enabled"`
+	Endpoint           string `json:"endpoint"`             // 外部计费系统接收结算数据的地址
+	ApiKey             string `json:"api_key"`              // 以 Bearer 方式放在 Authorization 请求头中
+	IntervalMinutes    int    `json:"interval_minutes"`     // 结算间隔
+	SettleDelaySeconds int64  `json:"settle_delay_seconds"` // 只结算早于该时长的日志，避免遗漏仍在写入的日志
+	MaxRetries         int    `json:"max_retries"`          // 单次结算内的重试次数，仍失败时下次结算继续上报同一批次
+}
+
+// 默认配置
+var billingSettlementSetting = BillingSettlementSetting{
+	Enabled:            false,
+	Endpoint:           "",
+	ApiKey:             "",
+	IntervalMinutes:    60,
+	SettleDelaySeconds: 60,
+	MaxRetries:         3,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("billing_settlement_setting", &billingSettlementSetting)
+}
+
+func GetBillingSettlementSetting() *BillingSettlementSetting {
+	return &billingSettlementSetting
+}
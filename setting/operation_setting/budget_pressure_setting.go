@@ -0,0 +1,36 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// BudgetPressureSetting 全站每日预算紧张时，将昂贵模型的请求自动替换为较便宜的模型，次日预算重置后恢复
+type BudgetPressureSetting struct {
+	Enabled          bool              `json:"enabled"`
+	DailyBudget      int64             `json:"daily_budget"`      // 全站每日预算额度
+	ThresholdPercent int               `json:"threshold_percent"` // 当日消耗达到预算的百分比后开始降级
+	ModelMapping     map[string]string `json:"model_mapping"`     // key 为昂贵模型，value 为替代模型
+}
+
+// 默认配置
+var budgetPressureSetting = BudgetPressureSetting{
+	Enabled:          false,
+	DailyBudget:      0,
+	ThresholdPercent: 90,
+	ModelMapping:     map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("budget_pressure_setting", &budgetPressureSetting)
+}
+
+func GetBudgetPressureSetting() *BudgetPressureSetting {
+	return &budgetPressureSetting
+}
+
+// IsBudgetPressureActive 判断当日消耗 spent 是否已达到降级阈值
+func IsBudgetPressureActive(spent int64) bool {
+	if !budgetPressureSetting.Enabled || budgetPressureSetting.DailyBudget <= 0 {
+		return false
+	}
+	return spent*100 >= budgetPressureSetting.DailyBudget*int64(budgetPressureSetting.ThresholdPercent)
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// DataResidencySetting 数据驻留要求，命中的用户只会被路由到区域标签符合要求的渠道
+type DataResidencySetting struct {
+	GroupRegions map[string][]string `json:"group_regions"` // key 为用户分组
+	UserRegions  map[string][]string `json:"user_regions"`  // key 为用户 id，优先于分组配置
+}
+
+// 默认配置
+var dataResidencySetting = DataResidencySetting{
+	GroupRegions: map[string][]string{},
+	UserRegions:  map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("data_residency_setting", &dataResidencySetting)
+}
+
+func GetDataResidencySetting() *DataResidencySetting {
+	return &dataResidencySetting
+}
+
+// GetRequiredRegions 获取用户允许的渠道区域，返回空表示没有数据驻留要求
+func GetRequiredRegions(userId int, userGroup string) []string {
+	if regions, ok := dataResidencySetting.UserRegions[strconv.Itoa(userId)]; ok && len(regions) > 0 {
+		return regions
+	}
+	return dataResidencySetting.GroupRegions[userGroup]
+}
@@ -0,0 +1,82 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 可全局开关的接口类别
+const (
+	EndpointChat        = "chat"
+	EndpointCompletions = "completions"
+	EndpointEmbeddings  = "embeddings"
+	EndpointImages      = "images"
+	EndpointAudio       = "audio"
+)
+
+// EndpointSetting 接口全局开关，关闭后对应接口的请求直接被拒绝，不影响渠道配置
+type EndpointSetting struct {
+	ChatEnabled        bool `json:"chat_enabled"`
+	CompletionsEnabled bool `json:"completions_enabled"`
+	EmbeddingsEnabled  bool `json:"embeddings_enabled"`
+	ImagesEnabled      bool `json:"images_enabled"`
+	AudioEnabled       bool `json:"audio_enabled"`
+}
+
+// 默认配置
+var endpointSetting = EndpointSetting{
+	ChatEnabled:        true,
+	CompletionsEnabled: true,
+	EmbeddingsEnabled:  true,
+	ImagesEnabled:      true,
+	AudioEnabled:       true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("endpoint_setting", &endpointSetting)
+}
+
+func GetEndpointSetting() *EndpointSetting {
+	return &endpointSetting
+}
+
+// IsKnownEndpoint 判断是否为可开关的接口类别
+func IsKnownEndpoint(endpoint string) bool {
+	switch endpoint {
+	case EndpointChat, EndpointCompletions, EndpointEmbeddings, EndpointImages, EndpointAudio:
+		return true
+	}
+	return false
+}
+
+// IsEndpointEnabled 判断接口类别是否启用，未知类别视为启用
+func IsEndpointEnabled(endpoint string) bool {
+	switch endpoint {
+	case EndpointChat:
+		return endpointSetting.ChatEnabled
+	case EndpointCompletions:
+		return endpointSetting.CompletionsEnabled
+	case EndpointEmbeddings:
+		return endpointSetting.EmbeddingsEnabled
+	case EndpointImages:
+		return endpointSetting.ImagesEnabled
+	case EndpointAudio:
+		return endpointSetting.AudioEnabled
+	}
+	return true
+}
+
+// IsEndpointTypeEnabled 判断模型支持的端点类型是否启用，用于从模型列表中排除已关闭的能力
+func IsEndpointTypeEnabled(endpointType constant.EndpointType) bool {
+	switch endpointType {
+	case constant.EndpointTypeOpenAI, constant.EndpointTypeOpenAIResponse, constant.EndpointTypeOpenAIResponseCompact,
+		constant.EndpointTypeAnthropic, constant.EndpointTypeGemini:
+		return IsEndpointEnabled(EndpointChat)
+	case constant.EndpointTypeImageGeneration:
+		return IsEndpointEnabled(EndpointImages)
+	case constant.EndpointTypeEmbeddings:
+		return IsEndpointEnabled(EndpointEmbeddings)
+	}
+	return true
+}
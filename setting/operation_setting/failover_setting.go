@@ -0,0 +1,51 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// FailoverSetting 跨渠道故障转移的限制，与重试次数相互独立
+type FailoverSetting struct {
+	MaxChannelsTried      int            `json:"max_channels_tried"`       // 单个请求最多尝试的渠道数，0 表示不限制
+	GroupMaxChannelsTried map[string]int `json:"group_max_channels_tried"` // 按分组覆盖，key 为分组名
+	// 上游返回模型不存在时，标记该渠道在一段时间内不承接该模型并换渠道重试，默认关闭
+	ModelNotFoundRetryEnabled bool `json:"model_not_found_retry_enabled"`
+	ModelNotFoundTTLSeconds   int  `json:"model_not_found_ttl_seconds"`
+}
+
+// 默认配置
+var failoverSetting = FailoverSetting{
+	MaxChannelsTried:          0,
+	GroupMaxChannelsTried:     map[string]int{},
+	ModelNotFoundRetryEnabled: false,
+	ModelNotFoundTTLSeconds:   600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("failover_setting", &failoverSetting)
+}
+
+func GetFailoverSetting() *FailoverSetting {
+	return &failoverSetting
+}
+
+// GetMaxChannelsTried 按顺序查找第一个配置了上限的分组，都未配置时使用全局上限
+func GetMaxChannelsTried(groups ...string) int {
+	for _, group := range groups {
+		if limit, ok := failoverSetting.GroupMaxChannelsTried[group]; ok {
+			return limit
+		}
+	}
+	return failoverSetting.MaxChannelsTried
+}
+
+// GetModelNotFoundTTL 渠道被标记为不承接某模型的时长
+func GetModelNotFoundTTL() time.Duration {
+	if failoverSetting.ModelNotFoundTTLSeconds <= 0 {
+		return 600 * time.Second
+	}
+	return time.Duration(failoverSetting.ModelNotFoundTTLSeconds) * time.Second
+}
@@ -20,6 +20,8 @@ type GeneralSetting struct {
 	CustomCurrencySymbol string `json:"custom_currency_symbol"`
 	// 自定义货币与美元汇率（1 USD = X Custom）
 	CustomCurrencyExchangeRate float64 `json:"custom_currency_exchange_rate"`
+	// 是否在响应中返回 X-Served-By 头，内容为渠道配置的服务商标签
+	ServedByHeaderEnabled bool `json:"served_by_header_enabled"`
 }
 
 // 默认配置
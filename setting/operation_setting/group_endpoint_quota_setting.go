@@ -0,0 +1,32 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// GroupEndpointQuotaSetting 分组按接口类别的独立额度，与用户额度同时生效
+type GroupEndpointQuotaSetting struct {
+	// Limits 的 key 为用户分组，内层 key 为接口类别（见 Endpoint* 常量），值为额度上限
+	Limits map[string]map[string]int64 `json:"limits"`
+}
+
+// 默认配置
+var groupEndpointQuotaSetting = GroupEndpointQuotaSetting{
+	Limits: map[string]map[string]int64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_endpoint_quota_setting", &groupEndpointQuotaSetting)
+}
+
+func GetGroupEndpointQuotaSetting() *GroupEndpointQuotaSetting {
+	return &groupEndpointQuotaSetting
+}
+
+// GetGroupEndpointQuotaLimit 获取分组在某类接口上的额度上限，未配置时返回 false
+func GetGroupEndpointQuotaLimit(group string, endpoint string) (int64, bool) {
+	if endpoint == "" {
+		return 0, false
+	}
+	limit, ok := groupEndpointQuotaSetting.Limits[group][endpoint]
+	return limit, ok
+}
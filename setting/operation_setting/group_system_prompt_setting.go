@@ -0,0 +1,40 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// GroupSystemPrompt 分组级系统提示词
+type GroupSystemPrompt struct {
+	Prompt string `json:"prompt"`
+	// Merge 为 true 时拼接到请求已有的第一条系统消息前，否则作为独立的系统消息插入到最前面；
+	// Claude 格式只有一个 system 字段，始终拼接
+	Merge bool `json:"merge"`
+	// NonOverridable 为 true 时即使请求自带系统消息也会注入；否则请求自带系统消息时不注入
+	NonOverridable bool `json:"non_overridable"`
+}
+
+type GroupSystemPromptSetting struct {
+	Prompts map[string]GroupSystemPrompt `json:"prompts"` // key 为用户分组
+}
+
+// 默认配置
+var groupSystemPromptSetting = GroupSystemPromptSetting{
+	Prompts: map[string]GroupSystemPrompt{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_system_prompt_setting", &groupSystemPromptSetting)
+}
+
+func GetGroupSystemPromptSetting() *GroupSystemPromptSetting {
+	return &groupSystemPromptSetting
+}
+
+// GetGroupSystemPrompt 获取分组配置的系统提示词，未配置或提示词为空时返回 false
+func GetGroupSystemPrompt(group string) (GroupSystemPrompt, bool) {
+	prompt, ok := groupSystemPromptSetting.Prompts[group]
+	if !ok || prompt.Prompt == "" {
+		return GroupSystemPrompt{}, false
+	}
+	return prompt, true
+}
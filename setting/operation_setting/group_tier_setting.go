@@ -0,0 +1,80 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 额度等级的判断依据
+const (
+	GroupTierMetricUsedQuota = "used_quota" // 累计消费额度
+	GroupTierMetricQuota     = "quota"      // 当前余额
+)
+
+// GroupTier 额度达到 Threshold 的用户归入 Group
+type GroupTier struct {
+	Group     string `json:"group"`
+	Threshold int64  `json:"threshold"`
+}
+
+// GroupTierSetting 按额度等级自动调整用户分组，只调整当前分组属于某个等级的用户，手动分配的其他分组不受影响
+type GroupTierSetting struct {
+	Enabled        bool        `json:"enabled"`
+	Metric         string      `json:"metric"`
+	Tiers          []GroupTier `json:"tiers"`
+	AllowDowngrade bool        `json:"allow_downgrade"` // 额度回落到较低等级时是否降级
+}
+
+// 默认配置
+var groupTierSetting = GroupTierSetting{
+	Enabled:        false,
+	Metric:         GroupTierMetricUsedQuota,
+	Tiers:          []GroupTier{},
+	AllowDowngrade: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_tier_setting", &groupTierSetting)
+}
+
+func GetGroupTierSetting() *GroupTierSetting {
+	return &groupTierSetting
+}
+
+// sortedGroupTiers 按阈值升序返回等级
+func sortedGroupTiers() []GroupTier {
+	tiers := slices.Clone(groupTierSetting.Tiers)
+	slices.SortStableFunc(tiers, func(a, b GroupTier) int {
+		switch {
+		case a.Threshold < b.Threshold:
+			return -1
+		case a.Threshold > b.Threshold:
+			return 1
+		}
+		return 0
+	})
+	return tiers
+}
+
+// GroupTierRank 返回分组所在等级的序号，不属于任何等级时返回 -1
+func GroupTierRank(group string) int {
+	for i, tier := range sortedGroupTiers() {
+		if tier.Group == group {
+			return i
+		}
+	}
+	return -1
+}
+
+// MatchGroupTier 返回额度 value 对应的最高等级分组及其序号
+func MatchGroupTier(value int64) (string, int, bool) {
+	group, rank := "", -1
+	for i, tier := range sortedGroupTiers() {
+		if value >= tier.Threshold {
+			group, rank = tier.Group, i
+		}
+	}
+	return group, rank, rank >= 0
+}
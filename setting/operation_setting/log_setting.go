@@ -0,0 +1,107 @@
+package operation_setting
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// LogSetting 日志相关配置
+type LogSetting struct {
+	// 日志列表中是否附带按额度换算的金额，不影响存储的额度
+	CostEnabled bool `json:"cost_enabled"`
+	// 换算使用的货币：USD / CNY / CUSTOM，为空时跟随额度展示类型
+	CostCurrency string `json:"cost_currency"`
+	// 金额保留的小数位数
+	CostPrecision int `json:"cost_precision"`
+	// 成功请求详细日志的采样百分比，0-100，错误日志始终记录，扣费不受影响。
+	// 低于 100 时消费日志不完整，按日志汇总的统计（请求诊断、令牌日志等）只反映被采样的请求，外部计费结算会拒绝运行
+	SuccessSamplePercent int `json:"success_sample_percent"`
+	// 按分组覆盖成功请求的采样百分比
+	GroupSuccessSamplePercent map[string]int `json:"group_success_sample_percent"`
+}
+
+// 默认配置
+var logSetting = LogSetting{
+	CostEnabled:   false,
+	CostCurrency:  "",
+	CostPrecision: 6,
+
+	SuccessSamplePercent:      100,
+	GroupSuccessSamplePercent: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_setting", &logSetting)
+}
+
+func GetLogSetting() *LogSetting {
+	return &logSetting
+}
+
+// GetSuccessLogSamplePercent 返回分组成功请求日志的采样百分比，分组未配置时使用全局配置
+func GetSuccessLogSamplePercent(group string) int {
+	percent, ok := logSetting.GroupSuccessSamplePercent[group]
+	if !ok {
+		percent = logSetting.SuccessSamplePercent
+	}
+	return max(0, min(100, percent))
+}
+
+// SuccessLogSamplingActive 判断全局或任一分组的成功请求日志是否在采样（低于 100%）
+func SuccessLogSamplingActive() bool {
+	if logSetting.SuccessSamplePercent < 100 {
+		return true
+	}
+	for _, percent := range logSetting.GroupSuccessSamplePercent {
+		if percent < 100 {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldSampleSuccessLog 按采样百分比决定是否记录本次成功请求的详细日志
+func ShouldSampleSuccessLog(group string) bool {
+	percent := GetSuccessLogSamplePercent(group)
+	if percent >= 100 {
+		return true
+	}
+	return rand.IntN(100) < percent
+}
+
+// GetLogCostCurrency 返回日志金额使用的货币，TOKENS 展示类型下按 USD 换算
+func GetLogCostCurrency() string {
+	currency := logSetting.CostCurrency
+	if currency == "" {
+		currency = generalSetting.QuotaDisplayType
+	}
+	switch currency {
+	case QuotaDisplayTypeCNY, QuotaDisplayTypeCustom:
+		return currency
+	default:
+		return QuotaDisplayTypeUSD
+	}
+}
+
+// QuotaToLogCost 将额度换算为日志货币金额，并按配置的精度四舍五入
+func QuotaToLogCost(quota int) float64 {
+	amount := float64(quota) / common.QuotaPerUnit
+	switch GetLogCostCurrency() {
+	case QuotaDisplayTypeCNY:
+		amount *= USDExchangeRate
+	case QuotaDisplayTypeCustom:
+		if generalSetting.CustomCurrencyExchangeRate > 0 {
+			amount *= generalSetting.CustomCurrencyExchangeRate
+		}
+	}
+	precision := logSetting.CostPrecision
+	if precision < 0 {
+		precision = 0
+	}
+	scale := math.Pow10(precision)
+	return math.Round(amount*scale) / scale
+}
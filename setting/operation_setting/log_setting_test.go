@@ -0,0 +1,45 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withLogCostSetting(t *testing.T, s LogSetting, displayType string, usdToCny float64) {
+	origLog, origDisplay, origRate := logSetting, generalSetting.QuotaDisplayType, USDExchangeRate
+	t.Cleanup(func() {
+		logSetting = origLog
+		generalSetting.QuotaDisplayType = origDisplay
+		USDExchangeRate = origRate
+	})
+	logSetting = s
+	generalSetting.QuotaDisplayType = displayType
+	USDExchangeRate = usdToCny
+}
+
+func TestQuotaToLogCost_USDPrecision(t *testing.T) {
+	withLogCostSetting(t, LogSetting{CostEnabled: true, CostCurrency: QuotaDisplayTypeUSD, CostPrecision: 2}, QuotaDisplayTypeUSD, 7.3)
+
+	// 1234567 / 500000 = 2.469134
+	require.Equal(t, 2.47, QuotaToLogCost(1234567))
+	require.Equal(t, float64(0), QuotaToLogCost(0))
+}
+
+func TestQuotaToLogCost_CNYFollowsDisplayType(t *testing.T) {
+	withLogCostSetting(t, LogSetting{CostEnabled: true, CostPrecision: 4}, QuotaDisplayTypeCNY, 7.3)
+
+	require.Equal(t, QuotaDisplayTypeCNY, GetLogCostCurrency())
+	quota := int(common.QuotaPerUnit) / 3
+	// 166666 / 500000 * 7.3 = 2.4333236
+	require.Equal(t, 2.4333, QuotaToLogCost(quota))
+}
+
+func TestQuotaToLogCost_TokensDisplayFallsBackToUSD(t *testing.T) {
+	withLogCostSetting(t, LogSetting{CostEnabled: true, CostPrecision: 6}, QuotaDisplayTypeTokens, 7.3)
+
+	require.Equal(t, QuotaDisplayTypeUSD, GetLogCostCurrency())
+	require.Equal(t, 1.0, QuotaToLogCost(int(common.QuotaPerUnit)))
+}
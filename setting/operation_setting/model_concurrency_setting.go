@@ -0,0 +1,38 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ModelConcurrencySetting 按模型限制全网关的并发请求数，跨所有渠道统计（单节点内生效）
+type ModelConcurrencySetting struct {
+	Enabled             bool           `json:"enabled"`
+	QueueTimeoutSeconds int            `json:"queue_timeout_seconds"` // 并发已满时的排队时间，超时返回 429，0 表示直接拒绝
+	Limits              map[string]int `json:"limits"`                // 模型名 -> 最大并发数，未配置的模型不限制
+}
+
+// 默认配置
+var modelConcurrencySetting = ModelConcurrencySetting{
+	Enabled:             false,
+	QueueTimeoutSeconds: 0,
+	Limits:              map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_concurrency_setting", &modelConcurrencySetting)
+}
+
+func GetModelConcurrencySetting() *ModelConcurrencySetting {
+	return &modelConcurrencySetting
+}
+
+// GetModelConcurrencyLimit 获取模型的并发上限，未启用或未配置时返回 false
+func GetModelConcurrencyLimit(modelName string) (int, bool) {
+	if !modelConcurrencySetting.Enabled {
+		return 0, false
+	}
+	limit, ok := modelConcurrencySetting.Limits[modelName]
+	if !ok || limit <= 0 {
+		return 0, false
+	}
+	return limit, true
+}
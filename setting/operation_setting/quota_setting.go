@@ -4,6 +4,8 @@ import "github.com/QuantumNous/new-api/setting/config"
 
 type QuotaSetting struct {
 	EnableFreeModelPreConsume bool `json:"enable_free_model_pre_consume"` // 是否对免费模型启用预消耗
+	ClampMaxTokensToQuota     bool `json:"clamp_max_tokens_to_quota"`     // 是否按剩余额度下调请求的最大输出 token 数
+	ExactBilling              bool `json:"exact_billing"`                 // 是否精确计费，不足 1 额度的消耗累计到用户余数而不是四舍五入
 }
 
 // 默认配置
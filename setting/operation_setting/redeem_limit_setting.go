@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RedeemLimitSetting 兑换码失败次数限制，按用户与 IP 分别统计，防止暴力猜测兑换码
+type RedeemLimitSetting struct {
+	Enabled         bool `json:"enabled"`
+	MaxFailures     int  `json:"max_failures"`     // 统计窗口内允许的失败次数
+	WindowSeconds   int  `json:"window_seconds"`   // 失败次数统计窗口
+	CooldownSeconds int  `json:"cooldown_seconds"` // 超过失败次数后的锁定时长
+}
+
+// 默认配置
+var redeemLimitSetting = RedeemLimitSetting{
+	Enabled:         true,
+	MaxFailures:     5,
+	WindowSeconds:   60,
+	CooldownSeconds: 600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("redeem_limit_setting", &redeemLimitSetting)
+}
+
+func GetRedeemLimitSetting() *RedeemLimitSetting {
+	return &redeemLimitSetting
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RedemptionSetting 兑换码相关配置
+type RedemptionSetting struct {
+	MaxQuotaPerCode  int  `json:"max_quota_per_code"` // 单个兑换码的最大额度，0 表示不限制
+	ChecksumEnabled  bool `json:"checksum_enabled"`   // 生成的兑换码末位附带校验位
+	ChecksumRequired bool `json:"checksum_required"`  // 兑换前先校验校验位，开启前需确保不再使用不带校验位的旧兑换码
+}
+
+// 默认配置
+var redemptionSetting = RedemptionSetting{
+	MaxQuotaPerCode:  0,
+	ChecksumEnabled:  false,
+	ChecksumRequired: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("redemption_setting", &redemptionSetting)
+}
+
+func GetRedemptionSetting() *RedemptionSetting {
+	return &redemptionSetting
+}
+
+// RedemptionQuotaExceedsLimit 判断单个兑换码额度是否超过上限
+func RedemptionQuotaExceedsLimit(quota int) bool {
+	return redemptionSetting.MaxQuotaPerCode > 0 && quota > redemptionSetting.MaxQuotaPerCode
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withRedemptionSetting(t *testing.T, s RedemptionSetting) {
+	orig := redemptionSetting
+	t.Cleanup(func() { redemptionSetting = orig })
+	redemptionSetting = s
+}
+
+func TestRedemptionQuotaExceedsLimit(t *testing.T) {
+	withRedemptionSetting(t, RedemptionSetting{MaxQuotaPerCode: 1000})
+
+	require.False(t, RedemptionQuotaExceedsLimit(999))
+	require.False(t, RedemptionQuotaExceedsLimit(1000))
+	require.True(t, RedemptionQuotaExceedsLimit(1001))
+}
+
+func TestRedemptionQuotaExceedsLimit_UnlimitedByDefault(t *testing.T) {
+	withRedemptionSetting(t, RedemptionSetting{})
+
+	require.False(t, RedemptionQuotaExceedsLimit(1<<30))
+}
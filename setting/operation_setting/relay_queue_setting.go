@@ -0,0 +1,35 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RelayQueueSetting 转发请求的全局并发限制与优先级排队配置（单节点内生效）
+type RelayQueueSetting struct {
+	Enabled             bool           `json:"enabled"`
+	MaxConcurrency      int            `json:"max_concurrency"`       // 同时处理的最大请求数
+	QueueTimeoutSeconds int            `json:"queue_timeout_seconds"` // 排队超时时间，超时返回 429
+	AgingSeconds        int            `json:"aging_seconds"`         // 每排队该时长优先级 +1，避免低优先级请求饿死，0 表示不老化
+	GroupPriorities     map[string]int `json:"group_priorities"`      // 分组优先级，数值越大越先获得名额，未配置的分组为 0
+}
+
+// 默认配置
+var relayQueueSetting = RelayQueueSetting{
+	Enabled:             false,
+	MaxConcurrency:      100,
+	QueueTimeoutSeconds: 30,
+	AgingSeconds:        5,
+	GroupPriorities:     map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("relay_queue_setting", &relayQueueSetting)
+}
+
+func GetRelayQueueSetting() *RelayQueueSetting {
+	return &relayQueueSetting
+}
+
+// GetGroupPriority 获取分组的排队优先级
+func GetGroupPriority(group string) int {
+	return relayQueueSetting.GroupPriorities[group]
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ResponseSizeSetting 上游响应大小限制，防止超大响应占满内存
+// 非流式响应超过上限时中止请求并返回错误；流式响应累计超过上限时截断，按已收到的内容计费
+type ResponseSizeSetting struct {
+	MaxResponseBytes int64 `json:"max_response_bytes"` // 非流式响应的最大字节数，0 表示不限制
+	MaxStreamBytes   int64 `json:"max_stream_bytes"`   // 流式响应累计的最大字节数，0 表示不限制
+}
+
+// 默认配置
+var responseSizeSetting = ResponseSizeSetting{
+	MaxResponseBytes: 0,
+	MaxStreamBytes:   0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_size_setting", &responseSizeSetting)
+}
+
+func GetResponseSizeSetting() *ResponseSizeSetting {
+	return &responseSizeSetting
+}
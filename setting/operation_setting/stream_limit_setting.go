@@ -0,0 +1,26 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// StreamLimitSetting 并发流式连接数限制（单节点内生效），与转发请求的并发排队相互独立
+type StreamLimitSetting struct {
+	Enabled        bool `json:"enabled"`
+	MaxStreams     int  `json:"max_streams"`      // 全局同时进行的流式响应数，0 表示不限制
+	MaxUserStreams int  `json:"max_user_streams"` // 单个用户同时进行的流式响应数，0 表示不限制
+}
+
+// 默认配置
+var streamLimitSetting = StreamLimitSetting{
+	Enabled:        false,
+	MaxStreams:     1000,
+	MaxUserStreams: 20,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_limit_setting", &streamLimitSetting)
+}
+
+func GetStreamLimitSetting() *StreamLimitSetting {
+	return &streamLimitSetting
+}
@@ -0,0 +1,26 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TenantSetting 多租户配置，关闭时所有请求都属于默认租户
+type TenantSetting struct {
+	Enabled    bool   `json:"enabled"`
+	Header     string `json:"header"`      // 指定租户标识的请求头，优先于子域名
+	BaseDomain string `json:"base_domain"` // 主域名，<租户标识>.<主域名> 解析为对应租户，为空表示不按子域名解析
+}
+
+// 默认配置
+var tenantSetting = TenantSetting{
+	Enabled:    false,
+	Header:     "X-Tenant",
+	BaseDomain: "",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tenant_setting", &tenantSetting)
+}
+
+func GetTenantSetting() *TenantSetting {
+	return &tenantSetting
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TokenAnomalySetting 令牌用量突增检测配置，命中后自动暂停令牌并通知管理员
+type TokenAnomalySetting struct {
+	Enabled              bool    `json:"enabled"`
+	WindowSeconds        int     `json:"window_seconds"`          // 统计窗口长度
+	SpikeMultiplier      float64 `json:"spike_multiplier"`        // 当前窗口请求数或额度超过基线的倍数，0 表示不按基线检测
+	MinRequests          int     `json:"min_requests"`            // 按基线检测时，当前窗口至少需要的请求数，避免低频令牌误判
+	BaselineWindows      int     `json:"baseline_windows"`        // 至少积累多少个窗口的历史后才按基线检测
+	MaxRequestsPerWindow int     `json:"max_requests_per_window"` // 单窗口请求数上限，0 表示不限制
+	MaxQuotaPerWindow    int     `json:"max_quota_per_window"`    // 单窗口消耗额度上限，0 表示不限制
+}
+
+// 默认配置
+var tokenAnomalySetting = TokenAnomalySetting{
+	Enabled:              false,
+	WindowSeconds:        60,
+	SpikeMultiplier:      10,
+	MinRequests:          30,
+	BaselineWindows:      10,
+	MaxRequestsPerWindow: 0,
+	MaxQuotaPerWindow:    0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_anomaly_setting", &tokenAnomalySetting)
+}
+
+func GetTokenAnomalySetting() *TokenAnomalySetting {
+	return &tokenAnomalySetting
+}
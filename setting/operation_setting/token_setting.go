@@ -0,0 +1,93 @@
+package operation_setting
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// TokenSetting 令牌相关配置
+type TokenSetting struct {
+	NamePolicyEnabled bool   `json:"name_policy_enabled"` // 是否启用令牌命名规范
+	NamePattern       string `json:"name_pattern"`        // 令牌名称需匹配的正则表达式
+	NameAutoPrefix    string `json:"name_auto_prefix"`    // 自动添加的名称前缀，支持 {username}、{group} 占位符
+}
+
+// 默认配置
+var tokenSetting = TokenSetting{
+	NamePolicyEnabled: false, // 默认关闭
+	NamePattern:       "",
+	NameAutoPrefix:    "",
+}
+
+var (
+	tokenNameRegexCache   *regexp.Regexp
+	tokenNameRegexPattern string
+	tokenNameRegexMutex   sync.Mutex
+)
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_setting", &tokenSetting)
+}
+
+func GetTokenSetting() *TokenSetting {
+	return &tokenSetting
+}
+
+// ValidateTokenNamePattern 校验令牌命名正则是否合法
+func ValidateTokenNamePattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("令牌命名规则不是合法的正则表达式: %s", err.Error())
+	}
+	return nil
+}
+
+func getTokenNameRegex(pattern string) (*regexp.Regexp, error) {
+	tokenNameRegexMutex.Lock()
+	defer tokenNameRegexMutex.Unlock()
+	if tokenNameRegexCache != nil && tokenNameRegexPattern == pattern {
+		return tokenNameRegexCache, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	tokenNameRegexCache = re
+	tokenNameRegexPattern = pattern
+	return re, nil
+}
+
+// ApplyTokenNamePolicy 按命名规范处理令牌名称，返回最终名称
+// 启用自动前缀时，名称缺少前缀会被自动补全，之后再按正则校验；group 为空时按 default 分组处理
+func ApplyTokenNamePolicy(name string, username string, group string) (string, error) {
+	if !tokenSetting.NamePolicyEnabled {
+		return name, nil
+	}
+	if group == "" {
+		group = "default"
+	}
+	if tokenSetting.NameAutoPrefix != "" {
+		prefix := strings.NewReplacer("{username}", username, "{group}", group).Replace(tokenSetting.NameAutoPrefix)
+		if !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+	}
+	if tokenSetting.NamePattern == "" {
+		return name, nil
+	}
+	re, err := getTokenNameRegex(tokenSetting.NamePattern)
+	if err != nil {
+		return name, fmt.Errorf("令牌命名规则配置错误，请联系管理员")
+	}
+	if !re.MatchString(name) {
+		return name, fmt.Errorf("令牌名称 %s 不符合命名规范 %s", name, tokenSetting.NamePattern)
+	}
+	return name, nil
+}
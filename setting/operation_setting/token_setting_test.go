@@ -0,0 +1,57 @@
+package operation_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withTokenSetting(t *testing.T, s TokenSetting) {
+	orig := tokenSetting
+	t.Cleanup(func() { tokenSetting = orig })
+	tokenSetting = s
+}
+
+func TestApplyTokenNamePolicy_Disabled(t *testing.T) {
+	withTokenSetting(t, TokenSetting{NamePolicyEnabled: false, NamePattern: "^team-"})
+
+	name, err := ApplyTokenNamePolicy("anything", "alice", "default")
+	require.NoError(t, err)
+	require.Equal(t, "anything", name)
+}
+
+func TestApplyTokenNamePolicy_Conforming(t *testing.T) {
+	withTokenSetting(t, TokenSetting{NamePolicyEnabled: true, NamePattern: `^team-[a-z]+-\w+$`})
+
+	name, err := ApplyTokenNamePolicy("team-ops-ci", "alice", "default")
+	require.NoError(t, err)
+	require.Equal(t, "team-ops-ci", name)
+}
+
+func TestApplyTokenNamePolicy_NonConforming(t *testing.T) {
+	withTokenSetting(t, TokenSetting{NamePolicyEnabled: true, NamePattern: `^team-[a-z]+-\w+$`})
+
+	_, err := ApplyTokenNamePolicy("my token", "alice", "default")
+	require.Error(t, err)
+}
+
+func TestApplyTokenNamePolicy_AutoPrefix(t *testing.T) {
+	withTokenSetting(t, TokenSetting{NamePolicyEnabled: true, NamePattern: `^vip-\w+$`, NameAutoPrefix: "{group}-"})
+
+	name, err := ApplyTokenNamePolicy("ci", "alice", "vip")
+	require.NoError(t, err)
+	require.Equal(t, "vip-ci", name)
+
+	// 已有前缀时不重复添加
+	name, err = ApplyTokenNamePolicy("vip-ci", "alice", "vip")
+	require.NoError(t, err)
+	require.Equal(t, "vip-ci", name)
+}
+
+func TestApplyTokenNamePolicy_EmptyGroupFallsBackToDefault(t *testing.T) {
+	withTokenSetting(t, TokenSetting{NamePolicyEnabled: true, NamePattern: `^default-\w+$`, NameAutoPrefix: "{group}-"})
+
+	name, err := ApplyTokenNamePolicy("ci", "alice", "")
+	require.NoError(t, err)
+	require.Equal(t, "default-ci", name)
+}
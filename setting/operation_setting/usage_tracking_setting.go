@@ -0,0 +1,26 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UsageTrackingSetting 令牌与用户的最近使用时间、IP 记录
+type UsageTrackingSetting struct {
+	Enabled         bool  `json:"enabled"`
+	DebounceSeconds int64 `json:"debounce_seconds"` // 同一令牌或用户两次写入的最小间隔
+	RecordIp        bool  `json:"record_ip"`        // 是否记录最近使用的 IP，关闭后不再保存 IP
+}
+
+// 默认配置
+var usageTrackingSetting = UsageTrackingSetting{
+	Enabled:         true,
+	DebounceSeconds: 60,
+	RecordIp:        true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("usage_tracking_setting", &usageTrackingSetting)
+}
+
+func GetUsageTrackingSetting() *UsageTrackingSetting {
+	return &usageTrackingSetting
+}
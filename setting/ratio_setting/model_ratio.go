@@ -452,11 +452,19 @@ func handleThinkingBudgetModel(name, prefix, wildcard string) string {
 }
 
 func GetModelRatio(name string) (float64, bool, string) {
+	return GetModelRatioAt(name, common.GetTimestamp())
+}
+
+// GetModelRatioAt 获取 at 时刻生效的模型倍率，定时倍率优先于常规倍率
+func GetModelRatioAt(name string, at int64) (float64, bool, string) {
 	modelRatioMapMutex.RLock()
 	defer modelRatioMapMutex.RUnlock()
 
 	name = FormatMatchingModelName(name)
 
+	if ratio, ok := getScheduledModelRatio(name, at); ok {
+		return ratio, true, name
+	}
 	ratio, ok := modelRatioMap[name]
 	if !ok {
 		if strings.HasSuffix(name, CompactModelSuffix) {
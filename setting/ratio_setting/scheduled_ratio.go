@@ -0,0 +1,63 @@
+package ratio_setting
+
+import (
+	"sort"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ScheduledModelRatio 定时生效的模型倍率，到达 EffectiveAt 后替代常规模型倍率
+type ScheduledModelRatio struct {
+	Model       string  `json:"model"`
+	Ratio       float64 `json:"ratio"`
+	EffectiveAt int64   `json:"effective_at"` // unix 秒
+}
+
+// ScheduledRatioSetting 模型倍率的定时调整计划
+type ScheduledRatioSetting struct {
+	Entries []ScheduledModelRatio `json:"entries"`
+}
+
+// 默认配置
+var scheduledRatioSetting = ScheduledRatioSetting{
+	Entries: []ScheduledModelRatio{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("scheduled_ratio_setting", &scheduledRatioSetting)
+}
+
+func GetScheduledRatioSetting() *ScheduledRatioSetting {
+	return &scheduledRatioSetting
+}
+
+// getScheduledModelRatio 返回 at 时刻已生效的最新定时倍率，没有时返回 false
+func getScheduledModelRatio(name string, at int64) (float64, bool) {
+	var found bool
+	var latest ScheduledModelRatio
+	for _, entry := range scheduledRatioSetting.Entries {
+		if FormatMatchingModelName(entry.Model) != name || entry.EffectiveAt > at {
+			continue
+		}
+		if !found || entry.EffectiveAt >= latest.EffectiveAt {
+			latest = entry
+			found = true
+		}
+	}
+	return latest.Ratio, found
+}
+
+// GetUpcomingScheduledRatios 返回 at 之后尚未生效的定时倍率，按生效时间升序
+func GetUpcomingScheduledRatios(at int64) []ScheduledModelRatio {
+	upcoming := make([]ScheduledModelRatio, 0)
+	for _, entry := range scheduledRatioSetting.Entries {
+		if entry.EffectiveAt > at {
+			upcoming = append(upcoming, entry)
+		}
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].EffectiveAt < upcoming[j].EffectiveAt
+	})
+	return upcoming
+}
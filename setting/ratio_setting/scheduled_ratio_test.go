@@ -0,0 +1,38 @@
+package ratio_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetModelRatioAt_ScheduledEntries(t *testing.T) {
+	InitRatioSettings()
+	orig := scheduledRatioSetting.Entries
+	t.Cleanup(func() { scheduledRatioSetting.Entries = orig })
+
+	baseRatio, ok, _ := GetModelRatio("gpt-4o")
+	require.True(t, ok)
+
+	const effectiveAt int64 = 1_800_000_000
+	scheduledRatioSetting.Entries = []ScheduledModelRatio{
+		{Model: "gpt-4o", Ratio: 2, EffectiveAt: effectiveAt},
+		{Model: "gpt-4o", Ratio: 3, EffectiveAt: effectiveAt + 3600},
+	}
+	const promptTokens = 1000
+	bill := func(at int64) int {
+		ratio, ok, _ := GetModelRatioAt("gpt-4o", at)
+		require.True(t, ok)
+		return int(float64(promptTokens) * ratio)
+	}
+
+	// 生效前沿用常规倍率
+	require.Equal(t, int(float64(promptTokens)*baseRatio), bill(effectiveAt-1))
+	// 生效后使用最新一条已生效的定时倍率
+	require.Equal(t, 2000, bill(effectiveAt))
+	require.Equal(t, 3000, bill(effectiveAt+7200))
+
+	upcoming := GetUpcomingScheduledRatios(effectiveAt)
+	require.Len(t, upcoming, 1)
+	require.Equal(t, 3.0, upcoming[0].Ratio)
+}
@@ -0,0 +1,87 @@
+package system_setting
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+type ChannelSecuritySetting struct {
+	HttpsOnly        bool     `json:"https_only"`         // 是否仅允许 https 渠道地址
+	HttpAllowedHosts []string `json:"http_allowed_hosts"` // 允许使用 http 的内部主机，支持 *.example.com 通配
+	AllowedDomains   []string `json:"allowed_domains"`    // 渠道地址域名白名单，为空时不限制，支持 *.example.com 通配
+	DeniedDomains    []string `json:"denied_domains"`     // 渠道地址域名黑名单，优先于白名单，支持 *.example.com 通配
+}
+
+var defaultChannelSecuritySetting = ChannelSecuritySetting{
+	HttpsOnly:        false,
+	HttpAllowedHosts: []string{},
+	AllowedDomains:   []string{},
+	DeniedDomains:    []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_security_setting", &defaultChannelSecuritySetting)
+}
+
+func GetChannelSecuritySetting() *ChannelSecuritySetting {
+	return &defaultChannelSecuritySetting
+}
+
+// MatchHostPattern 判断主机是否匹配规则，规则为精确主机名或 *.example.com 形式的通配
+func MatchHostPattern(host string, pattern string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return false
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+func matchAnyHostPattern(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if MatchHostPattern(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateChannelBaseURL 按域名黑白名单与仅 https 模式校验渠道地址，白名单中的内部主机可使用 http
+func ValidateChannelBaseURL(baseURL string) error {
+	setting := GetChannelSecuritySetting()
+	if baseURL == "" {
+		return nil
+	}
+	checkDomain := len(setting.AllowedDomains) > 0 || len(setting.DeniedDomains) > 0
+	if !setting.HttpsOnly && !checkDomain {
+		return nil
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("渠道地址格式错误: %s", baseURL)
+	}
+	host := parsed.Hostname()
+	if checkDomain && host == "" {
+		return fmt.Errorf("渠道地址格式错误: %s", baseURL)
+	}
+	if matchAnyHostPattern(host, setting.DeniedDomains) {
+		return fmt.Errorf("渠道地址 %s 的域名 %s 在禁止列表中", baseURL, host)
+	}
+	if len(setting.AllowedDomains) > 0 && !matchAnyHostPattern(host, setting.AllowedDomains) {
+		return fmt.Errorf("渠道地址 %s 的域名 %s 不在允许列表中", baseURL, host)
+	}
+	if !setting.HttpsOnly || strings.EqualFold(parsed.Scheme, "https") {
+		return nil
+	}
+	if matchAnyHostPattern(host, setting.HttpAllowedHosts) {
+		return nil
+	}
+	return fmt.Errorf("已启用仅 HTTPS 模式，渠道地址 %s 未使用 https，且主机 %s 不在允许列表中", baseURL, host)
+}
@@ -0,0 +1,46 @@
+package system_setting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withChannelSecuritySetting(t *testing.T, s ChannelSecuritySetting) {
+	orig := defaultChannelSecuritySetting
+	t.Cleanup(func() { defaultChannelSecuritySetting = orig })
+	defaultChannelSecuritySetting = s
+}
+
+func TestValidateChannelBaseURL_RejectsHttpInStrictMode(t *testing.T) {
+	withChannelSecuritySetting(t, ChannelSecuritySetting{HttpsOnly: true, HttpAllowedHosts: []string{"llm.internal"}})
+
+	require.Error(t, ValidateChannelBaseURL("http://api.example.com"))
+	require.NoError(t, ValidateChannelBaseURL("https://api.example.com"))
+}
+
+func TestValidateChannelBaseURL_AllowlistedInternalHost(t *testing.T) {
+	withChannelSecuritySetting(t, ChannelSecuritySetting{HttpsOnly: true, HttpAllowedHosts: []string{"llm.internal", "*.svc.cluster.local"}})
+
+	require.NoError(t, ValidateChannelBaseURL("http://llm.internal:8000"))
+	require.NoError(t, ValidateChannelBaseURL("http://vllm.ai.svc.cluster.local/v1"))
+	require.Error(t, ValidateChannelBaseURL("http://svc.cluster.local.evil.com"))
+}
+
+func TestValidateChannelBaseURL_DisabledAllowsHttp(t *testing.T) {
+	withChannelSecuritySetting(t, ChannelSecuritySetting{HttpsOnly: false})
+
+	require.NoError(t, ValidateChannelBaseURL("http://api.example.com"))
+}
+
+func TestValidateChannelBaseURL_DomainAllowlist(t *testing.T) {
+	withChannelSecuritySetting(t, ChannelSecuritySetting{
+		AllowedDomains: []string{"api.openai.com", "*.azure.com"},
+		DeniedDomains:  []string{"evil.azure.com"},
+	})
+	require.NoError(t, ValidateChannelBaseURL("https://api.openai.com"))
+	require.NoError(t, ValidateChannelBaseURL("https://my-resource.openai.azure.com/"))
+	require.Error(t, ValidateChannelBaseURL("https://api.openai.com.evil.com"))
+	require.Error(t, ValidateChannelBaseURL("https://attacker.example.com/v1"))
+	require.Error(t, ValidateChannelBaseURL("https://evil.azure.com"))
+}
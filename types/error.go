@@ -41,6 +41,9 @@ const (
 	ErrorCodeInvalidRequest         ErrorCode = "invalid_request"
 	ErrorCodeSensitiveWordsDetected ErrorCode = "sensitive_words_detected"
 	ErrorCodeViolationFeeGrokCSAM   ErrorCode = "violation_fee.grok.csam"
+	ErrorCodeEndpointDisabled       ErrorCode = "endpoint_disabled"
+	ErrorCodeDataResidency          ErrorCode = "data_residency_unavailable"
+	ErrorCodeStreamLimitExceeded    ErrorCode = "stream_limit_exceeded"
 
 	// new api error
 	ErrorCodeCountTokenFailed   ErrorCode = "count_token_failed"
@@ -59,6 +62,9 @@ const (
 	ErrorCodeChannelAwsClientError        ErrorCode = "channel:aws_client_error"
 	ErrorCodeChannelInvalidKey            ErrorCode = "channel:invalid_key"
 	ErrorCodeChannelResponseTimeExceeded  ErrorCode = "channel:response_time_exceeded"
+	ErrorCodeChannelInsecureBaseURL       ErrorCode = "channel:insecure_base_url"
+	ErrorCodeChannelTransformerFailed     ErrorCode = "channel:transformer_failed"
+	ErrorCodeChannelResponseTooLarge      ErrorCode = "channel:response_too_large"
 
 	// client request error
 	ErrorCodeReadRequestBodyFailed ErrorCode = "read_request_body_failed"
@@ -84,6 +90,7 @@ const (
 
 	// quota error
 	ErrorCodeInsufficientUserQuota      ErrorCode = "insufficient_user_quota"
+	ErrorCodeInsufficientGroupQuota     ErrorCode = "insufficient_group_endpoint_quota"
 	ErrorCodePreConsumeTokenQuotaFailed ErrorCode = "pre_consume_token_quota_failed"
 )
 